@@ -0,0 +1,407 @@
+// Command line tool for setting up and running the auction servers
+package main
+
+import (
+	"Auction/audit"
+	"Auction/auth"
+	"Auction/frontend"
+	proto "Auction/grpc"
+	"Auction/signing"
+	"Auction/tlsconfig"
+	"Auction/tracing"
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// A subcommand of auctionctl, run gets the arguments after the name of the subcommand
+type subcommand struct {
+	name        string
+	description string
+	run         func(args []string)
+}
+
+var subcommands []subcommand
+
+// The table is filled in init, since usage refers to it
+func init() {
+	subcommands = []subcommand{
+		{"gen-key", "create a signing key for the tokens, receipts or bids", runGenKey},
+		{"hash-password", "hash a password for the users in the configuration", runHashPassword},
+		{"gen-ca", "create a certificate authority and a server certificate for local development", runGenCa},
+		{"public-key", "print the public key of an Ed25519 key, for the bidders in the configuration", runPublicKey},
+		{"verify", "check the signatures of a bid receipt", runVerify},
+		{"audit", "check the hash chain of an audit log, with `audit verify`", runAudit},
+		{"trace", "show the spans of the client and server files as a tree per trace", runTrace},
+		{"status", "show the state of every server and mark the servers that don't agree", runStatus},
+		{"diverge", "compare the states of the servers and show the auctions and bids that differ", runDiverge},
+		{"lincheck", "record bids and results of concurrent clients and check that the history is linearizable", runLincheck},
+		{"simulate", "run the crash and network scenarios on a cluster inside the process", runSimulate},
+		{"faults", "show or change the faults injected into the calls of the servers", runFaults},
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, subcommand := range subcommands {
+		if subcommand.name == os.Args[1] {
+			subcommand.run(os.Args[2:])
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [arguments]\n\nThe commands are:\n", os.Args[0])
+	for _, subcommand := range subcommands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", subcommand.name, subcommand.description)
+	}
+}
+
+func runGenKey(args []string) {
+	flags := flag.NewFlagSet("gen-key", flag.ExitOnError)
+	algorithm := flags.String("alg", "HS256", "the algorithm of the key, HS256 or EdDSA")
+	out := flags.String("out", "", "the file to write the key to, the default is the console")
+	flags.Parse(args)
+
+	var key []byte
+	switch *algorithm {
+	case "HS256":
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatal(err)
+		}
+		key = []byte(base64.StdEncoding.EncodeToString(secret) + "\n")
+	case "EdDSA":
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		key = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	default:
+		log.Fatalf("Unknown algorithm %q, must be HS256 or EdDSA", *algorithm)
+	}
+	writeOutput(*out, key)
+}
+
+func runHashPassword(args []string) {
+	flags := flag.NewFlagSet("hash-password", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: auctionctl hash-password [password]\nWithout an argument the password is read from the console.\n")
+	}
+	flags.Parse(args)
+
+	password := flags.Arg(0)
+	if password == "" {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			log.Fatal(err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(hash)
+}
+
+func runGenCa(args []string) {
+	flags := flag.NewFlagSet("gen-ca", flag.ExitOnError)
+	dir := flags.String("dir", "certs", "the directory to write the certificates and keys to")
+	hosts := flags.String("hosts", "localhost,127.0.0.1", "comma separated host names and IP addresses of the servers")
+	flags.Parse(args)
+
+	if err := tlsconfig.GenerateDevCA(*dir, strings.Split(*hosts, ",")); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Wrote %s, %s, %s and %s to %s\n", tlsconfig.CaCertFile, tlsconfig.CaKeyFile, tlsconfig.ServerCertFile, tlsconfig.ServerKeyFile, *dir)
+}
+
+func runPublicKey(args []string) {
+	flags := flag.NewFlagSet("public-key", flag.ExitOnError)
+	keyFile := flags.String("key", "", "the Ed25519 private key, as written by gen-key -alg EdDSA")
+	flags.Parse(args)
+	if *keyFile == "" {
+		log.Fatal("The key file is required, see -h")
+	}
+
+	privateKey, err := signing.LoadPrivateKey(*keyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(signing.EncodePublicKey(privateKey.Public().(ed25519.PublicKey)))
+}
+
+func runVerify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	servers := flags.String("servers", "", "comma separated public keys of the trusted servers")
+	bidder := flags.String("bidder", "", "the public key of the bidder, to also check the signature of the bid")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: auctionctl verify -servers keys [-bidder key] [receipt.json]\n"+
+			"The receipt is a Receipt or an Acknowledgement as JSON, without a file it is read from the console.\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *servers == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	var trustedServers []ed25519.PublicKey
+	for _, encoded := range strings.Split(*servers, ",") {
+		publicKey, err := signing.ParsePublicKey(encoded)
+		if err != nil {
+			log.Fatalf("Invalid server key: %v", err)
+		}
+		trustedServers = append(trustedServers, publicKey)
+	}
+	var bidderKey ed25519.PublicKey
+	if *bidder != "" {
+		publicKey, err := signing.ParsePublicKey(*bidder)
+		if err != nil {
+			log.Fatalf("Invalid bidder key: %v", err)
+		}
+		bidderKey = publicKey
+	}
+
+	receipt, err := readReceipt(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := signing.VerifyReceipt(receipt, trustedServers, bidderKey); err != nil {
+		log.Fatalf("The receipt is NOT valid: %v", err)
+	}
+	fmt.Printf("The receipt is valid: %s bid %d at %s and the server answered %q (receipt %d at %s)\n",
+		receipt.Bidder, receipt.Amount, time.UnixMilli(receipt.BidTimestamp).Format(time.RFC3339),
+		receipt.Status, receipt.Sequence, time.UnixMilli(receipt.Timestamp).Format(time.RFC3339))
+	if bidderKey == nil {
+		fmt.Println("The signature of the bid was not checked, give -bidder to check it")
+	}
+}
+
+func runAudit(args []string) {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprintf(os.Stderr, "Usage: auctionctl audit verify [-server address] [-ca file] [-user seller] audit.jsonl\n")
+		os.Exit(2)
+	}
+	flags := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	server := flags.String("server", "", "the grpc address of the server that wrote the log, fx localhost:5000, to compare the log with its result")
+	caFile := flags.String("ca", "", "connect to the server with TLS, trusting the certificate authority in the file")
+	user := flags.String("user", "", "log in to the server as the seller or an admin, with -password or $AUCTION_PASSWORD, to see the real name of the winner when the server shows pseudonyms")
+	password := flags.String("password", os.Getenv("AUCTION_PASSWORD"), "the password of -user")
+	flags.Parse(args[1:])
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	summary, err := audit.Verify(file)
+	if err != nil {
+		log.Fatalf("The audit log is NOT valid: %v", err)
+	}
+	fmt.Printf("The hash chain of the %d entries is intact, the highest bid is %d", summary.Entries, summary.HighestBid)
+	if winner := summary.Winner(); winner != "" {
+		fmt.Printf(" and %s won", winner)
+	}
+	fmt.Printf("\nThe last hash is %s\n", summary.LastHash)
+	if *server == "" {
+		return
+	}
+
+	//The log of a server must end in the state that the server reports
+	options := []frontend.Option{frontend.WithDiscovery(frontend.StaticDiscovery{*server}), frontend.WithRetries(0, 0)}
+	if *caFile != "" {
+		tlsConfig, err := tlsconfig.ClientConfig(*caFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		options = append(options, frontend.WithDialOptions(grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))))
+	}
+	auctionClient, err := frontend.New(options...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer auctionClient.Close()
+	//With pseudonyms only the seller and admins see the real name of the winner, which is the name in the log
+	if *user != "" {
+		if _, err := auctionClient.Login(context.Background(), *user, *password); err != nil {
+			log.Fatalf("Could not log in to %s: %v", *server, err)
+		}
+	}
+	outcome, err := auctionClient.Result(context.Background())
+	if err != nil {
+		log.Fatalf("Could not get the result from %s: %v", *server, err)
+	}
+	if outcome.HighestBid != summary.HighestBid || outcome.Winner != summary.Winner() {
+		if outcome.HighestBid == summary.HighestBid && *user == "" && strings.HasPrefix(outcome.Winner, "bidder-") {
+			log.Fatalf("%s shows the winner as the pseudonym %s, log in as the seller or an admin with -user to compare the real name", *server, outcome.Winner)
+		}
+		log.Fatalf("The audit log does NOT match %s, which reports the highest bid %d and the winner %q", *server, outcome.HighestBid, outcome.Winner)
+	}
+	fmt.Printf("The audit log matches the result of %s\n", *server)
+}
+
+func runTrace(args []string) {
+	flags := flag.NewFlagSet("trace", flag.ExitOnError)
+	traceId := flags.String("id", "", "only show the trace with the id, or the traces whose id starts with it")
+	bidder := flags.String("bidder", "", "only show the traces with a span of the bidder")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: auctionctl trace [-id trace] [-bidder name] spans.jsonl...\n"+
+			"The files are the -trace file of the clients and the spans-N.jsonl files of the servers.\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	//The spans of a trace are spread over the files of all processes that handled the request
+	var records []tracing.Record
+	for _, path := range flags.Args() {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fileRecords, err := tracing.ReadRecords(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", path, err)
+		}
+		records = append(records, fileRecords...)
+	}
+	tracing.SortRecords(records)
+
+	var traceIds []string
+	traces := make(map[string][]tracing.Record)
+	for _, record := range records {
+		if !strings.HasPrefix(record.TraceId, *traceId) {
+			continue
+		}
+		if _, ok := traces[record.TraceId]; !ok {
+			traceIds = append(traceIds, record.TraceId)
+		}
+		traces[record.TraceId] = append(traces[record.TraceId], record)
+	}
+	for _, id := range traceIds {
+		if *bidder != "" && !hasBidder(traces[id], *bidder) {
+			continue
+		}
+		fmt.Printf("trace %s\n", id)
+		printSpans(traces[id])
+		fmt.Println()
+	}
+}
+
+func hasBidder(records []tracing.Record, bidder string) bool {
+	for _, record := range records {
+		if record.Attributes["bidder"] == bidder {
+			return true
+		}
+	}
+	return false
+}
+
+// Prints the spans of a trace as a tree, every span below its parent
+// Spans whose parent isn't in the files, fx because the client wasn't traced, are printed at the top
+func printSpans(records []tracing.Record) {
+	children := make(map[string][]tracing.Record)
+	spanIds := make(map[string]bool)
+	for _, record := range records {
+		spanIds[record.SpanId] = true
+	}
+	for _, record := range records {
+		parent := record.ParentSpanId
+		if !spanIds[parent] {
+			parent = ""
+		}
+		children[parent] = append(children[parent], record)
+	}
+	var printTree func(parent string, depth int)
+	printTree = func(parent string, depth int) {
+		for _, record := range children[parent] {
+			line := fmt.Sprintf("%s %s%s [%s] %v", record.Start.Format("15:04:05.000"), strings.Repeat("  ", depth), record.Name, record.Service, record.Duration().Round(time.Microsecond))
+			keys := make([]string, 0, len(record.Attributes))
+			for key := range record.Attributes {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				line += fmt.Sprintf(" %s=%q", key, record.Attributes[key])
+			}
+			if record.Error != "" {
+				line += fmt.Sprintf(" error=%q", record.Error)
+			}
+			fmt.Println(line)
+			printTree(record.SpanId, depth+1)
+		}
+	}
+	printTree("", 0)
+}
+
+// Reads a receipt from the file or the console, either on its own or in an acknowledgement
+func readReceipt(path string) (*proto.Receipt, error) {
+	var data []byte
+	var err error
+	if path == "" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ack := &proto.Acknowledgement{}
+	if err := protojson.Unmarshal(data, ack); err == nil && ack.Receipt != nil {
+		return ack.Receipt, nil
+	}
+	receipt := &proto.Receipt{}
+	if err := protojson.Unmarshal(data, receipt); err != nil {
+		return nil, fmt.Errorf("the file is not a receipt: %w", err)
+	}
+	return receipt, nil
+}
+
+// Writes to the file with permissions only for the owner, or to the console if the path is empty
+func writeOutput(path string, data []byte) {
+	if path == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		log.Fatal(err)
+	}
+}
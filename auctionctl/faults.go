@@ -0,0 +1,159 @@
+// The command that changes the fault injection of the servers
+
+package main
+
+import (
+	"Auction/auth"
+	"Auction/config"
+	"Auction/faults"
+	"Auction/frontend"
+	proto "Auction/grpc"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func runFaults(args []string) {
+	flags := flag.NewFlagSet("faults", flag.ExitOnError)
+	cluster := addClusterFlags(flags)
+	user := flags.String("user", "", "log in as the admin, with -password or $AUCTION_PASSWORD")
+	password := flags.String("password", os.Getenv("AUCTION_PASSWORD"), "the password of -user")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: auctionctl faults [-servers addresses] [-user admin] list | set rules.json | clear\n"+
+			"The servers must have a faults section in their configuration. The file is a faults section, fx\n"+
+			"  {\"rules\": [{\"method\": \"Auction/Bid\", \"dropResponse\": true, \"probability\": 0.5}]}\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	addresses, dialOptions, err := cluster.resolve()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	//Every server accepts the token, so the admin logs in once
+	var token string
+	if *user != "" {
+		auctionClient, err := frontend.New(frontend.WithDiscovery(frontend.StaticDiscovery(addresses)), frontend.WithDialOptions(dialOptions...))
+		if err != nil {
+			log.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+		loginToken, err := auctionClient.Login(ctx, *user, *password)
+		cancel()
+		auctionClient.Close()
+		if err != nil {
+			log.Fatalf("Could not log in: %v", err)
+		}
+		token = loginToken.Token
+	}
+	withToken := func(ctx context.Context) context.Context {
+		if token == "" {
+			return ctx
+		}
+		return auth.WithToken(ctx, token)
+	}
+
+	var call func(context.Context, proto.AdminClient) (*proto.FaultRules, error)
+	switch command := flags.Arg(0); command {
+	case "list":
+		call = func(ctx context.Context, admin proto.AdminClient) (*proto.FaultRules, error) {
+			return admin.GetFaults(withToken(ctx), &proto.Empty{})
+		}
+	case "set", "clear":
+		rules := &config.Faults{}
+		if command == "set" {
+			if flags.NArg() != 2 {
+				flags.Usage()
+				os.Exit(2)
+			}
+			//The servers check the rules too, but a mistake is found before any server has changed its rules
+			injector, err := loadFaults(flags.Arg(1))
+			if err != nil {
+				log.Fatal(err)
+			}
+			rules.Rules = injector.Rules()
+		}
+		call = func(ctx context.Context, admin proto.AdminClient) (*proto.FaultRules, error) {
+			return admin.SetFaults(withToken(ctx), faults.ToProto(rules.Rules))
+		}
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	results, errs := callAdmins(addresses, dialOptions, call)
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "SERVER\tMETHOD\tPEER\tPROBABILITY\tLEFT\tFAULT")
+	failed := false
+	for i, address := range addresses {
+		if errs[i] != nil {
+			failed = true
+			fmt.Fprintf(writer, "%s\t-\t\t\t\t%v\n", address, errs[i])
+			continue
+		}
+		if len(results[i].Rules) == 0 {
+			fmt.Fprintf(writer, "%s\t-\t\t\t\tno faults\n", address)
+		}
+		for _, rule := range faults.FromProto(results[i]) {
+			peer, probability, left := "any", "always", "no limit"
+			if rule.Peer != "" {
+				peer = rule.Peer
+			}
+			if rule.Probability > 0 {
+				probability = fmt.Sprint(rule.Probability)
+			}
+			if rule.Count > 0 {
+				left = fmt.Sprint(rule.Count)
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", address, rule.Method, peer, probability, left, describeFault(rule))
+		}
+	}
+	writer.Flush()
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// Reads a faults section from the file
+func loadFaults(path string) (*faults.Injector, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules config.Faults
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	return faults.New(rules)
+}
+
+// The faults of a rule, fx "latency 200ms, code UNAVAILABLE"
+func describeFault(rule config.FaultRule) string {
+	var parts []string
+	if rule.Latency > 0 {
+		parts = append(parts, "latency "+time.Duration(rule.Latency).String())
+	}
+	if rule.Code != "" {
+		parts = append(parts, "code "+rule.Code)
+	}
+	if rule.DropResponse {
+		parts = append(parts, "drop response")
+	}
+	if rule.Crash != "" {
+		parts = append(parts, "crash "+rule.Crash)
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
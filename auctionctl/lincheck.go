@@ -0,0 +1,168 @@
+// The command that checks the consistency of the cluster
+
+package main
+
+import (
+	"Auction/frontend"
+	"Auction/linearizability"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+func runLincheck(args []string) {
+	flags := flag.NewFlagSet("lincheck", flag.ExitOnError)
+	cluster := addClusterFlags(flags)
+	clients := flags.Int("clients", 5, "the number of concurrent clients, each with a frontend of its own")
+	shared := flags.Bool("shared-frontend", false, "let all clients use one frontend, which sends the bids to the servers one at a time")
+	duration := flags.Duration("duration", 10*time.Second, "how long the clients bid and read results")
+	reads := flags.Float64("reads", 0.3, "the share of the operations that read the result instead of bidding")
+	think := flags.Duration("think", 10*time.Millisecond, "the longest pause of a client between two operations")
+	historyFile := flags.String("history", "", "check the history in the file instead of recording one")
+	out := flags.String("out", "", "write the recorded history to the file, to check it again with -history")
+	timeout := flags.Duration("timeout", time.Minute, "give up the check after the time")
+	faultsFile := flags.String("faults", "", "inject the faults in the file, a faults section like in the configuration, into the calls of the clients")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: auctionctl lincheck [-clients n] [-duration time] [-out file] [-servers addresses]\n"+
+			"       auctionctl lincheck -history file\n"+
+			"Records the bids and results of concurrent clients and checks that they could have happened one at a time.\n"+
+			"Stop and start servers while it runs to check the crash handling. The auction must be plain and new,\n"+
+			"without sealed bids, accounts, bans or pseudonyms, and must not end before the clients stop.\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	var history []linearizability.AuctionOperation
+	if *historyFile != "" {
+		file, err := os.Open(*historyFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		history, err = linearizability.ReadHistory(file)
+		file.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		addresses, dialOptions, err := cluster.resolve()
+		if err != nil {
+			log.Fatal(err)
+		}
+		frontendOptions := []frontend.Option{frontend.WithDiscovery(frontend.StaticDiscovery(addresses)), frontend.WithDialOptions(dialOptions...)}
+		if *faultsFile != "" {
+			injector, err := loadFaults(*faultsFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			frontendOptions = append(frontendOptions, frontend.WithFaults(injector))
+		}
+		history = recordHistory(frontendOptions, *clients, *shared, *duration, *reads, *think)
+		if *out != "" {
+			file, err := os.Create(*out)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := linearizability.WriteHistory(file, history); err != nil {
+				log.Fatal(err)
+			}
+			file.Close()
+		}
+	}
+	printHistorySummary(history)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	result, err := linearizability.Check(ctx, linearizability.AuctionModel, history)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if result.Ok {
+		fmt.Println("The history is linearizable")
+		return
+	}
+	fmt.Println("The history is NOT linearizable")
+	//The end of the longest order that worked, and the operations that couldn't come after it
+	start := max(0, len(result.Order)-10)
+	fmt.Printf("\nThe last of the %d operations that could be ordered:\n", len(result.Order))
+	for _, i := range result.Order[start:] {
+		fmt.Println("  " + linearizability.Describe(history[i]))
+	}
+	fmt.Println("\nNone of these operations can come next:")
+	for _, i := range result.Stuck {
+		fmt.Println("  " + linearizability.Describe(history[i]))
+	}
+	os.Exit(1)
+}
+
+// Lets the clients bid and read results until the time is up
+func recordHistory(frontendOptions []frontend.Option, clients int, shared bool, duration time.Duration, reads float64, think time.Duration) []linearizability.AuctionOperation {
+	recorder := linearizability.NewRecorder()
+	deadline := time.Now().Add(duration)
+	newFrontend := func() *frontend.AuctionClient {
+		auctionClient, err := frontend.New(frontendOptions...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return auctionClient
+	}
+	var sharedClient *frontend.AuctionClient
+	if shared {
+		sharedClient = newFrontend()
+		defer sharedClient.Close()
+	}
+	var wait sync.WaitGroup
+	for client := 0; client < clients; client++ {
+		auctionClient := sharedClient
+		if auctionClient == nil {
+			auctionClient = newFrontend()
+			defer auctionClient.Close()
+		}
+		wait.Add(1)
+		go func(client int, auctionClient *frontend.AuctionClient) {
+			defer wait.Done()
+			random := rand.New(rand.NewSource(time.Now().UnixNano() + int64(client)))
+			bidder := fmt.Sprintf("lincheck-%d", client)
+			//The clients bid a little above the highest bid they have seen, so some bids are too low
+			var highest int32
+			for time.Now().Before(deadline) {
+				ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+				if random.Float64() < reads {
+					if outcome, err := recorder.Result(ctx, client, auctionClient); err == nil {
+						highest = outcome.HighestBid
+					}
+				} else {
+					amount := highest + int32(random.Intn(5)) + 1
+					if ack, err := recorder.Bid(ctx, client, auctionClient, bidder, amount); err == nil && ack.Status == "success" {
+						highest = amount
+					}
+				}
+				cancel()
+				if think > 0 {
+					time.Sleep(time.Duration(random.Int63n(int64(think))))
+				}
+			}
+		}(client, auctionClient)
+	}
+	wait.Wait()
+	return recorder.History()
+}
+
+func printHistorySummary(history []linearizability.AuctionOperation) {
+	bids, results, unknown := 0, 0, 0
+	for _, operation := range history {
+		switch {
+		case operation.Output.Unknown:
+			unknown++
+		case operation.Input.Kind == linearizability.KindBid:
+			bids++
+		default:
+			results++
+		}
+	}
+	fmt.Printf("%d operations: %d bids, %d results and %d bids whose outcome is unknown\n", len(history), bids, results, unknown)
+}
@@ -0,0 +1,50 @@
+// The command that runs the scenarios of the harness package
+
+package main
+
+import (
+	"Auction/harness"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"time"
+)
+
+func runSimulate(args []string) {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	run := flags.String("run", "", "only run the scenarios whose names match the regular expression")
+	list := flags.Bool("list", false, "list the scenarios instead of running them")
+	timeout := flags.Duration("timeout", time.Minute, "fail a scenario that takes longer")
+	flags.Parse(args)
+	pattern, err := regexp.Compile(*run)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	failed := 0
+	for _, scenario := range harness.Scenarios {
+		if !pattern.MatchString(scenario.Name) {
+			continue
+		}
+		if *list {
+			fmt.Printf("%-26s %s\n", scenario.Name, scenario.Description)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		start := time.Now()
+		err := scenario.Execute(ctx)
+		cancel()
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %s (%s)\n     %v\n", scenario.Name, time.Since(start).Round(time.Millisecond), err)
+			continue
+		}
+		fmt.Printf("ok   %s (%s)\n", scenario.Name, time.Since(start).Round(time.Millisecond))
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
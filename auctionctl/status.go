@@ -0,0 +1,248 @@
+// The commands that ask the Admin service of the servers
+
+package main
+
+import (
+	"Auction/config"
+	"Auction/divergence"
+	"Auction/frontend"
+	proto "Auction/grpc"
+	"Auction/tlsconfig"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// How long a server may take to answer an admin command
+const adminTimeout = 5 * time.Second
+
+// The flags that choose the servers of an admin command
+type clusterFlags struct {
+	servers    *string
+	configPath *string
+	caFile     *string
+}
+
+func addClusterFlags(flags *flag.FlagSet) *clusterFlags {
+	return &clusterFlags{
+		servers:    flags.String("servers", "", "comma separated grpc addresses of the servers, the default is the cluster section of -config or localhost:5000-5002"),
+		configPath: flags.String("config", "", "read the servers from the cluster section of the JSON configuration file"),
+		caFile:     flags.String("ca", "", "connect with TLS, trusting the certificate authority in the file"),
+	}
+}
+
+// The addresses of the servers and the options to dial them
+func (cluster *clusterFlags) resolve() ([]string, []grpc.DialOption, error) {
+	addresses := []string(frontend.DefaultReplicas)
+	if *cluster.configPath != "" {
+		clusterConfig, err := config.Load(*cluster.configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		if clusterConfig.Cluster != nil && len(clusterConfig.Cluster.Replicas) > 0 {
+			addresses = clusterConfig.Cluster.Replicas
+		}
+	}
+	if *cluster.servers != "" {
+		addresses = strings.Split(*cluster.servers, ",")
+	}
+	dialOptions := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if *cluster.caFile != "" {
+		tlsConfig, err := tlsconfig.ClientConfig(*cluster.caFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		dialOptions = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+	}
+	return addresses, dialOptions, nil
+}
+
+// Calls the Admin service of every server at the same time, and returns the answers in the order of the addresses
+func callAdmins[T any](addresses []string, dialOptions []grpc.DialOption, call func(context.Context, proto.AdminClient) (T, error)) ([]T, []error) {
+	results := make([]T, len(addresses))
+	errs := make([]error, len(addresses))
+	var wait sync.WaitGroup
+	for i, address := range addresses {
+		wait.Add(1)
+		go func(i int, address string) {
+			defer wait.Done()
+			conn, err := grpc.Dial(address, dialOptions...)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer conn.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+			defer cancel()
+			results[i], errs[i] = call(ctx, proto.NewAdminClient(conn))
+		}(i, address)
+	}
+	wait.Wait()
+	return results, errs
+}
+
+func runStatus(args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	cluster := addClusterFlags(flags)
+	flags.Parse(args)
+	addresses, dialOptions, err := cluster.resolve()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	statuses, errs := callAdmins(addresses, dialOptions, func(ctx context.Context, admin proto.AdminClient) (*proto.NodeStatus, error) {
+		return admin.Status(ctx, &proto.Empty{})
+	})
+	//The servers that answered should agree, a value that differs from what most of them say is marked with a *
+	var answered []*proto.NodeStatus
+	for i, nodeStatus := range statuses {
+		if errs[i] == nil {
+			answered = append(answered, nodeStatus)
+		}
+	}
+	checksum := majority(answered, func(nodeStatus *proto.NodeStatus) string { return nodeStatus.Checksum })
+	applied := majority(answered, func(nodeStatus *proto.NodeStatus) string { return fmt.Sprint(nodeStatus.LastApplied) })
+	leaders := majority(answered, func(nodeStatus *proto.NodeStatus) string { return leaderOf(nodeStatus) })
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "SERVER\tID\tROLE\tAPPLIED\tBIDS\tCONNECTIONS\tWATCHERS\tCHECKSUM\tMEMBERS UP\tSTARTED")
+	diverged := false
+	for i, address := range addresses {
+		if errs[i] != nil {
+			diverged = true
+			fmt.Fprintf(writer, "%s\t-\tdown\t\t\t\t\t\t\t%v\n", address, errs[i])
+			continue
+		}
+		nodeStatus := statuses[i]
+		mark := func(value, expected string) string {
+			if value != expected {
+				diverged = true
+				return value + " *"
+			}
+			return value
+		}
+		//A server that sees another leader than most servers has another view of the members
+		members := membersUp(nodeStatus)
+		if leaderOf(nodeStatus) != leaders {
+			diverged = true
+			members += " *"
+		}
+		started := "-"
+		if nodeStatus.StartedAt != 0 {
+			started = time.UnixMilli(nodeStatus.StartedAt).Format(time.TimeOnly)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n", address, nodeStatus.Id, nodeStatus.Role,
+			mark(fmt.Sprint(nodeStatus.LastApplied), applied), nodeStatus.Bids, nodeStatus.Connections, nodeStatus.Watchers,
+			mark(shortChecksum(nodeStatus.Checksum), shortChecksum(checksum)), members, started)
+	}
+	writer.Flush()
+	if diverged {
+		fmt.Println("\nThe servers marked with * or down don't agree with the majority")
+		os.Exit(1)
+	}
+}
+
+func runDiverge(args []string) {
+	flags := flag.NewFlagSet("diverge", flag.ExitOnError)
+	cluster := addClusterFlags(flags)
+	settle := flags.Duration("settle", time.Second, "how long to wait before checking the differences again, so bids that were on their way are left out")
+	watch := flags.Duration("watch", 0, "check again at the interval until interrupted, instead of once")
+	flags.Parse(args)
+	addresses, dialOptions, err := cluster.resolve()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	check := func() *proto.DivergenceReport {
+		digests, errs := callAdmins(addresses, dialOptions, func(ctx context.Context, admin proto.AdminClient) (*proto.StateDigest, error) {
+			return admin.GetDigest(ctx, &proto.Empty{})
+		})
+		return divergence.Check(addresses, digests, errs)
+	}
+	for {
+		report := check()
+		//A bid that was applied on some of the servers but not yet on the others isn't a divergence
+		if len(report.Differences) > 0 {
+			time.Sleep(*settle)
+			report = divergence.Persistent(report, check())
+		}
+		ok := printDivergence(report, len(addresses))
+		if *watch <= 0 {
+			if !ok {
+				os.Exit(1)
+			}
+			return
+		}
+		time.Sleep(*watch)
+		fmt.Println()
+	}
+}
+
+// Prints the differences of the report, and returns whether all servers answered and agree
+func printDivergence(report *proto.DivergenceReport, servers int) bool {
+	fmt.Printf("Checked at %s\n", time.UnixMilli(report.CheckedAt).Format(time.TimeOnly))
+	for _, address := range report.Unreachable {
+		fmt.Printf("%s is down, its state wasn't compared\n", address)
+	}
+	if len(report.Differences) == 0 {
+		fmt.Printf("The %d servers that answered agree\n", servers-len(report.Unreachable))
+		return len(report.Unreachable) == 0
+	}
+	fmt.Printf("The servers differ in %d items:\n", len(report.Differences))
+	for _, difference := range report.Differences {
+		fmt.Println("  " + divergence.Format(difference))
+	}
+	return false
+}
+
+// The value that most of the servers have, of equally common values the first one
+func majority(statuses []*proto.NodeStatus, value func(*proto.NodeStatus) string) string {
+	counts := make(map[string]int)
+	best := ""
+	for _, nodeStatus := range statuses {
+		v := value(nodeStatus)
+		counts[v]++
+		if counts[v] > counts[best] {
+			best = v
+		}
+	}
+	return best
+}
+
+// The leader in the view of a server, the first member that is up
+func leaderOf(nodeStatus *proto.NodeStatus) string {
+	for _, member := range nodeStatus.Members {
+		if member.Up {
+			return member.Address
+		}
+	}
+	return ""
+}
+
+// The members a server sees as up, fx "2/3"
+func membersUp(nodeStatus *proto.NodeStatus) string {
+	up := 0
+	for _, member := range nodeStatus.Members {
+		if member.Up {
+			up++
+		}
+	}
+	return fmt.Sprintf("%d/%d", up, len(nodeStatus.Members))
+}
+
+func shortChecksum(checksum string) string {
+	if len(checksum) > 12 {
+		return checksum[:12]
+	}
+	return checksum
+}
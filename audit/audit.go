@@ -0,0 +1,211 @@
+// Package audit keeps a tamper-evident log of the state changes of a replication manager.
+//
+// The log is a file with one JSON entry per line. Every entry has the hash of the entry before it,
+// so changing, removing or inserting an entry afterwards breaks the chain, which Verify detects.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// The events in the log
+const (
+	Started     = "started"
+	Opened      = "opened"
+	BidAccepted = "bid-accepted"
+	BidRejected = "bid-rejected"
+	Closed      = "closed"
+	Cancelled   = "cancelled"
+	Banned      = "banned"
+	Unbanned    = "unbanned"
+	Committed   = "committed"
+	Revealing   = "revealing"
+	Revealed    = "revealed"
+	Penalized   = "penalized"
+)
+
+// Entry is a state change. For closed auctions the bidder and amount are the winner and the winning bid,
+// and for rejected bids the detail is the reason.
+type Entry struct {
+	Sequence uint64 `json:"seq"`
+	Time     int64  `json:"time"`
+	Event    string `json:"event"`
+	Auction  string `json:"auction,omitempty"`
+	Bidder   string `json:"bidder,omitempty"`
+	Amount   int32  `json:"amount,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	PrevHash string `json:"prevHash"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// The hash of the entry is the SHA-256 of its JSON encoding without the hash
+func (entry Entry) computeHash() string {
+	entry.Hash = ""
+	data, _ := json.Marshal(entry)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Log appends entries to the audit log file, it is safe for concurrent use.
+type Log struct {
+	mutex    sync.Mutex
+	file     *os.File
+	sequence uint64
+	lastHash string
+	err      error
+}
+
+// Open opens the log file, and continues the chain of the entries already in it.
+func Open(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	auditLog := &Log{file: file}
+	//Only the last entry is needed to continue the chain, the chain itself is checked by Verify
+	scanner := newScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s is not an audit log: %w", path, err)
+		}
+		auditLog.sequence = entry.Sequence
+		auditLog.lastHash = entry.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return auditLog, nil
+}
+
+// Append adds the entry to the chain and writes it to the file.
+// If a write fails, the error is logged and no more entries are written, so the entries in the file stay a valid chain.
+func (auditLog *Log) Append(entry Entry) {
+	auditLog.mutex.Lock()
+	defer auditLog.mutex.Unlock()
+
+	if auditLog.err != nil {
+		return
+	}
+	entry.Sequence = auditLog.sequence + 1
+	entry.PrevHash = auditLog.lastHash
+	entry.Hash = entry.computeHash()
+	data, _ := json.Marshal(entry)
+	if _, err := auditLog.file.Write(append(data, '\n')); err != nil {
+		auditLog.err = err
+		slog.Error("Could not write the audit log, no more entries are written", "err", err)
+		return
+	}
+	auditLog.sequence = entry.Sequence
+	auditLog.lastHash = entry.Hash
+}
+
+// Err returns the error that stopped the log, or nil.
+func (auditLog *Log) Err() error {
+	auditLog.mutex.Lock()
+	defer auditLog.mutex.Unlock()
+	return auditLog.err
+}
+
+// Close closes the log file.
+func (auditLog *Log) Close() error {
+	return auditLog.file.Close()
+}
+
+// Summary is the state of the auction after replaying the log.
+// A started entry resets it, since the replication manager starts with an empty auction.
+type Summary struct {
+	Entries     int
+	LastHash    string
+	HighestBid  int32
+	Bidder      string
+	IsOver      bool
+	IsCancelled bool
+}
+
+// Winner returns the winner of the auction, or "" if it isn't over or was cancelled.
+func (summary *Summary) Winner() string {
+	if !summary.IsOver || summary.IsCancelled {
+		return ""
+	}
+	return summary.Bidder
+}
+
+// ErrBrokenChain is returned by Verify when an entry was changed, removed or inserted.
+var ErrBrokenChain = errors.New("the hash chain is broken")
+
+// Verify checks the chain of the log and replays the entries, and returns the state of the auction at the end.
+func Verify(reader io.Reader) (*Summary, error) {
+	summary := &Summary{}
+	var lastHash string
+	var sequence uint64
+	scanner := newScanner(reader)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", sequence+1, err)
+		}
+		sequence++
+		switch {
+		case entry.Sequence != sequence:
+			return nil, fmt.Errorf("%w: expected entry %d, found entry %d", ErrBrokenChain, sequence, entry.Sequence)
+		case entry.PrevHash != lastHash:
+			return nil, fmt.Errorf("%w: entry %d doesn't follow the entry before it", ErrBrokenChain, sequence)
+		case entry.Hash != entry.computeHash():
+			return nil, fmt.Errorf("%w: entry %d was changed", ErrBrokenChain, sequence)
+		}
+		lastHash = entry.Hash
+		summary.LastHash = lastHash
+		if err := summary.apply(entry); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", sequence, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// Replays an entry like the replication manager applied it
+func (summary *Summary) apply(entry Entry) error {
+	summary.Entries++
+	switch entry.Event {
+	case Started:
+		*summary = Summary{Entries: summary.Entries, LastHash: summary.LastHash}
+	case BidAccepted:
+		if summary.IsOver {
+			return fmt.Errorf("a bid was accepted after the auction was over")
+		}
+		if entry.Amount < summary.HighestBid {
+			return fmt.Errorf("a bid of %d was accepted below the highest bid of %d", entry.Amount, summary.HighestBid)
+		}
+		summary.HighestBid = entry.Amount
+		summary.Bidder = entry.Bidder
+	case Closed:
+		if entry.Bidder != summary.Bidder || entry.Amount != summary.HighestBid {
+			return fmt.Errorf("the winner %s with %d is not the highest bidder %s with %d", entry.Bidder, entry.Amount, summary.Bidder, summary.HighestBid)
+		}
+		summary.IsOver = true
+	case Cancelled:
+		summary.IsOver = true
+		summary.IsCancelled = true
+	}
+	return nil
+}
+
+func newScanner(reader io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return scanner
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// The metadata key of the token, the value is "Bearer <token>"
+const authorizationKey = "authorization"
+
+type claimsKey struct{}
+
+// ClaimsFromContext returns the claims of the verified token of the caller, or nil if the caller sent no token.
+func ClaimsFromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(claimsKey{}).(*Claims)
+	return claims
+}
+
+// ContextWithClaims returns a context with the claims, for requests that are verified outside of the interceptors.
+func ContextWithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// WithToken returns a context that sends the token with outgoing grpc calls.
+func WithToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, authorizationKey, "Bearer "+token)
+}
+
+// ForwardToken returns a context that sends the token of the incoming grpc call with outgoing calls,
+// so a server that calls other servers for its caller is allowed what the caller is allowed.
+func ForwardToken(ctx context.Context) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(authorizationKey); len(values) > 0 {
+			return metadata.AppendToOutgoingContext(ctx, authorizationKey, values[0])
+		}
+	}
+	return ctx
+}
+
+// TokenFromHeader returns the token of an HTTP Authorization header, or "" if it isn't a bearer token.
+func TokenFromHeader(header string) string {
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// UnaryServerInterceptor verifies the token of every call and stores its claims in the context.
+// Calls without a token are let through without claims, what they may do is decided by the authz package.
+func (authenticator *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticator.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, request)
+	}
+}
+
+// StreamServerInterceptor verifies the token of every stream and stores its claims in the context of the stream.
+func (authenticator *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(server any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticator.authenticate(stream.Context())
+		if err != nil {
+			return err
+		}
+		return handler(server, &contextStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// A token that is sent must always be valid
+func (authenticator *Authenticator) authenticate(ctx context.Context) (context.Context, error) {
+	token := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(authorizationKey); len(values) > 0 {
+			token = TokenFromHeader(values[0])
+			if token == "" {
+				return nil, status.Error(codes.Unauthenticated, "authorization must be a bearer token")
+			}
+		}
+	}
+	if token == "" {
+		return ctx, nil
+	}
+
+	claims, err := authenticator.Verify(token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return ContextWithClaims(ctx, claims), nil
+}
+
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *contextStream) Context() context.Context {
+	return stream.ctx
+}
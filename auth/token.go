@@ -0,0 +1,194 @@
+// Package auth issues and verifies the signed tokens that identify bidders.
+//
+// Tokens are JSON Web Tokens signed with HMAC-SHA256 (HS256) or Ed25519 (EdDSA). Every replication manager
+// is configured with the same key, so a token issued by one of them is accepted by all of them.
+package auth
+
+import (
+	"Auction/config"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultRole is the role of users without a role in the configuration
+const DefaultRole = "bidder"
+
+var (
+	ErrInvalidLogin = errors.New("unknown bidder or wrong password")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("token has expired")
+)
+
+// Claims are the contents of a token.
+type Claims struct {
+	Subject   string `json:"sub"`
+	Role      string `json:"role,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+}
+
+// Authenticator checks passwords and issues and verifies tokens.
+type Authenticator struct {
+	algorithm string
+	hmacKey   []byte
+	ed25519   ed25519.PrivateKey
+	ttl       time.Duration
+	users     map[string]config.User
+	now       func() time.Time
+}
+
+// New creates an Authenticator from the auth section of the configuration.
+func New(authConfig config.Auth) (*Authenticator, error) {
+	authenticator := &Authenticator{
+		algorithm: authConfig.Algorithm,
+		ttl:       time.Duration(authConfig.TokenTtl),
+		users:     authConfig.Users,
+		now:       time.Now,
+	}
+	if authenticator.ttl == 0 {
+		authenticator.ttl = time.Hour
+	}
+
+	key, err := os.ReadFile(authConfig.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the key: %w", err)
+	}
+	switch authConfig.Algorithm {
+	case "HS256":
+		authenticator.hmacKey = []byte(strings.TrimSpace(string(key)))
+		if len(authenticator.hmacKey) < 32 {
+			return nil, errors.New("the HS256 key must be at least 32 bytes")
+		}
+	case "EdDSA":
+		authenticator.ed25519, err = ParseEd25519PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown algorithm %q, must be HS256 or EdDSA", authConfig.Algorithm)
+	}
+	return authenticator, nil
+}
+
+// Login checks the password of the user and issues a token for them, with the role of the user.
+func (authenticator *Authenticator) Login(user string, password string) (string, *Claims, error) {
+	userConfig, ok := authenticator.users[user]
+	if !ok || !CheckPassword(userConfig.PasswordHash, password) {
+		return "", nil, ErrInvalidLogin
+	}
+	role := userConfig.Role
+	if role == "" {
+		role = DefaultRole
+	}
+	return authenticator.Issue(user, role)
+}
+
+// Issue creates a signed token for the subject with the role.
+func (authenticator *Authenticator) Issue(subject string, role string) (string, *Claims, error) {
+	now := authenticator.now()
+	claims := &Claims{
+		Subject:   subject,
+		Role:      role,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(authenticator.ttl).Unix(),
+	}
+	headerJson, _ := json.Marshal(header{Algorithm: authenticator.algorithm, Type: "JWT"})
+	claimsJson, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	signingInput := encodeSegment(headerJson) + "." + encodeSegment(claimsJson)
+	return signingInput + "." + encodeSegment(authenticator.sign([]byte(signingInput))), claims, nil
+}
+
+// Verify checks the signature and expiry of the token and returns its claims.
+func (authenticator *Authenticator) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	headerJson, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var tokenHeader header
+	//The algorithm of the token must be the configured one, so a token can't pick a weaker one
+	if json.Unmarshal(headerJson, &tokenHeader) != nil || tokenHeader.Algorithm != authenticator.algorithm {
+		return nil, ErrInvalidToken
+	}
+	signature, err := decodeSegment(parts[2])
+	if err != nil || !authenticator.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return nil, ErrInvalidToken
+	}
+	claimsJson, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	claims := &Claims{}
+	if json.Unmarshal(claimsJson, claims) != nil || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	if claims.Role == "" {
+		claims.Role = DefaultRole
+	}
+	if authenticator.now().Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+	return claims, nil
+}
+
+func (authenticator *Authenticator) sign(signingInput []byte) []byte {
+	if authenticator.algorithm == "EdDSA" {
+		return ed25519.Sign(authenticator.ed25519, signingInput)
+	}
+	mac := hmac.New(sha256.New, authenticator.hmacKey)
+	mac.Write(signingInput)
+	return mac.Sum(nil)
+}
+
+func (authenticator *Authenticator) verify(signingInput []byte, signature []byte) bool {
+	if authenticator.algorithm == "EdDSA" {
+		return ed25519.Verify(authenticator.ed25519.Public().(ed25519.PublicKey), signingInput, signature)
+	}
+	return hmac.Equal(authenticator.sign(signingInput), signature)
+}
+
+// ParseEd25519PrivateKey parses a PEM encoded PKCS #8 Ed25519 private key, as written by `auctionctl gen-key`.
+func ParseEd25519PrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("the key is not PEM encoded")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("the key is not an Ed25519 key")
+	}
+	return privateKey, nil
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(segment)
+}
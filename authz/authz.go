@@ -0,0 +1,138 @@
+// Package authz decides which roles may call which grpc methods.
+//
+// The role of a caller is the role in their token, or the anonymous role if they sent no token.
+// Methods are written as "Service/Method", fx "Auction/Bid", and a policy may use "Service/*" or "*" for all methods.
+package authz
+
+import (
+	"Auction/auth"
+	"Auction/config"
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The roles of the default policy
+const (
+	Admin    = "admin"
+	Seller   = "seller"
+	Bidder   = "bidder"
+	Observer = "observer"
+)
+
+// Login can always be called, since it is how a caller gets a role
+const loginMethod = "Auction/Login"
+
+var observerMethods = []string{"Auction/GetResult", "Auction/GetSignedResult", "Auction/ListAuctions", "Auction/GetBidHistory", "Auction/Watch", "Admin/Status", "Admin/GetDigest", "Admin/CheckDivergence"}
+
+// DefaultRoles is the policy used when the configuration has no roles.
+// Admins may call everything, sellers may also close the auction, bidders may also bid and see their account,
+// and observers may only read the results, streams, the status of the servers and whether they diverged.
+// The servers show the bidders in the digests to observers as they show them in the results, as pseudonyms when they are on.
+var DefaultRoles = map[string][]string{
+	Admin:    {"*"},
+	Seller:   append([]string{"Auction/CloseAuction"}, observerMethods...),
+	Bidder:   append([]string{"Auction/Bid", "Auction/CommitBid", "Auction/RevealBid", "Auction/GetAccount"}, observerMethods...),
+	Observer: observerMethods,
+}
+
+// Policy maps roles to the methods they may call.
+type Policy struct {
+	anonymousRole string
+	roles         map[string][]string
+}
+
+// NewPolicy creates the policy from the authorization section of the configuration.
+func NewPolicy(authorization config.Authorization) (*Policy, error) {
+	policy := &Policy{anonymousRole: Observer, roles: DefaultRoles}
+	if authorization.AnonymousRole != nil {
+		policy.anonymousRole = *authorization.AnonymousRole
+	}
+	if len(authorization.Roles) > 0 {
+		policy.roles = authorization.Roles
+	}
+	for role, methods := range policy.roles {
+		for _, method := range methods {
+			if method != "*" && strings.Count(method, "/") != 1 {
+				return nil, fmt.Errorf("method %q of role %s must be written as Service/Method", method, role)
+			}
+		}
+	}
+	if _, ok := policy.roles[policy.anonymousRole]; policy.anonymousRole != "" && !ok {
+		return nil, fmt.Errorf("the anonymous role %q is not in the roles", policy.anonymousRole)
+	}
+	return policy, nil
+}
+
+// RoleFromContext returns the role of the caller, or "" if the caller has no role.
+func (policy *Policy) RoleFromContext(ctx context.Context) string {
+	if claims := auth.ClaimsFromContext(ctx); claims != nil {
+		return claims.Role
+	}
+	return policy.anonymousRole
+}
+
+// Allowed tells whether the role may call the method, written as "Service/Method".
+func (policy *Policy) Allowed(role string, method string) bool {
+	if method == loginMethod {
+		return true
+	}
+	service, _, _ := strings.Cut(method, "/")
+	for _, allowed := range policy.roles[role] {
+		if allowed == "*" || allowed == method || allowed == service+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an error with the grpc status for a caller that may not call the method.
+// The method is either "Service/Method" or a full grpc method name like "/Auction.Auction/Bid".
+func (policy *Policy) Check(ctx context.Context, method string) error {
+	method = ShortMethod(method)
+	role := policy.RoleFromContext(ctx)
+	if policy.Allowed(role, method) {
+		return nil
+	}
+	if auth.ClaimsFromContext(ctx) == nil {
+		return status.Errorf(codes.Unauthenticated, "%s needs a token, log in first", method)
+	}
+	return status.Errorf(codes.PermissionDenied, "the role %s may not call %s", role, method)
+}
+
+// UnaryServerInterceptor checks the role of the caller of every call. It must come after the interceptor of the auth package.
+func (policy *Policy) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := policy.Check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, request)
+	}
+}
+
+// StreamServerInterceptor checks the role of the caller of every stream. It must come after the interceptor of the auth package.
+func (policy *Policy) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(server any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := policy.Check(stream.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(server, stream)
+	}
+}
+
+// ShortMethod turns "/Auction.Auction/Bid" into "Auction/Bid", by dropping the proto package from the service.
+// A method that is already short is returned as it is.
+func ShortMethod(fullMethod string) string {
+	if !strings.HasPrefix(fullMethod, "/") {
+		return fullMethod
+	}
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if dot := strings.LastIndex(service, "."); dot >= 0 {
+		service = service[dot+1:]
+	}
+	return service + "/" + method
+}
@@ -1,167 +1,197 @@
-// Client and frontend for the auction application
+// Client for the auction application, the frontend is in the frontend package
 package main
 
 import (
+	"Auction/config"
+	"Auction/faults"
+	"Auction/frontend"
 	proto "Auction/grpc"
-	"bufio"
+	"Auction/logging"
+	"Auction/metrics"
+	"Auction/sealed"
+	"Auction/signing"
+	"Auction/tlsconfig"
+	"Auction/tracing"
 	"context"
+	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
-	"strconv"
-	"strings"
 
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/credentials"
 )
 
-//A Client has a slice of all replication managers that it connects to and sends requests to
-//When the frontend registers that a replication manager has failed, it removes it from the slice
+//A Client sends its commands through the frontend, which connects to all replication managers
+//When the frontend registers that a replication manager has failed, it stops using it
 
 type Client struct {
-	id string
+	id       string
+	frontend *frontend.AuctionClient
+	// The last sealed bid, which is revealed by the reveal command
+	sealedBid *sealed.Bid
+	// Records a span for every command, nil without -trace
+	tracer *tracing.Tracer
 }
 
-type Frontend struct {
-	id                  string
-	replicationManagers []int32
-	auctionClients      []proto.AuctionClient
+// Flag that can be given more than once, fx -cmd "bid 100" -cmd result
+type commandFlags []string
+
+func (flags *commandFlags) String() string {
+	return fmt.Sprint(*flags)
 }
 
-func main() {
-	clientId := os.Args[1]
+func (flags *commandFlags) Set(value string) error {
+	*flags = append(*flags, value)
+	return nil
+}
 
-	client := &Client{
-		id: string(clientId),
+func main() {
+	var commands commandFlags
+	flag.Var(&commands, "cmd", "run the command in batch mode, can be given more than once")
+	scriptPath := flag.String("script", "", "run the commands in the file in batch mode, one command per line")
+	caFile := flag.String("ca", "", "connect with TLS, trusting the servers signed by the certificate authority in the file")
+	configPath := flag.String("config", "", "read the servers of the cluster from the cluster section of the JSON configuration file")
+	metricsAddress := flag.String("metrics", "", "serve the metrics of the frontend at /metrics on the address, fx :9100")
+	traceFile := flag.String("trace", "", "append the spans of the commands and of the calls to the servers to the file")
+	keyFile := flag.String("key", "", "sign every bid with the Ed25519 private key in the file")
+	password := flag.String("password", os.Getenv("AUCTION_PASSWORD"), "log in with the password, the default is $AUCTION_PASSWORD")
+	logFlags := logging.AddFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-cmd command]... [-script file] [-password password] [-key file] [-ca file] [-config file] [-metrics address] [-trace file] [-log-level level] [-log-format text|json] name\n", os.Args[0])
+		flag.PrintDefaults()
 	}
-
-	//Create a frontend with the id of the client and the ports of the replication managers
-	//and a slice of auctionClients
-	frontend := &Frontend{
-		id:                  string(clientId),
-		replicationManagers: []int32{5000, 5001, 5002},
-		auctionClients:      []proto.AuctionClient{},
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
 	}
+	clientId := flag.Arg(0)
 
-	//Connect to all replication managers
-	frontend.connectToServers()
-
-	go listenToClient(client, frontend)
-
-	for {
-		//infinite for loop to keep the client running
+	//The logs go to stderr, so they don't mix with the results of batch mode
+	logger, err := logFlags.New(os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-}
+	logger = logger.With("client", clientId)
+	slog.SetDefault(logger)
 
-// Function to listen to client input
-func listenToClient(client *Client, frontend *Frontend) {
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() { //For loop that doesn't stop, until program is terminated
-		scan := scanner.Text()
-		if strings.HasPrefix(scan, "bid") {
-			bidAmount, err := strconv.Atoi(strings.Split(scan, " ")[1])
+	//Create a frontend connected to the replication managers on port 5000, 5001 and 5002, or the ones in the configuration
+	options := []frontend.Option{
+		frontend.WithDiscovery(frontend.DefaultReplicas),
+		frontend.WithLogger(logger),
+	}
+	if *caFile != "" {
+		tlsConfig, err := tlsconfig.ClientConfig(*caFile)
+		if err != nil {
+			logging.Fatal("Could not set up TLS", "err", err)
+		}
+		options = append(options, frontend.WithDialOptions(grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))))
+	}
+	if *configPath != "" {
+		clientConfig, err := config.Load(*configPath)
+		if err != nil {
+			logging.Fatal("Could not load the configuration", "err", err)
+		}
+		if clientConfig.Cluster != nil {
+			clusterOptions, err := frontend.ClusterOptions(*clientConfig.Cluster)
 			if err != nil {
-				log.Println("Bid is not a number")
-				return
+				logging.Fatal("Could not read the cluster", "err", err)
 			}
-			//Send bid to frontend, who will then pass the bid on to all replication managers
-			client.sendBid(int32(bidAmount), frontend)
-
-		} else if scan == "result" {
-			//Request result from frontend, who will then pass the request on to the first replication manager
-			//and the frontend will return the response to the client
-			client.getResult(frontend)
+			options = append(options, clusterOptions...)
+		}
+		if clientConfig.RateLimits != nil {
+			options = append(options, frontend.WithRateLimits(*clientConfig.RateLimits))
+		}
+		if clientConfig.Faults != nil {
+			injector, err := faults.New(*clientConfig.Faults)
+			if err != nil {
+				logging.Fatal("Could not read the fault rules", "err", err)
+			}
+			logger.Warn("Fault injection is enabled", "rules", len(clientConfig.Faults.Rules))
+			options = append(options, frontend.WithFaults(injector))
 		}
 	}
-}
-
-func (client *Client) sendBid(bidAmount int32, frontend *Frontend) {
-	frontendResponse := frontend.sendBid(bidAmount)
-	log.Printf("Client received response from frontend: %s", frontendResponse)
-}
-
-// Function to send bid to all replication managers by looping through auctionClients
-// We assume that there is always a minimum of one functioning server
-func (frontend *Frontend) sendBid(bidAmount int32) string {
-	//The function can maximally remove one server each call of sendBid()
-	var toDelete proto.AuctionClient
-	var serverResponse string
-	for _, auctionClient := range frontend.auctionClients {
-		ack, err := auctionClient.Bid(context.Background(), &proto.BidMessage{Id: frontend.id, Amount: bidAmount})
+	if *keyFile != "" {
+		privateKey, err := signing.LoadPrivateKey(*keyFile)
 		if err != nil {
-			log.Printf("Frontend: Could not send bid to server: Connection lost!")
-			//Mark this server for deletion
-			toDelete = auctionClient
-		} else {
-			log.Printf("Frontend received: Received response from server: %v", ack)
-			serverResponse = ack.Status
+			logging.Fatal("Could not load the signing key", "err", err)
 		}
+		options = append(options, frontend.WithSigningKey(privateKey))
 	}
-
-	//Remove the server marked for deletion if there is one
-	if toDelete != nil {
-		frontend.auctionClients = removeElement(frontend.auctionClients, toDelete)
+	if *metricsAddress != "" {
+		registry := metrics.NewRegistry()
+		options = append(options, frontend.WithMetrics(registry))
+		go func() {
+			if err := http.ListenAndServe(*metricsAddress, registry.Handler()); err != nil {
+				logger.Error("Could not serve the metrics", "err", err)
+			}
+		}()
 	}
-
-	//Return a response to the client
-	return serverResponse
-}
-
-func (client *Client) getResult(frontend *Frontend) {
-	frontendResponse := frontend.getResult()
-	log.Printf("Client received from frontend: %s", frontendResponse)
-}
-
-// Function to request result of auction
-func (frontend *Frontend) getResult() string {
-	//Ask the first replication manager for the result
-	//Since the first RM in the slice is always the first to be updated, it will 
-	//always be the one with the most up-to-date result
-	var serverResponse string
-	outcome, err := frontend.auctionClients[0].GetResult(context.Background(), &proto.Empty{})
+	var tracer *tracing.Tracer
+	if *traceFile != "" {
+		exporter, err := tracing.OpenFile(*traceFile)
+		if err != nil {
+			logging.Fatal("Could not open the file of the spans", "err", err)
+		}
+		defer exporter.Close()
+		tracer = tracing.New("client-"+clientId, exporter)
+		options = append(options, frontend.WithTracer(tracer))
+	}
+	auctionClient, err := frontend.New(options...)
 	if err != nil {
-		//This error will happen, if the first RM in the slice is down
-		log.Printf("Could not receive result from server: %v", err)
-
-		//Remove the first RM from the slice
-		frontend.auctionClients = removeElement(frontend.auctionClients, frontend.auctionClients[0])
-		
-		//Call the function again, to try the next RM in the slice
-		return frontend.getResult()
+		logging.Fatal("Could not create the frontend", "err", err)
+	}
+	defer auctionClient.Close()
+
+	//With a password the client logs in, and the servers take the name from the token instead of trusting it
+	if *password != "" {
+		ctx, span := tracer.Start(logging.EnsureRequestId(context.Background()), "client.login")
+		_, err := auctionClient.Login(ctx, clientId, *password)
+		span.SetError(err)
+		span.End()
+		if err != nil {
+			logging.Fatal("Could not log in", "err", err)
+		}
 	}
 
-	//If there is no winner yet, we only return the highest bid
-	if len(outcome.Winner) == 0 {
-		serverResponse = fmt.Sprintf("The current highest bid is %d", outcome.HighestBid)
-		log.Printf("Frontend received from server: The current highest bid is %d", outcome.HighestBid)
-	} else {
-		serverResponse = fmt.Sprintf("The auction is over! The winner is %s, with the bid of: %d", outcome.Winner, outcome.HighestBid)
-		log.Printf("Frontend received from server: The auction is over! The winner is %s, with the bid of: %d", outcome.Winner, outcome.HighestBid)
+	client := &Client{
+		id:       clientId,
+		frontend: auctionClient,
+		tracer:   tracer,
 	}
 
-	return serverResponse
-}
+	//Without -cmd or -script the client reads commands from the console
+	if len(commands) == 0 && *scriptPath == "" {
+		listenToClient(client)
+		return
+	}
 
-func (frontend *Frontend) connectToServers() {
-	// Dial the servers at the specified port.
-	for _, port := range frontend.replicationManagers {
-		conn, err := grpc.Dial("localhost:"+strconv.Itoa(int(port)), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	//In batch mode the -cmd commands run first and then the script, the output is one JSON object per command
+	var script io.Reader
+	if *scriptPath != "" {
+		file, err := os.Open(*scriptPath)
 		if err != nil {
-			log.Fatalf("Could not connect to port %d", port)
-		} else {
-			log.Printf("Connected to the server at port %d\n", port)
+			logging.Fatal("Could not open script", "err", err)
 		}
-		//Add the connection to the slice of auctionClients
-		frontend.auctionClients = append(frontend.auctionClients, proto.NewAuctionClient(conn))
+		defer file.Close()
+		script = file
+	}
+	if !runBatch(client, commands, script, os.Stdout) {
+		auctionClient.Close()
+		os.Exit(1)
 	}
 }
 
-// Helper method used to remove a specific element from a Slice
-func removeElement(slice []proto.AuctionClient, element proto.AuctionClient) []proto.AuctionClient {
-	for i, v := range slice {
-		if v == element {
-			return append(slice[:i], slice[i+1:]...)
-		}
-	}
-	return slice // Element not found
+// Sends the bid through the frontend, which passes it on to all replication managers
+func (client *Client) sendBid(ctx context.Context, bidAmount int32) (*proto.Acknowledgement, error) {
+	return client.frontend.Bid(ctx, client.id, bidAmount)
+}
+
+// Requests the result through the frontend, which passes the request on to the first replication manager
+func (client *Client) getResult(ctx context.Context) (*proto.Outcome, error) {
+	return client.frontend.Result(ctx)
 }
@@ -0,0 +1,387 @@
+// Command shell of the client, used both interactively and in batch mode
+package main
+
+import (
+	proto "Auction/grpc"
+	"Auction/logging"
+	"Auction/sealed"
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// A command of the shell, run gets the validated arguments and reports every result through emit
+// The context carries the span of the command, so the calls to the servers are part of its trace
+// A result is either a proto message or a line of text
+type command struct {
+	name        string
+	usage       string
+	description string
+	minArgs     int
+	maxArgs     int
+	run         func(ctx context.Context, client *Client, args []string, emit func(any)) error
+}
+
+// Returned by the quit command to stop the shell
+var errQuit = errors.New("quit")
+
+var commands []command
+
+// The table is filled in init, since the help command refers to it
+func init() {
+	commands = []command{
+		{"help", "help", "show this help", 0, 0, runHelp},
+		{"bid", "bid <amount>", "bid the amount, which must be a positive integer", 1, 1, runBid},
+		{"commit", "commit <amount>", "place a sealed bid in a sealed auction, which must be revealed after the bidding", 1, 1, runCommit},
+		{"reveal", "reveal [amount nonce]", "reveal the sealed bid, or the given amount and hex nonce", 0, 2, runReveal},
+		{"result", "result", "show the highest bid, or the winner if the auction is over", 0, 0, runResult},
+		{"status", "status", "show the state of the auction and the time left", 0, 0, runStatus},
+		{"history", "history", "show all accepted bids", 0, 0, runHistory},
+		{"account", "account", "show your balance and the amount held for your highest bids", 0, 0, runAccount},
+		{"watch", "watch [seconds]", "show every change of the auction until it is over, or for the given seconds", 0, 1, runWatch},
+		{"close", "close", "end the auction now, for the seller and admins", 0, 0, runClose},
+		{"cancel", "cancel", "end the auction without a winner, for admins", 0, 0, runCancel},
+		{"ban", "ban <bidder> <seconds>", "stop the bidder from bidding for the given seconds, 0 lifts the ban, for admins", 2, 2, runBan},
+		{"quit", "quit", "stop the client", 0, 0, runQuit},
+	}
+}
+
+// Function to listen to client input
+func listenToClient(client *Client) {
+	fmt.Println(`Type "help" to see the commands`)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() { //For loop that doesn't stop, until the input ends or quit is typed
+		err := runLine(client, scanner.Text(), func(result any) {
+			fmt.Println(describe(result))
+		})
+		if errors.Is(err, errQuit) {
+			return
+		}
+		if err != nil {
+			fmt.Println("Error:", err)
+		}
+	}
+}
+
+// The JSON object written for every command in batch mode
+type batchResult struct {
+	Command string            `json:"command"`
+	Ok      bool              `json:"ok"`
+	Results []json.RawMessage `json:"results,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// Runs the commands and then the lines of the script, and writes one JSON object per line to out
+// Returns false if any of the commands failed
+func runBatch(client *Client, commands []string, script io.Reader, out io.Writer) bool {
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	allOk := true
+
+	runOne := func(line string) bool {
+		result := batchResult{Command: line, Ok: true}
+		err := runLine(client, line, func(value any) {
+			var data []byte
+			if message, ok := value.(protobuf.Message); ok {
+				data, _ = protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(message)
+			} else {
+				data, _ = json.Marshal(value)
+			}
+			result.Results = append(result.Results, data)
+		})
+		if errors.Is(err, errQuit) {
+			return false
+		}
+		if err != nil {
+			result.Ok = false
+			result.Error = err.Error()
+			allOk = false
+		}
+		encoder.Encode(result)
+		return true
+	}
+
+	for _, line := range commands {
+		if !runOne(line) {
+			return allOk
+		}
+	}
+	if script != nil {
+		scanner := bufio.NewScanner(script)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			//Empty lines and comments are skipped in scripts
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !runOne(line) {
+				return allOk
+			}
+		}
+	}
+	return allOk
+}
+
+// Parses a line, validates the arguments and runs the command
+func runLine(client *Client, line string, emit func(any)) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	for _, command := range commands {
+		if command.name != fields[0] {
+			continue
+		}
+		args := fields[1:]
+		if len(args) < command.minArgs || len(args) > command.maxArgs {
+			return fmt.Errorf("usage: %s", command.usage)
+		}
+		//Every command is a request of its own, the frontend and the servers log it with the same request id
+		ctx := logging.ContextWithRequestId(context.Background(), logging.NewRequestId())
+		ctx, span := client.tracer.Start(ctx, "client."+command.name)
+		defer span.End()
+		span.SetAttribute("line", line)
+		slog.DebugContext(ctx, "Running command", "command", line)
+		err := command.run(ctx, client, args, emit)
+		if err != nil {
+			slog.DebugContext(ctx, "Command failed", "command", line, "err", err)
+		}
+		span.SetError(err)
+		return err
+	}
+	return fmt.Errorf("unknown command %q, type \"help\" to see the commands", fields[0])
+}
+
+func runHelp(ctx context.Context, client *Client, args []string, emit func(any)) error {
+	for _, command := range commands {
+		emit(fmt.Sprintf("  %-18s %s", command.usage, command.description))
+	}
+	return nil
+}
+
+func runBid(ctx context.Context, client *Client, args []string, emit func(any)) error {
+	bidAmount, err := strconv.ParseInt(args[0], 10, 32)
+	if err != nil || bidAmount <= 0 {
+		return fmt.Errorf("bid is not a positive integer: %s", args[0])
+	}
+	//Send bid to frontend, who will then pass the bid on to all replication managers
+	ack, err := client.sendBid(ctx, int32(bidAmount))
+	if err != nil {
+		return err
+	}
+	emit(ack)
+	return nil
+}
+
+func runCommit(ctx context.Context, client *Client, args []string, emit func(any)) error {
+	bidAmount, err := strconv.ParseInt(args[0], 10, 32)
+	if err != nil || bidAmount <= 0 {
+		return fmt.Errorf("bid is not a positive integer: %s", args[0])
+	}
+	sealedBid, err := sealed.NewBid(client.id, int32(bidAmount))
+	if err != nil {
+		return err
+	}
+	ack, err := client.frontend.Commit(ctx, client.id, sealedBid.Commitment())
+	if err != nil {
+		return err
+	}
+	emit(ack)
+	if ack.Status == "success" {
+		//The nonce is shown as well, so the bid can still be revealed if the client is restarted
+		client.sealedBid = sealedBid
+		emit(fmt.Sprintf("Sealed bid of %d, reveal it after the bidding with: reveal %d %x", sealedBid.Amount, sealedBid.Amount, sealedBid.Nonce))
+	}
+	return nil
+}
+
+func runReveal(ctx context.Context, client *Client, args []string, emit func(any)) error {
+	sealedBid := client.sealedBid
+	switch len(args) {
+	case 0:
+		if sealedBid == nil {
+			return errors.New("there is no sealed bid to reveal, give the amount and nonce")
+		}
+	case 2:
+		bidAmount, err := strconv.ParseInt(args[0], 10, 32)
+		if err != nil {
+			return fmt.Errorf("bid is not an integer: %s", args[0])
+		}
+		nonce, err := hex.DecodeString(args[1])
+		if err != nil {
+			return fmt.Errorf("the nonce is not hex: %s", args[1])
+		}
+		sealedBid = &sealed.Bid{Bidder: client.id, Amount: int32(bidAmount), Nonce: nonce}
+	default:
+		return errors.New("usage: reveal [amount nonce]")
+	}
+	ack, err := client.frontend.Reveal(ctx, client.id, sealedBid.Amount, sealedBid.Nonce)
+	if err != nil {
+		return err
+	}
+	emit(ack)
+	return nil
+}
+
+func runResult(ctx context.Context, client *Client, args []string, emit func(any)) error {
+	//Request result from frontend, who will then pass the request on to the first replication manager
+	//and the frontend will return the response to the client
+	outcome, err := client.getResult(ctx)
+	if err != nil {
+		return err
+	}
+	emit(outcome)
+	return nil
+}
+
+func runStatus(ctx context.Context, client *Client, args []string, emit func(any)) error {
+	auctions, err := client.frontend.Auctions(ctx)
+	if err != nil {
+		return err
+	}
+	for _, auction := range auctions.Auctions {
+		emit(auction)
+	}
+	return nil
+}
+
+func runHistory(ctx context.Context, client *Client, args []string, emit func(any)) error {
+	history, err := client.frontend.History(ctx)
+	if err != nil {
+		return err
+	}
+	emit(history)
+	return nil
+}
+
+func runAccount(ctx context.Context, client *Client, args []string, emit func(any)) error {
+	account, err := client.frontend.Account(ctx, client.id)
+	if err != nil {
+		return err
+	}
+	emit(account)
+	return nil
+}
+
+func runWatch(ctx context.Context, client *Client, args []string, emit func(any)) error {
+	if len(args) == 1 {
+		seconds, err := strconv.Atoi(args[0])
+		if err != nil || seconds <= 0 {
+			return fmt.Errorf("seconds is not a positive integer: %s", args[0])
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(seconds)*time.Second)
+		defer cancel()
+	}
+	return client.frontend.Watch(ctx, func(info *proto.AuctionInfo) {
+		emit(info)
+	})
+}
+
+func runClose(ctx context.Context, client *Client, args []string, emit func(any)) error {
+	info, err := client.frontend.CloseAuction(ctx)
+	if err != nil {
+		return err
+	}
+	emit(info)
+	return nil
+}
+
+func runCancel(ctx context.Context, client *Client, args []string, emit func(any)) error {
+	info, err := client.frontend.CancelAuction(ctx)
+	if err != nil {
+		return err
+	}
+	emit(info)
+	return nil
+}
+
+func runBan(ctx context.Context, client *Client, args []string, emit func(any)) error {
+	seconds, err := strconv.Atoi(args[1])
+	if err != nil || seconds < 0 {
+		return fmt.Errorf("the seconds must be a number that is 0 or more, got %q", args[1])
+	}
+	if _, err := client.frontend.Ban(ctx, args[0], time.Duration(seconds)*time.Second); err != nil {
+		return err
+	}
+	if seconds == 0 {
+		emit(args[0] + " is no longer banned")
+	} else {
+		emit(fmt.Sprintf("%s is banned for %d seconds", args[0], seconds))
+	}
+	return nil
+}
+
+func runQuit(ctx context.Context, client *Client, args []string, emit func(any)) error {
+	return errQuit
+}
+
+// Formats a result of a command for the console
+func describe(result any) string {
+	switch message := result.(type) {
+	case string:
+		return message
+	case *proto.Acknowledgement:
+		if message.Receipt != nil {
+			return fmt.Sprintf("Bid: %s (receipt %d from %s)", message.Status, message.Receipt.Sequence, message.Receipt.Server)
+		}
+		return "Bid: " + message.Status
+	case *proto.Outcome:
+		return describeOutcome(message)
+	case *proto.AuctionInfo:
+		return describeAuction(message)
+	case *proto.Account:
+		return fmt.Sprintf("Balance: %d, held for highest bids: %d, available: %d", message.Balance, message.Held, message.Available)
+	case *proto.BidHistory:
+		if len(message.Bids) == 0 {
+			return "No bids yet"
+		}
+		lines := make([]string, len(message.Bids))
+		for i, bid := range message.Bids {
+			lines[i] = fmt.Sprintf("%s  %-12s %d", time.UnixMilli(bid.Timestamp).Format(time.TimeOnly), bid.Id, bid.Amount)
+		}
+		return strings.Join(lines, "\n")
+	}
+	return fmt.Sprint(result)
+}
+
+func describeOutcome(outcome *proto.Outcome) string {
+	//If there is no winner yet, we only return the highest bid
+	if len(outcome.Winner) == 0 {
+		return fmt.Sprintf("The current highest bid is %d", outcome.HighestBid)
+	}
+	return fmt.Sprintf("The auction is over! The winner is %s, with the bid of: %d", outcome.Winner, outcome.HighestBid)
+}
+
+func describeAuction(auction *proto.AuctionInfo) string {
+	switch {
+	case auction.IsCancelled:
+		return fmt.Sprintf("Auction %s was cancelled", auction.Id)
+	case auction.IsOver && auction.Winner == "":
+		return fmt.Sprintf("Auction %s is over without a winner", auction.Id)
+	case auction.IsOver:
+		return fmt.Sprintf("Auction %s is over, the winner is %s with the bid of %d", auction.Id, auction.Winner, auction.HighestBid)
+	case auction.EndsAt == 0:
+		return fmt.Sprintf("Auction %s starts with the first bid", auction.Id)
+	case auction.RevealEndsAt != 0:
+		left := time.Until(time.UnixMilli(auction.RevealEndsAt)).Round(time.Second)
+		return fmt.Sprintf("Auction %s: the bidding is over, %v left to reveal the sealed bids", auction.Id, left)
+	case auction.IsSealed:
+		left := time.Until(time.UnixMilli(auction.EndsAt)).Round(time.Second)
+		return fmt.Sprintf("Auction %s is sealed, %v left to commit bids", auction.Id, left)
+	}
+	left := time.Until(time.UnixMilli(auction.EndsAt)).Round(time.Second)
+	return fmt.Sprintf("Auction %s: the highest bid is %d, %v left", auction.Id, auction.HighestBid, left)
+}
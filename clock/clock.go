@@ -0,0 +1,103 @@
+// Package clock is the time of the auction, so it can be controlled, fx by the harness package.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and runs functions after a while.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine after the duration, like time.AfterFunc
+	AfterFunc(duration time.Duration, f func()) Timer
+}
+
+// Timer is a function waiting to be called by AfterFunc.
+type Timer interface {
+	// Stop prevents the function from being called, and returns false if it was already called or stopped
+	Stop() bool
+}
+
+// Real is the clock of the machine.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(duration time.Duration, f func()) Timer {
+	return time.AfterFunc(duration, f)
+}
+
+// Manual is a clock that only moves when it is told to, it is safe for concurrent use.
+type Manual struct {
+	mutex  sync.Mutex
+	now    time.Time
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	clock *Manual
+	when  time.Time
+	f     func()
+}
+
+// NewManual creates a Manual clock that stands still at the time.
+func NewManual(now time.Time) *Manual {
+	return &Manual{now: now}
+}
+
+func (manual *Manual) Now() time.Time {
+	manual.mutex.Lock()
+	defer manual.mutex.Unlock()
+	return manual.now
+}
+
+func (manual *Manual) AfterFunc(duration time.Duration, f func()) Timer {
+	manual.mutex.Lock()
+	defer manual.mutex.Unlock()
+	timer := &manualTimer{clock: manual, when: manual.now.Add(duration), f: f}
+	manual.timers = append(manual.timers, timer)
+	return timer
+}
+
+// Advance moves the clock forward and calls the functions whose time has come, in the order of their times.
+// Unlike time.AfterFunc the functions are called before Advance returns, so their effects can be checked right after it.
+// Timers that the functions start wait for the next Advance, even if they are due.
+func (manual *Manual) Advance(duration time.Duration) {
+	manual.mutex.Lock()
+	manual.now = manual.now.Add(duration)
+	var due, waiting []*manualTimer
+	for _, timer := range manual.timers {
+		if timer.when.After(manual.now) {
+			waiting = append(waiting, timer)
+		} else {
+			due = append(due, timer)
+		}
+	}
+	manual.timers = waiting
+	manual.mutex.Unlock()
+
+	//The functions are called without the mutex, since they may read the clock or start new timers
+	sort.SliceStable(due, func(i, j int) bool { return due[i].when.Before(due[j].when) })
+	for _, timer := range due {
+		timer.f()
+	}
+}
+
+func (timer *manualTimer) Stop() bool {
+	manual := timer.clock
+	manual.mutex.Lock()
+	defer manual.mutex.Unlock()
+	for i, waiting := range manual.timers {
+		if waiting == timer {
+			manual.timers = append(manual.timers[:i], manual.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,344 @@
+// Launches a local cluster of servers and bots as child processes, and kills and restarts them for crash demos
+package main
+
+import (
+	"Auction/authz"
+	"Auction/config"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// The file in the directory of the cluster that the supervisor takes commands on
+const controlSocket = "control.sock"
+
+// A subcommand of cluster, run gets the arguments after the name of the subcommand
+type subcommand struct {
+	name        string
+	description string
+	run         func(args []string)
+}
+
+var subcommands []subcommand
+
+// The table is filled in init, since usage refers to it
+func init() {
+	subcommands = []subcommand{
+		{"up", "build and start the servers and bots, and show their logs until interrupted", runUp},
+		{"ps", "show the processes of the running cluster", runPs},
+		{"kill", "kill servers or bots right away, like a crash", runAction("kill")},
+		{"stop", "stop servers or bots and let them exit", runAction("stop")},
+		{"start", "start servers or bots that were killed or stopped", runAction("start")},
+		{"restart", "stop servers or bots and start them again", runAction("restart")},
+		{"down", "stop all processes and the cluster", runDown},
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	for _, subcommand := range subcommands {
+		if subcommand.name == os.Args[1] {
+			subcommand.run(os.Args[2:])
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [arguments]\n\nThe commands are:\n", os.Args[0])
+	for _, subcommand := range subcommands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", subcommand.name, subcommand.description)
+	}
+}
+
+func addDirFlag(flags *flag.FlagSet) *string {
+	return flags.String("dir", ".cluster", "the directory of the cluster, with the configuration, the binaries and the control socket")
+}
+
+// The name of a replica, the same as in the harness
+func replicaName(i int) string {
+	return fmt.Sprintf("rm-%d", i)
+}
+
+func botName(i int) string {
+	return fmt.Sprintf("bot-%d", i)
+}
+
+func runUp(args []string) {
+	flags := flag.NewFlagSet("up", flag.ExitOnError)
+	dir := addDirFlag(flags)
+	replicas := flags.Int("replicas", 3, "the number of servers, server N listens on grpc port 5000+N and HTTP port 8000+N")
+	bots := flags.Int("bots", 0, "the number of bots that bid on the auction")
+	botRate := flags.Float64("bot-rate", 1, "the bids per second of every bot")
+	botStrategy := flags.String("bot-strategy", "random", "the strategy of the bots, like the -strategy of loadgen")
+	botUsers := flags.String("bot-users", "", "comma separated users the bots log in as when the configuration has an auth section, the default is its users with the bidder role")
+	botPassword := flags.String("bot-password", os.Getenv("AUCTION_PASSWORD"), "the password of the users of the bots, the default is $AUCTION_PASSWORD")
+	configPath := flags.String("config", "", "the configuration the servers start from, the cluster section is replaced by the servers of the cluster")
+	binDir := flags.String("bin", "", "the directory with the server and loadgen binaries, the default is to build them into the directory of the cluster")
+	flags.Parse(args)
+	if *replicas < 1 {
+		log.Fatal("A cluster needs at least one server")
+	}
+
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		log.Fatal(err)
+	}
+	socketPath := filepath.Join(*dir, controlSocket)
+	if connection, err := net.Dial("unix", socketPath); err == nil {
+		connection.Close()
+		log.Fatalf("A cluster is already running in %s, see `cluster ps -dir %s`", *dir, *dir)
+	}
+	//The socket of a cluster that didn't exit cleanly is left behind
+	os.Remove(socketPath)
+
+	if *binDir == "" {
+		*binDir = filepath.Join(*dir, "bin")
+		log.Printf("Building the server and loadgen into %s", *binDir)
+		build := exec.Command("go", "build", "-o", *binDir+string(filepath.Separator), "Auction/server", "Auction/loadgen")
+		build.Stdout = os.Stdout
+		build.Stderr = os.Stderr
+		if err := build.Run(); err != nil {
+			log.Fatalf("Could not build the binaries: %v", err)
+		}
+	}
+
+	clusterConfig, addresses, err := generateConfig(*configPath, *replicas)
+	if err != nil {
+		log.Fatal(err)
+	}
+	generatedPath := filepath.Join(*dir, "config.json")
+	if err := writeConfig(generatedPath, clusterConfig); err != nil {
+		log.Fatal(err)
+	}
+
+	var users []string
+	if *bots > 0 && clusterConfig.Auth != nil {
+		users, err = botLogins(clusterConfig.Auth, *botUsers, *botPassword)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var processes []*process
+	for i, address := range addresses {
+		processes = append(processes, &process{name: replicaName(i), path: filepath.Join(*binDir, "server"),
+			args: []string{"-config", generatedPath, strconv.Itoa(i)}, address: address})
+	}
+	for i := 0; i < *bots; i++ {
+		//A bot bids until the auction is over, so the sniper strategy bids at the end of the auction,
+		//and follows the bids of the other bots and clients
+		botArgs := []string{"-config", generatedPath, "-bidders", "1", "-frontends", "1", "-prefix", botName(i), "-offset", strconv.Itoa(i),
+			"-rate", strconv.FormatFloat(*botRate, 'g', -1, 64), "-strategy", *botStrategy, "-duration", "0", "-follow"}
+		if clusterConfig.Tls != nil {
+			botArgs = append(botArgs, "-ca", clusterConfig.Tls.CaFile)
+		}
+		bot := &process{name: botName(i), path: filepath.Join(*binDir, "loadgen"), args: botArgs}
+		if len(users) > 0 {
+			//The password is passed in the environment, so it doesn't show up in the list of processes
+			bot.args = append(bot.args, "-users", users[i%len(users)])
+			bot.env = append(os.Environ(), "AUCTION_PASSWORD="+*botPassword)
+		}
+		processes = append(processes, bot)
+	}
+
+	supervisor := newSupervisor(processes, os.Stdout)
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(socketPath)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	//The servers are started first, so the bots find them
+	for _, process := range processes {
+		if err := supervisor.start(process); err != nil {
+			supervisor.logf("%v", err)
+			supervisor.stopAll()
+			listener.Close()
+			os.Remove(socketPath)
+			os.Exit(1)
+		}
+	}
+	supervisor.logf("The cluster is up, the configuration of the clients is %s", generatedPath)
+	supervisor.serve(ctx, listener)
+}
+
+// The users the bots log in as, the given ones or the users with the bidder role, whose password must be given
+func botLogins(authConfig *config.Auth, botUsers, password string) ([]string, error) {
+	var users []string
+	if botUsers != "" {
+		users = strings.Split(botUsers, ",")
+	} else {
+		for name, user := range authConfig.Users {
+			if user.Role == "" || user.Role == authz.Bidder {
+				users = append(users, name)
+			}
+		}
+		sort.Strings(users)
+	}
+	if len(users) == 0 {
+		return nil, errors.New("the bots must log in, but the configuration has no bidders, give -bot-users")
+	}
+	if password == "" {
+		return nil, errors.New("the bots must log in, give the password of their users with -bot-password or $AUCTION_PASSWORD")
+	}
+	return users, nil
+}
+
+// The configuration of the servers, the one in configPath with a cluster section of the local servers
+func generateConfig(configPath string, replicas int) (*config.Config, []string, error) {
+	clusterConfig := &config.Config{}
+	if configPath != "" {
+		var err error
+		clusterConfig, err = config.Load(configPath)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	addresses := make([]string, replicas)
+	for i := range addresses {
+		addresses[i] = fmt.Sprintf("localhost:%d", 5000+i)
+	}
+	if clusterConfig.Cluster == nil {
+		clusterConfig.Cluster = &config.Cluster{}
+	}
+	clusterConfig.Cluster.Replicas = addresses
+	return clusterConfig, addresses, nil
+}
+
+func writeConfig(path string, clusterConfig *config.Config) error {
+	data, err := json.MarshalIndent(clusterConfig, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// A client of the control socket of the supervisor
+func controlClient(dir string) *http.Client {
+	socketPath := filepath.Join(dir, controlSocket)
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+}
+
+// Sends a command to the supervisor, and returns the body of the answer
+func control(dir, method, path string) ([]byte, error) {
+	request, err := http.NewRequest(method, "http://cluster"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := controlClient(dir).Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("no cluster is running in %s: %w", dir, err)
+	}
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+func runPs(args []string) {
+	flags := flag.NewFlagSet("ps", flag.ExitOnError)
+	dir := addDirFlag(flags)
+	flags.Parse(args)
+
+	body, err := control(*dir, http.MethodGet, "/processes")
+	if err != nil {
+		log.Fatal(err)
+	}
+	var statuses []processStatus
+	if err := json.Unmarshal(body, &statuses); err != nil {
+		log.Fatal(err)
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tADDRESS\tPID\tSTATE\tUPTIME\tRESTARTS\tEXIT")
+	for _, status := range statuses {
+		pid, uptime := "-", "-"
+		if status.State == stateRunning {
+			pid = strconv.Itoa(status.Pid)
+			uptime = time.Since(status.Started).Round(time.Second).String()
+		}
+		address := status.Address
+		if address == "" {
+			address = "-"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n", status.Name, address, pid, status.State, uptime, status.Restarts, status.Exit)
+	}
+	writer.Flush()
+}
+
+// A subcommand that sends an action for every process named in the arguments, a number is the name of a server
+func runAction(action string) func(args []string) {
+	return func(args []string) {
+		flags := flag.NewFlagSet(action, flag.ExitOnError)
+		dir := addDirFlag(flags)
+		flags.Usage = func() {
+			fmt.Fprintf(os.Stderr, "Usage: cluster %s [-dir dir] name...\nA name is rm-N or just N for server N, or bot-N for bot N.\n", action)
+			flags.PrintDefaults()
+		}
+		flags.Parse(args)
+		if flags.NArg() == 0 {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		failed := false
+		for _, name := range flags.Args() {
+			if number, err := strconv.Atoi(name); err == nil {
+				name = replicaName(number)
+			}
+			if _, err := control(*dir, http.MethodPost, "/"+action+"?name="+url.QueryEscape(name)); err != nil {
+				log.Printf("%s: %v", name, err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	}
+}
+
+func runDown(args []string) {
+	flags := flag.NewFlagSet("down", flag.ExitOnError)
+	dir := addDirFlag(flags)
+	flags.Parse(args)
+
+	if _, err := control(*dir, http.MethodPost, "/down"); err != nil {
+		log.Fatal(err)
+	}
+}
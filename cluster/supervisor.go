@@ -0,0 +1,340 @@
+// The supervisor that runs the servers and bots as child processes
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// How long a stopped process gets to exit before it is killed
+const stopTimeout = 5 * time.Second
+
+// How long a started server gets to listen for grpc
+const readyTimeout = 10 * time.Second
+
+// The states of a process
+const (
+	stateRunning = "running"
+	stateExited  = "exited"
+	stateKilled  = "killed"
+	stateStopped = "stopped"
+)
+
+// A server or bot of the cluster, which can be killed and started again
+type process struct {
+	name    string
+	path    string
+	args    []string
+	env     []string
+	address string
+
+	cmd   *exec.Cmd
+	state string
+	//The state the process is in when it exits, after it was killed or stopped on purpose
+	stopping string
+	exit     string
+	started  time.Time
+	restarts int
+	//Closed when the process has exited and its output is written
+	done chan struct{}
+}
+
+// The state of a process, as the control socket returns it
+type processStatus struct {
+	Name     string    `json:"name"`
+	Address  string    `json:"address,omitempty"`
+	Pid      int       `json:"pid,omitempty"`
+	State    string    `json:"state"`
+	Exit     string    `json:"exit,omitempty"`
+	Started  time.Time `json:"started"`
+	Restarts int       `json:"restarts"`
+}
+
+// Runs the processes, writes their output with the name of the process in front of every line,
+// and takes the commands of the other subcommands on the control socket
+type supervisor struct {
+	mutex     sync.Mutex
+	processes []*process
+	//The lines of all processes go through one writer, so they don't mix
+	outputMutex sync.Mutex
+	output      io.Writer
+	width       int
+	shutdown    chan struct{}
+	down        sync.Once
+}
+
+func newSupervisor(processes []*process, output io.Writer) *supervisor {
+	width := 0
+	for _, process := range processes {
+		width = max(width, len(process.name))
+	}
+	return &supervisor{processes: processes, output: output, width: width, shutdown: make(chan struct{})}
+}
+
+// Writes a line of the supervisor itself to the combined log
+func (supervisor *supervisor) logf(format string, args ...any) {
+	supervisor.writeLine("cluster", []byte(fmt.Sprintf(format, args...)))
+}
+
+func (supervisor *supervisor) writeLine(name string, line []byte) {
+	supervisor.outputMutex.Lock()
+	defer supervisor.outputMutex.Unlock()
+	fmt.Fprintf(supervisor.output, "%-*s | %s\n", supervisor.width, name, line)
+}
+
+// Splits the output of a process into lines, and writes every line with the name of the process
+type prefixWriter struct {
+	supervisor *supervisor
+	name       string
+	buffer     []byte
+}
+
+func (writer *prefixWriter) Write(data []byte) (int, error) {
+	writer.buffer = append(writer.buffer, data...)
+	for {
+		end := bytes.IndexByte(writer.buffer, '\n')
+		if end < 0 {
+			return len(data), nil
+		}
+		writer.supervisor.writeLine(writer.name, bytes.TrimRight(writer.buffer[:end], "\r"))
+		writer.buffer = writer.buffer[end+1:]
+	}
+}
+
+// Writes the last line of a process if it didn't end with a newline
+func (writer *prefixWriter) flush() {
+	if len(writer.buffer) > 0 {
+		writer.supervisor.writeLine(writer.name, writer.buffer)
+		writer.buffer = nil
+	}
+}
+
+func (supervisor *supervisor) find(name string) (*process, error) {
+	for _, process := range supervisor.processes {
+		if process.name == name {
+			return process, nil
+		}
+	}
+	return nil, fmt.Errorf("there is no process %s", name)
+}
+
+// Starts a process that isn't running, and waits until a server listens for grpc
+func (supervisor *supervisor) start(process *process) error {
+	supervisor.mutex.Lock()
+	if process.state == stateRunning {
+		supervisor.mutex.Unlock()
+		return fmt.Errorf("%s is already running", process.name)
+	}
+	output := &prefixWriter{supervisor: supervisor, name: process.name}
+	cmd := exec.Command(process.path, process.args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.Env = process.env
+	if err := cmd.Start(); err != nil {
+		supervisor.mutex.Unlock()
+		return fmt.Errorf("starting %s: %w", process.name, err)
+	}
+	if !process.started.IsZero() {
+		process.restarts++
+	}
+	process.cmd = cmd
+	process.state = stateRunning
+	process.stopping = ""
+	process.exit = ""
+	process.started = time.Now()
+	done := make(chan struct{})
+	process.done = done
+	supervisor.mutex.Unlock()
+	supervisor.logf("Started %s, pid %d", process.name, cmd.Process.Pid)
+
+	go func() {
+		err := cmd.Wait()
+		output.flush()
+		supervisor.mutex.Lock()
+		//A process that was killed or stopped on purpose is in that state, otherwise it exited on its own
+		process.state = stateExited
+		if process.stopping != "" {
+			process.state = process.stopping
+		}
+		process.exit = "exit status 0"
+		if err != nil {
+			process.exit = err.Error()
+		}
+		state := process.state
+		supervisor.mutex.Unlock()
+		supervisor.logf("%s is %s, %s", process.name, state, process.exit)
+		close(done)
+	}()
+
+	if process.address == "" {
+		return nil
+	}
+	return waitUntilListening(process.address, done)
+}
+
+// Waits until the address accepts connections, or the process has exited
+func waitUntilListening(address string, done chan struct{}) error {
+	deadline := time.Now().Add(readyTimeout)
+	for time.Now().Before(deadline) {
+		connection, err := net.DialTimeout("tcp", address, time.Second)
+		if err == nil {
+			connection.Close()
+			return nil
+		}
+		select {
+		case <-done:
+			return fmt.Errorf("the server exited before it listened on %s", address)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	return fmt.Errorf("the server doesn't listen on %s after %v", address, readyTimeout)
+}
+
+// Kills a running process right away like a crash, or lets it exit with SIGTERM if graceful is set,
+// and waits until it has exited
+func (supervisor *supervisor) kill(process *process, graceful bool) error {
+	supervisor.mutex.Lock()
+	if process.state != stateRunning {
+		supervisor.mutex.Unlock()
+		return fmt.Errorf("%s isn't running", process.name)
+	}
+	signal, stopping := syscall.SIGKILL, stateKilled
+	if graceful {
+		signal, stopping = syscall.SIGTERM, stateStopped
+	}
+	//The state changes when the process has exited, the signal only decides which state it changes to,
+	//and if the signal can't be sent the process keeps running as before
+	previous := process.stopping
+	process.stopping = stopping
+	cmd, done := process.cmd, process.done
+	supervisor.mutex.Unlock()
+
+	if err := cmd.Process.Signal(signal); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		supervisor.mutex.Lock()
+		if process.done == done {
+			process.stopping = previous
+		}
+		supervisor.mutex.Unlock()
+		return fmt.Errorf("signalling %s: %w", process.name, err)
+	}
+	select {
+	case <-done:
+	case <-time.After(stopTimeout):
+		supervisor.logf("%s didn't exit after %v, killing it", process.name, stopTimeout)
+		if err := cmd.Process.Kill(); err == nil {
+			supervisor.mutex.Lock()
+			process.stopping = stateKilled
+			supervisor.mutex.Unlock()
+		}
+		<-done
+	}
+	return nil
+}
+
+// Stops a process if it is running, and starts it again
+func (supervisor *supervisor) restart(process *process) error {
+	supervisor.mutex.Lock()
+	running := process.state == stateRunning
+	supervisor.mutex.Unlock()
+	if running {
+		if err := supervisor.kill(process, true); err != nil {
+			return err
+		}
+	}
+	return supervisor.start(process)
+}
+
+// Stops all running processes
+func (supervisor *supervisor) stopAll() {
+	var wait sync.WaitGroup
+	for _, running := range supervisor.processes {
+		wait.Add(1)
+		go func(running *process) {
+			defer wait.Done()
+			supervisor.kill(running, true)
+		}(running)
+	}
+	wait.Wait()
+}
+
+func (supervisor *supervisor) statuses() []processStatus {
+	supervisor.mutex.Lock()
+	defer supervisor.mutex.Unlock()
+	statuses := make([]processStatus, len(supervisor.processes))
+	for i, process := range supervisor.processes {
+		statuses[i] = processStatus{Name: process.name, Address: process.address, State: process.state,
+			Exit: process.exit, Started: process.started, Restarts: process.restarts}
+		if process.state == stateRunning {
+			statuses[i].Pid = process.cmd.Process.Pid
+		}
+	}
+	return statuses
+}
+
+// The control API on the socket, the other subcommands of cluster call it
+func (supervisor *supervisor) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/processes", func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(writer).Encode(supervisor.statuses())
+	})
+	actions := map[string]func(*process) error{
+		"kill":    func(process *process) error { return supervisor.kill(process, false) },
+		"stop":    func(process *process) error { return supervisor.kill(process, true) },
+		"start":   supervisor.start,
+		"restart": supervisor.restart,
+	}
+	for name, action := range actions {
+		action := action
+		mux.HandleFunc("/"+name, func(writer http.ResponseWriter, request *http.Request) {
+			if request.Method != http.MethodPost {
+				http.Error(writer, "use POST", http.StatusMethodNotAllowed)
+				return
+			}
+			process, err := supervisor.find(request.URL.Query().Get("name"))
+			if err != nil {
+				http.Error(writer, err.Error(), http.StatusNotFound)
+				return
+			}
+			if err := action(process); err != nil {
+				http.Error(writer, err.Error(), http.StatusConflict)
+			}
+		})
+	}
+	mux.HandleFunc("/down", func(writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			http.Error(writer, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		supervisor.stopAll()
+		supervisor.down.Do(func() { close(supervisor.shutdown) })
+	})
+	return mux
+}
+
+// Serves the control API on the unix socket until ctx is done or the cluster is taken down
+func (supervisor *supervisor) serve(ctx context.Context, listener net.Listener) {
+	server := &http.Server{Handler: supervisor.handler()}
+	go server.Serve(listener)
+	select {
+	case <-ctx.Done():
+		supervisor.logf("Stopping the cluster")
+		supervisor.stopAll()
+	case <-supervisor.shutdown:
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+}
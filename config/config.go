@@ -0,0 +1,209 @@
+// Package config reads the JSON configuration file of the servers.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config is the configuration file given to a server with -config.
+// Every section is optional, a missing section turns the feature off.
+type Config struct {
+	Auth          *Auth          `json:"auth,omitempty"`
+	Tls           *Tls           `json:"tls,omitempty"`
+	Accounts      *Accounts      `json:"accounts,omitempty"`
+	Authorization *Authorization `json:"authorization,omitempty"`
+	Auction       *Auction       `json:"auction,omitempty"`
+	RateLimits    *RateLimits    `json:"rateLimits,omitempty"`
+	Signing       *Signing       `json:"signing,omitempty"`
+	Cluster       *Cluster       `json:"cluster,omitempty"`
+	Audit         *Audit         `json:"audit,omitempty"`
+	Privacy       *Privacy       `json:"privacy,omitempty"`
+	Tracing       *Tracing       `json:"tracing,omitempty"`
+	Divergence    *Divergence    `json:"divergence,omitempty"`
+	Faults        *Faults        `json:"faults,omitempty"`
+}
+
+// Auth configures the Login RPC and the tokens it issues.
+type Auth struct {
+	// Algorithm is "HS256" for HMAC-SHA256 or "EdDSA" for Ed25519 signatures.
+	Algorithm string `json:"algorithm"`
+	// KeyFile is the secret for HS256, or the PEM encoded Ed25519 private key for EdDSA.
+	// Use `auctionctl gen-key` to create one. All servers must use the same key.
+	KeyFile string `json:"keyFile"`
+	// TokenTtl is how long an issued token is valid, the default is one hour.
+	TokenTtl Duration `json:"tokenTtl"`
+	// Users maps every bidder to the hash of their password, made with `auctionctl hash-password`.
+	Users map[string]User `json:"users"`
+}
+
+type User struct {
+	PasswordHash string `json:"passwordHash"`
+	// Role is one of the roles of the authorization policy, the default is "bidder".
+	Role string `json:"role,omitempty"`
+}
+
+// Authorization is the policy of which roles may call which methods. It is only enforced with auth enabled.
+type Authorization struct {
+	// AnonymousRole is the role of callers without a token, the default is "observer".
+	// Set it to "" to make everybody log in first.
+	AnonymousRole *string `json:"anonymousRole,omitempty"`
+	// Roles maps every role to the methods it may call, written as "Service/Method", "Service/*" or "*".
+	// Without roles, the default policy of the authz package is used.
+	Roles map[string][]string `json:"roles,omitempty"`
+}
+
+// DefaultAuction is the id of the single auction the servers host, the receipts of its bids carry it.
+const DefaultAuction = "default"
+
+// Auction configures the auction hosted by the servers.
+type Auction struct {
+	// Seller is the user who may close the auction besides the admins.
+	Seller string `json:"seller,omitempty"`
+	// Duration is how long the auction runs after the first bid, the default is 60 seconds.
+	Duration Duration `json:"duration,omitempty"`
+	// Sealed turns on sealed bidding, where the bidders commit to a hash of their bid and reveal it after the bidding.
+	Sealed *Sealed `json:"sealed,omitempty"`
+}
+
+// Sealed is how long the bidders have to reveal their bids, and what happens to bidders who don't reveal them.
+// Penalty is "none", "fine" to charge the fine to their account, or "ban" to ban them for BanDuration.
+type Sealed struct {
+	RevealWindow Duration `json:"revealWindow,omitempty"`
+	Penalty      string   `json:"penalty,omitempty"`
+	Fine         int32    `json:"fine,omitempty"`
+	BanDuration  Duration `json:"banDuration,omitempty"`
+}
+
+// Tls turns on TLS for the grpc server and the HTTP API, use `auctionctl gen-ca` to create the files.
+type Tls struct {
+	// CertFile and KeyFile are the certificate of the server. The servers also show it to each other
+	// when they connect, so it must be valid for both server and client authentication.
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// CaFile is the certificate authority of the cluster. Frontends trust servers signed by it,
+	// and only callers with a client certificate signed by it may call the internal services.
+	CaFile string `json:"caFile"`
+}
+
+// Accounts gives every bidder a balance, and bids above the available funds of the bidder are rejected.
+// All servers must have the same accounts, since they keep the ledger by applying the same bids.
+type Accounts struct {
+	// DefaultBalance is the balance of the bidders that aren't in Balances.
+	DefaultBalance int32 `json:"defaultBalance"`
+	// Balances maps bidders to their starting balance.
+	Balances map[string]int32 `json:"balances"`
+}
+
+// RateLimits are token buckets that limit how fast callers may send requests.
+// A rate of 0 turns that limit off.
+type RateLimits struct {
+	// BidsPerSecond and BidBurst limit the bids of every bidder, no matter which connection they come from.
+	BidsPerSecond float64 `json:"bidsPerSecond"`
+	BidBurst      int     `json:"bidBurst"`
+	// CallsPerSecond and CallBurst limit all calls on every connection, and all HTTP requests from every address.
+	// Other servers of the cluster, recognized by their client certificate, are not limited.
+	CallsPerSecond float64 `json:"callsPerSecond"`
+	CallBurst      int     `json:"callBurst"`
+}
+
+// Signing is the Ed25519 key the server signs receipts with, and the public keys of the bidders.
+// When Bidders is set, every bid must be signed by the key of its bidder.
+type Signing struct {
+	KeyFile string `json:"keyFile"`
+	// Bidders maps bidders to their base64 encoded public keys, as printed by `auctionctl public-key`
+	Bidders map[string]string `json:"bidders,omitempty"`
+}
+
+// Cluster lists the servers for the frontends of the clients and of the HTTP APIs.
+// With ServerKeys the frontends don't trust a single server: there must be 3f+1 keys,
+// and a result or bid only counts when f+1 servers signed the same answer.
+type Cluster struct {
+	Replicas   []string `json:"replicas"`
+	ServerKeys []string `json:"serverKeys,omitempty"`
+}
+
+// Audit is where the servers write their audit logs, server N writes audit-N.jsonl in Dir.
+type Audit struct {
+	Dir string `json:"dir"`
+}
+
+// Privacy turns on pseudonyms: the results, streams and history show a pseudonym instead of the name of a bidder,
+// except to the bidder themselves, the seller and admins. The pseudonyms are made with the secret in KeyFile,
+// created with `auctionctl gen-key`, and all servers must have the same secret.
+type Privacy struct {
+	KeyFile string `json:"keyFile"`
+}
+
+// Tracing is where the servers write their spans, server N writes spans-N.jsonl in Dir.
+// Read the files with `auctionctl trace`.
+type Tracing struct {
+	Dir string `json:"dir"`
+}
+
+// Divergence makes every server compare the digests of the states of all servers at the interval,
+// and log the auctions and bids that differ. Run `auctionctl diverge` to compare them once.
+type Divergence struct {
+	Interval Duration `json:"interval"`
+}
+
+// Faults injects failures into the calls of a server or client, for chaos testing.
+// With the section, even without rules, admins can also change the rules of a running server with `auctionctl faults`.
+type Faults struct {
+	Rules []FaultRule `json:"rules,omitempty"`
+}
+
+// FaultRule injects a fault into the calls of the method to or from the peer. A call that matches several rules gets the first one.
+type FaultRule struct {
+	// Method is written as "Service/Method", "Service/*" or "*", like the methods of the roles.
+	Method string `json:"method"`
+	// Peer is part of the address of the other side, the caller on a server and the server on a client. Empty matches everybody.
+	Peer string `json:"peer,omitempty"`
+	// Probability is the chance that a matching call gets the fault, 0 means every call.
+	Probability float64 `json:"probability,omitempty"`
+	// Count is how many calls get the fault before the rule is used up, 0 means no limit.
+	Count int `json:"count,omitempty"`
+	// Latency delays the call before it is handled.
+	Latency Duration `json:"latency,omitempty"`
+	// Code fails the call with the grpc status code, fx "UNAVAILABLE", without handling it.
+	Code string `json:"code,omitempty"`
+	// DropResponse handles the call but never answers it, so the caller times out.
+	DropResponse bool `json:"dropResponse,omitempty"`
+	// Crash stops the process "before" or "after" the call is handled.
+	Crash string `json:"crash,omitempty"`
+}
+
+// Duration is a time.Duration written as a string in the file, fx "90s" or "1h".
+type Duration time.Duration
+
+func (duration Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(duration).String())
+}
+
+func (duration *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("duration must be a string like \"90s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*duration = Duration(parsed)
+	return nil
+}
+
+// Load reads the configuration file at the path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	return config, nil
+}
@@ -0,0 +1,186 @@
+// Package divergence compares the digests of the replication managers, and tells exactly which auctions and bids differ.
+//
+// The replication managers apply the same writes in the same order, so their digests should be the same.
+// A write that is applied on some of them but not yet on the others also makes them differ for a moment,
+// so a checker should only report the differences that are still there when it checks again, see Persistent.
+package divergence
+
+import (
+	proto "Auction/grpc"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The value of an item that a replication manager doesn't have
+const missing = "missing"
+
+// Check compares the digests of the replication managers that answered, the ones with an error are unreachable.
+// The addresses, digests and errors are in the same order.
+func Check(addresses []string, digests []*proto.StateDigest, errs []error) *proto.DivergenceReport {
+	report := &proto.DivergenceReport{CheckedAt: time.Now().UnixMilli()}
+	var answered []string
+	var answers []*proto.StateDigest
+	for i, address := range addresses {
+		if errs[i] != nil || digests[i] == nil {
+			report.Unreachable = append(report.Unreachable, address)
+			continue
+		}
+		answered = append(answered, address)
+		answers = append(answers, digests[i])
+	}
+	report.Differences = Compare(answered, answers)
+	return report
+}
+
+// Compare returns what differs between the digests, sorted by auction and item.
+// Every difference has the value of every replication manager, in the order of the addresses.
+func Compare(addresses []string, digests []*proto.StateDigest) []*proto.Difference {
+	if agree(digests) {
+		return nil
+	}
+	auctions := make([]map[string]*proto.AuctionDigest, len(digests))
+	var ids []string
+	for i, digest := range digests {
+		auctions[i] = make(map[string]*proto.AuctionDigest)
+		for _, auction := range digest.Auctions {
+			if !slices.Contains(ids, auction.Id) {
+				ids = append(ids, auction.Id)
+			}
+			auctions[i][auction.Id] = auction
+		}
+	}
+	sort.Strings(ids)
+
+	var differences []*proto.Difference
+	for _, id := range ids {
+		items := make([]map[string]string, len(digests))
+		var names []string
+		for i := range digests {
+			items[i] = auctionItems(auctions[i][id])
+			for name := range items[i] {
+				if !slices.Contains(names, name) {
+					names = append(names, name)
+				}
+			}
+		}
+		sort.Slice(names, func(a, b int) bool { return itemLess(names[a], names[b]) })
+		for _, name := range names {
+			values := make([]*proto.ReplicaValue, len(digests))
+			same := true
+			for i, address := range addresses {
+				value, ok := items[i][name]
+				if !ok {
+					value = missing
+				}
+				values[i] = &proto.ReplicaValue{Address: address, Value: value}
+				same = same && value == values[0].Value
+			}
+			if !same {
+				differences = append(differences, &proto.Difference{Auction: id, Item: name, Values: values})
+			}
+		}
+	}
+	return differences
+}
+
+// Persistent returns the report of the later check with only the differences that were also found by the earlier check,
+// so the writes that were only applied on some of the replication managers during the earlier check are left out.
+func Persistent(earlier, later *proto.DivergenceReport) *proto.DivergenceReport {
+	found := make(map[string]bool)
+	for _, difference := range earlier.Differences {
+		found[key(difference)] = true
+	}
+	report := &proto.DivergenceReport{Unreachable: later.Unreachable, CheckedAt: later.CheckedAt}
+	for _, difference := range later.Differences {
+		if found[key(difference)] {
+			report.Differences = append(report.Differences, difference)
+		}
+	}
+	return report
+}
+
+// Format writes a difference on one line, fx `default bid 3: localhost:5000="alice 20" localhost:5001=missing`.
+func Format(difference *proto.Difference) string {
+	text := difference.Auction + " " + difference.Item + ":"
+	for _, value := range difference.Values {
+		if value.Value == missing {
+			text += " " + value.Address + "=" + missing
+		} else {
+			text += " " + value.Address + "=" + strconv.Quote(value.Value)
+		}
+	}
+	return text
+}
+
+func agree(digests []*proto.StateDigest) bool {
+	for _, digest := range digests {
+		if digest.Checksum != digests[0].Checksum {
+			return false
+		}
+	}
+	return true
+}
+
+// The items of an auction, which are compared one by one, and none if the auction is missing
+// The bids are numbered from 1 in the order they were accepted
+func auctionItems(auction *proto.AuctionDigest) map[string]string {
+	items := make(map[string]string)
+	if auction == nil {
+		return items
+	}
+	items["auction"] = "present"
+	items["over"] = strconv.FormatBool(auction.IsOver)
+	items["cancelled"] = strconv.FormatBool(auction.IsCancelled)
+	items["revealing"] = strconv.FormatBool(auction.IsRevealing)
+	for i, bid := range auction.Bids {
+		items["bid "+strconv.Itoa(i+1)] = fmt.Sprintf("%s %d", bid.Id, bid.Amount)
+	}
+	for _, sealed := range auction.SealedBids {
+		items["sealed "+sealed.Id] = fmt.Sprintf("commitment %s revealed %t amount %d", shortHex(sealed.Commitment), sealed.Revealed, sealed.Amount)
+	}
+	for _, ban := range auction.Bans {
+		items["ban "+ban.Id] = "until " + time.UnixMilli(ban.Until).UTC().Format(time.RFC3339Nano)
+	}
+	return items
+}
+
+// Sorts the items as they are listed in auctionItems, with the bids in the order they were accepted
+func itemLess(a, b string) bool {
+	rankA, restA := itemRank(a)
+	rankB, restB := itemRank(b)
+	if rankA != rankB {
+		return rankA < rankB
+	}
+	numberA, errA := strconv.Atoi(restA)
+	numberB, errB := strconv.Atoi(restB)
+	if errA == nil && errB == nil {
+		return numberA < numberB
+	}
+	return restA < restB
+}
+
+func itemRank(item string) (int, string) {
+	for rank, prefix := range []string{"auction", "over", "cancelled", "revealing", "bid ", "sealed ", "ban "} {
+		if rest, ok := strings.CutPrefix(item, prefix); ok {
+			return rank, rest
+		}
+	}
+	return 7, item
+}
+
+func key(difference *proto.Difference) string {
+	return difference.Auction + "\x00" + difference.Item
+}
+
+func shortHex(data []byte) string {
+	text := hex.EncodeToString(data)
+	if len(text) > 16 {
+		return text[:16]
+	}
+	return text
+}
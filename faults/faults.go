@@ -0,0 +1,296 @@
+// Package faults injects failures into grpc calls for chaos testing: latency, error codes, dropped responses and crashes.
+//
+// The Injector has interceptors for servers and clients, and its rules can be changed while it runs.
+package faults
+
+import (
+	"Auction/authz"
+	"Auction/config"
+	proto "Auction/grpc"
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// When a crash rule stops the process
+const (
+	CrashBefore = "before"
+	CrashAfter  = "after"
+)
+
+// The methods that are never faulted, so the rules can always be changed back
+var exempt = []string{"Admin/GetFaults", "Admin/SetFaults"}
+
+// A rule with its parsed code and the calls it has left
+type rule struct {
+	config.FaultRule
+	code      codes.Code
+	remaining int
+}
+
+// Injector decides which calls get a fault, it is safe for concurrent use.
+type Injector struct {
+	mutex  sync.Mutex
+	rules  []*rule
+	random *rand.Rand
+	crash  func()
+}
+
+// New creates an Injector with the rules of the faults section of the configuration.
+func New(faults config.Faults) (*Injector, error) {
+	injector := &Injector{
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+		crash: func() {
+			slog.Error("Crashing on purpose, because of a fault rule")
+			os.Exit(1)
+		},
+	}
+	if err := injector.SetRules(faults.Rules); err != nil {
+		return nil, err
+	}
+	return injector, nil
+}
+
+// OnCrash replaces what a crash rule does, which is to exit the process, fx to crash a node of the harness package instead.
+func (injector *Injector) OnCrash(crash func()) {
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+	injector.crash = crash
+}
+
+// SetRules replaces the rules, none of them are used if one is invalid.
+func (injector *Injector) SetRules(faultRules []config.FaultRule) error {
+	rules := make([]*rule, len(faultRules))
+	for i, faultRule := range faultRules {
+		if faultRule.Method == "" {
+			return fmt.Errorf("rule %d has no method, use \"*\" for all methods", i+1)
+		}
+		if faultRule.Probability < 0 || faultRule.Probability > 1 {
+			return fmt.Errorf("rule %d has the probability %v, it must be between 0 and 1", i+1, faultRule.Probability)
+		}
+		if faultRule.Crash != "" && faultRule.Crash != CrashBefore && faultRule.Crash != CrashAfter {
+			return fmt.Errorf("rule %d crashes %q, it must be %q or %q", i+1, faultRule.Crash, CrashBefore, CrashAfter)
+		}
+		rules[i] = &rule{FaultRule: faultRule, remaining: faultRule.Count}
+		if faultRule.Code != "" {
+			if err := rules[i].code.UnmarshalJSON([]byte(strconv.Quote(strings.ToUpper(faultRule.Code)))); err != nil || rules[i].code == codes.OK {
+				return fmt.Errorf("rule %d has the code %q, which is not a grpc error code", i+1, faultRule.Code)
+			}
+		}
+	}
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+	injector.rules = rules
+	return nil
+}
+
+// Rules returns the rules that can still fault calls, with the Count of a limited rule lowered to the calls it has left.
+// A used up rule is left out, since a Count of 0 would make it a rule without a limit.
+func (injector *Injector) Rules() []config.FaultRule {
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+	var faultRules []config.FaultRule
+	for _, rule := range injector.rules {
+		if rule.Count > 0 && rule.remaining == 0 {
+			continue
+		}
+		faultRule := rule.FaultRule
+		faultRule.Count = rule.remaining
+		faultRules = append(faultRules, faultRule)
+	}
+	return faultRules
+}
+
+// Returns the rule that faults the call, if any, and counts it
+func (injector *Injector) match(method, address string) *rule {
+	injector.mutex.Lock()
+	defer injector.mutex.Unlock()
+	for _, exemptMethod := range exempt {
+		if method == exemptMethod {
+			return nil
+		}
+	}
+	service, _, _ := strings.Cut(method, "/")
+	for _, rule := range injector.rules {
+		if rule.Method != "*" && rule.Method != method && rule.Method != service+"/*" {
+			continue
+		}
+		if rule.Peer != "" && !strings.Contains(address, rule.Peer) {
+			continue
+		}
+		if rule.Count > 0 && rule.remaining == 0 {
+			continue
+		}
+		if rule.Probability > 0 && injector.random.Float64() >= rule.Probability {
+			continue
+		}
+		if rule.Count > 0 {
+			rule.remaining--
+		}
+		matched := *rule
+		return &matched
+	}
+	return nil
+}
+
+// Applies the faults that come before the call is handled, and returns the error to answer with instead of handling it
+func (injector *Injector) before(ctx context.Context, fault *rule, method string) error {
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(time.Duration(fault.Latency)):
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	if fault.Crash == CrashBefore {
+		injector.crashNow()
+	}
+	if fault.code != codes.OK {
+		return status.Errorf(fault.code, "fault injected into %s", method)
+	}
+	return nil
+}
+
+func (injector *Injector) crashNow() {
+	injector.mutex.Lock()
+	crash := injector.crash
+	injector.mutex.Unlock()
+	crash()
+}
+
+// UnaryServerInterceptor faults the calls that match the rules, the peer is the address of the caller.
+func (injector *Injector) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		method := authz.ShortMethod(info.FullMethod)
+		fault := injector.match(method, callerAddress(ctx))
+		if fault == nil {
+			return handler(ctx, request)
+		}
+		if err := injector.before(ctx, fault, method); err != nil {
+			return nil, err
+		}
+		response, err := handler(ctx, request)
+		if fault.Crash == CrashAfter {
+			injector.crashNow()
+		}
+		//The call is handled, but the caller only hears that it gave up
+		if fault.DropResponse {
+			<-ctx.Done()
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		return response, err
+	}
+}
+
+// StreamServerInterceptor faults the streams that match the rules when they are opened.
+func (injector *Injector) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(server any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		method := authz.ShortMethod(info.FullMethod)
+		fault := injector.match(method, callerAddress(stream.Context()))
+		if fault == nil {
+			return handler(server, stream)
+		}
+		if err := injector.before(stream.Context(), fault, method); err != nil {
+			return err
+		}
+		return handler(server, stream)
+	}
+}
+
+// UnaryClientInterceptor faults the calls that match the rules, the peer is the address of the server.
+// A dropped response fails with UNAVAILABLE at once after the server handled the call.
+func (injector *Injector) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, fullMethod string, request, reply any, conn *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		method := authz.ShortMethod(fullMethod)
+		fault := injector.match(method, conn.Target())
+		if fault == nil {
+			return invoker(ctx, fullMethod, request, reply, conn, opts...)
+		}
+		if err := injector.before(ctx, fault, method); err != nil {
+			return err
+		}
+		err := invoker(ctx, fullMethod, request, reply, conn, opts...)
+		if fault.Crash == CrashAfter {
+			injector.crashNow()
+		}
+		if fault.DropResponse && err == nil {
+			return status.Errorf(codes.Unavailable, "fault injected into %s, the response was dropped", method)
+		}
+		return err
+	}
+}
+
+// StreamClientInterceptor faults the streams that match the rules when they are opened.
+func (injector *Injector) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, conn *grpc.ClientConn, fullMethod string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		method := authz.ShortMethod(fullMethod)
+		if fault := injector.match(method, conn.Target()); fault != nil {
+			if err := injector.before(ctx, fault, method); err != nil {
+				return nil, err
+			}
+		}
+		return streamer(ctx, desc, conn, fullMethod, opts...)
+	}
+}
+
+// DialOptions are the client interceptors as options for grpc.Dial.
+func (injector *Injector) DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(injector.UnaryClientInterceptor()),
+		grpc.WithChainStreamInterceptor(injector.StreamClientInterceptor()),
+	}
+}
+
+func callerAddress(ctx context.Context) string {
+	if caller, ok := peer.FromContext(ctx); ok && caller.Addr != nil {
+		return caller.Addr.String()
+	}
+	return ""
+}
+
+// ToProto converts the rules for the Admin service.
+func ToProto(faultRules []config.FaultRule) *proto.FaultRules {
+	rules := &proto.FaultRules{}
+	for _, faultRule := range faultRules {
+		rules.Rules = append(rules.Rules, &proto.FaultRule{
+			Method:             faultRule.Method,
+			Peer:               faultRule.Peer,
+			Probability:        faultRule.Probability,
+			Count:              int32(faultRule.Count),
+			LatencyNanoseconds: int64(faultRule.Latency),
+			Code:               faultRule.Code,
+			DropResponse:       faultRule.DropResponse,
+			Crash:              faultRule.Crash,
+		})
+	}
+	return rules
+}
+
+// FromProto converts the rules from the Admin service.
+func FromProto(rules *proto.FaultRules) []config.FaultRule {
+	var faultRules []config.FaultRule
+	for _, rule := range rules.Rules {
+		faultRules = append(faultRules, config.FaultRule{
+			Method:       rule.Method,
+			Peer:         rule.Peer,
+			Probability:  rule.Probability,
+			Count:        int(rule.Count),
+			Latency:      config.Duration(rule.LatencyNanoseconds),
+			Code:         rule.Code,
+			DropResponse: rule.DropResponse,
+			Crash:        rule.Crash,
+		})
+	}
+	return faultRules
+}
@@ -0,0 +1,47 @@
+package faults
+
+import (
+	"Auction/config"
+	"testing"
+	"time"
+)
+
+func TestRulesLeaveOutUsedUpRules(t *testing.T) {
+	injector, err := New(config.Faults{Rules: []config.FaultRule{
+		{Method: "Auction/Bid", Count: 2, Code: "UNAVAILABLE"},
+		{Method: "Auction/GetResult", Latency: config.Duration(time.Millisecond)},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fault := injector.match("Auction/Bid", ""); fault == nil {
+		t.Fatal("the first bid isn't faulted")
+	}
+	if rules := injector.Rules(); len(rules) != 2 || rules[0].Count != 1 {
+		t.Fatalf("the rules after one fault are %+v, want the bid rule with 1 call left", rules)
+	}
+	injector.match("Auction/Bid", "")
+	if fault := injector.match("Auction/Bid", ""); fault != nil {
+		t.Fatal("the third bid is faulted by a rule of 2 calls")
+	}
+
+	//A used up rule must not come back as a rule without a limit
+	rules := injector.Rules()
+	if len(rules) != 1 || rules[0].Method != "Auction/GetResult" {
+		t.Fatalf("the rules are %+v, want only the GetResult rule", rules)
+	}
+	if err := injector.SetRules(FromProto(ToProto(rules))); err != nil {
+		t.Fatal(err)
+	}
+	if fault := injector.match("Auction/Bid", ""); fault != nil {
+		t.Fatal("the used up rule faults bids again after a round trip")
+	}
+}
+
+func TestProtoKeepsTheLatency(t *testing.T) {
+	rules := []config.FaultRule{{Method: "*", Latency: config.Duration(1500 * time.Microsecond), Count: 3, Code: "ABORTED"}}
+	got := FromProto(ToProto(rules))
+	if len(got) != 1 || got[0] != rules[0] {
+		t.Fatalf("the rules after a round trip are %+v, want %+v", got, rules)
+	}
+}
@@ -0,0 +1,102 @@
+package frontend
+
+import (
+	"Auction/config"
+	proto "Auction/grpc"
+	"Auction/logging"
+	"Auction/signing"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// ErrNoQuorum is returned in the byzantine fault tolerant mode, when too few servers signed the same answer.
+var ErrNoQuorum = errors.New("not enough servers signed the same answer")
+
+// The number of lying servers that can be tolerated, with 3f+1 trusted servers
+func (client *AuctionClient) faultyServers() int {
+	return (len(client.options.serverKeys) - 1) / 3
+}
+
+// Asks the servers for their signed result, until f+1 different servers signed the same result
+// A server can only sign once for the nonce, so a lying server can't sign for the others
+func (client *AuctionClient) quorumResult(ctx context.Context) (*proto.Outcome, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	quorum := client.faultyServers() + 1
+	ctx = logging.EnsureRequestId(ctx)
+
+	var outcome *proto.Outcome
+	err := client.retry(ctx, func() error {
+		votes := make(map[string]map[string]bool)
+		answered := false
+		for _, replica := range client.snapshot() {
+			var signed *proto.SignedOutcome
+			err := client.call(ctx, replica, func(ctx context.Context, auction proto.AuctionClient) (err error) {
+				signed, err = auction.GetSignedResult(ctx, &proto.ResultRequest{Nonce: nonce})
+				return err
+			})
+			if err != nil {
+				if client.isFailure(ctx, err) {
+					client.drop(replica, err)
+				}
+				continue
+			}
+			answered = true
+			if err := signing.VerifyOutcome(signed, nonce, client.options.serverKeys); err != nil {
+				client.options.logger.WarnContext(ctx, "Ignoring the result of the replication manager", "address", replica.address, "err", err)
+				continue
+			}
+			answer := fmt.Sprintf("%d %q", signed.Outcome.HighestBid, signed.Outcome.Winner)
+			if votes[answer] == nil {
+				votes[answer] = make(map[string]bool)
+			}
+			votes[answer][signed.Server] = true
+			if len(votes[answer]) >= quorum {
+				outcome = signed.Outcome
+				return nil
+			}
+		}
+		if !answered {
+			return ErrNoReplica
+		}
+		return ErrNoQuorum
+	})
+	return outcome, err
+}
+
+// Picks the answer to the bid that f+1 different servers signed a receipt for
+// The receipts must be for this bid of this bidder in this auction, so a receipt for another bid can't be sent instead
+func (client *AuctionClient) quorumAck(bidMessage *proto.BidMessage, acks []*proto.Acknowledgement) (*proto.Acknowledgement, error) {
+	quorum := client.faultyServers() + 1
+	votes := make(map[string]map[string]bool)
+	for _, ack := range acks {
+		receipt := ack.Receipt
+		if receipt == nil || receipt.Status != ack.Status || receipt.Auction != config.DefaultAuction {
+			continue
+		}
+		if receipt.Amount != bidMessage.Amount || receipt.BidTimestamp != bidMessage.Timestamp {
+			continue
+		}
+		//With authentication the id can be left out, then the token says who bids, and the servers must agree on the bidder
+		if bidMessage.Id != "" && receipt.Bidder != bidMessage.Id {
+			continue
+		}
+		if err := signing.VerifyReceipt(receipt, client.options.serverKeys, nil); err != nil {
+			client.options.logger.Warn("Ignoring a receipt", "err", err)
+			continue
+		}
+		answer := fmt.Sprintf("%q %q", ack.Status, receipt.Bidder)
+		if votes[answer] == nil {
+			votes[answer] = make(map[string]bool)
+		}
+		votes[answer][receipt.Server] = true
+		if len(votes[answer]) >= quorum {
+			return ack, nil
+		}
+	}
+	return nil, ErrNoQuorum
+}
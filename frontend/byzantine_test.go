@@ -0,0 +1,88 @@
+package frontend
+
+import (
+	"Auction/config"
+	proto "Auction/grpc"
+	"Auction/signing"
+	"crypto/ed25519"
+	"errors"
+	"testing"
+)
+
+// A client that trusts four servers, so two receipts are a quorum, and the keys of the servers
+func byzantineClient(t *testing.T) (*AuctionClient, []ed25519.PrivateKey) {
+	client := &AuctionClient{options: defaultOptions()}
+	var keys []ed25519.PrivateKey
+	for i := 0; i < 4; i++ {
+		publicKey, privateKey, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.options.serverKeys = append(client.options.serverKeys, publicKey)
+		keys = append(keys, privateKey)
+	}
+	return client, keys
+}
+
+// The answer of a server, with a receipt it signed for the bid, changed by change before it is signed
+func signedAck(key ed25519.PrivateKey, bidder string, bidMessage *proto.BidMessage, change func(*proto.Receipt)) *proto.Acknowledgement {
+	receipt := &proto.Receipt{
+		Status:       "success",
+		Auction:      config.DefaultAuction,
+		Bidder:       bidder,
+		Amount:       bidMessage.Amount,
+		BidTimestamp: bidMessage.Timestamp,
+	}
+	if change != nil {
+		change(receipt)
+	}
+	signing.SignReceipt(key, receipt)
+	return &proto.Acknowledgement{Status: receipt.Status, Receipt: receipt}
+}
+
+func TestQuorumAck(t *testing.T) {
+	client, keys := byzantineClient(t)
+	bid := &proto.BidMessage{Id: "alice", Amount: 10, Timestamp: 1000}
+	anonymous := &proto.BidMessage{Amount: 10, Timestamp: 1000}
+	otherBidder := func(receipt *proto.Receipt) { receipt.Bidder = "mallory" }
+	otherAuction := func(receipt *proto.Receipt) { receipt.Auction = "other" }
+	otherAmount := func(receipt *proto.Receipt) { receipt.Amount = 11 }
+
+	tests := []struct {
+		name       string
+		bidMessage *proto.BidMessage
+		acks       []*proto.Acknowledgement
+		quorum     bool
+	}{
+		{"two receipts for the bid", bid, []*proto.Acknowledgement{signedAck(keys[0], "alice", bid, nil), signedAck(keys[1], "alice", bid, nil)}, true},
+		{"one receipt for the bid", bid, []*proto.Acknowledgement{signedAck(keys[0], "alice", bid, nil)}, false},
+		{"the same server twice", bid, []*proto.Acknowledgement{signedAck(keys[0], "alice", bid, nil), signedAck(keys[0], "alice", bid, nil)}, false},
+		{"a receipt for another bidder", bid, []*proto.Acknowledgement{signedAck(keys[0], "alice", bid, nil), signedAck(keys[1], "mallory", bid, otherBidder)}, false},
+		{"a receipt for another auction", bid, []*proto.Acknowledgement{signedAck(keys[0], "alice", bid, nil), signedAck(keys[1], "alice", bid, otherAuction)}, false},
+		{"a receipt for another amount", bid, []*proto.Acknowledgement{signedAck(keys[0], "alice", bid, nil), signedAck(keys[1], "alice", bid, otherAmount)}, false},
+		{"a bid without id and two receipts for the same bidder", anonymous, []*proto.Acknowledgement{signedAck(keys[0], "alice", anonymous, nil), signedAck(keys[1], "alice", anonymous, nil)}, true},
+		{"a bid without id and receipts for different bidders", anonymous, []*proto.Acknowledgement{signedAck(keys[0], "alice", anonymous, nil), signedAck(keys[1], "mallory", anonymous, nil)}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ack, err := client.quorumAck(test.bidMessage, test.acks)
+			if test.quorum && err != nil {
+				t.Fatalf("expected a quorum, got %v", err)
+			}
+			if !test.quorum && !errors.Is(err, ErrNoQuorum) {
+				t.Fatalf("expected ErrNoQuorum, got %v and %v", ack, err)
+			}
+		})
+	}
+}
+
+func TestQuorumAckRejectsChangedReceipt(t *testing.T) {
+	client, keys := byzantineClient(t)
+	bid := &proto.BidMessage{Id: "alice", Amount: 10, Timestamp: 1000}
+	forged := signedAck(keys[1], "mallory", bid, nil)
+	//The bidder is changed after signing, so the receipt names the bidder but its signature doesn't
+	forged.Receipt.Bidder = "alice"
+	if _, err := client.quorumAck(bid, []*proto.Acknowledgement{signedAck(keys[0], "alice", bid, nil), forged}); !errors.Is(err, ErrNoQuorum) {
+		t.Fatalf("expected ErrNoQuorum, got %v", err)
+	}
+}
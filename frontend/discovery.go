@@ -0,0 +1,38 @@
+package frontend
+
+import (
+	"context"
+	"fmt"
+)
+
+// Discovery finds the addresses of the replication managers.
+// It is asked again whenever every known replication manager has failed.
+type Discovery interface {
+	Replicas(ctx context.Context) ([]string, error)
+}
+
+// DiscoveryFunc lets an ordinary function be used as a Discovery.
+type DiscoveryFunc func(ctx context.Context) ([]string, error)
+
+func (discover DiscoveryFunc) Replicas(ctx context.Context) ([]string, error) {
+	return discover(ctx)
+}
+
+// StaticDiscovery always returns the same addresses, in the given order.
+type StaticDiscovery []string
+
+func (addresses StaticDiscovery) Replicas(ctx context.Context) ([]string, error) {
+	return addresses, nil
+}
+
+// LocalPorts returns a StaticDiscovery of replication managers on localhost.
+func LocalPorts(ports ...int32) StaticDiscovery {
+	addresses := make(StaticDiscovery, len(ports))
+	for i, port := range ports {
+		addresses[i] = fmt.Sprintf("localhost:%d", port)
+	}
+	return addresses
+}
+
+// DefaultReplicas are the three replication managers started by `go run . 0/1/2` in the server folder.
+var DefaultReplicas = LocalPorts(5000, 5001, 5002)
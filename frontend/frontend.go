@@ -0,0 +1,522 @@
+// Package frontend is the frontend of the auction application as a library.
+//
+// An AuctionClient sends every bid to all replication managers, in the same order, and reads results
+// from the first replication manager that answers. When a replication manager fails, it is dropped,
+// and when all of them have failed, the discovery is asked for the replication managers again.
+package frontend
+
+import (
+	"Auction/auth"
+	proto "Auction/grpc"
+	"Auction/logging"
+	"Auction/signing"
+	"Auction/tracing"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ErrNoReplica is returned when no replication manager could answer a request, after all retries.
+var ErrNoReplica = errors.New("no replication manager is reachable")
+
+type replica struct {
+	address string
+	conn    *grpc.ClientConn
+	auction proto.AuctionClient
+	admin   proto.AdminClient
+}
+
+// AuctionClient is safe for concurrent use. Bids are sent one at a time, so every replication manager
+// receives them in the same order.
+type AuctionClient struct {
+	options    options
+	writeMutex sync.Mutex
+	mutex      sync.Mutex
+	replicas   []*replica
+	token      string
+	metrics    *frontendMetrics
+}
+
+// New creates an AuctionClient and connects to the replication managers found by the discovery.
+// Connecting doesn't block, so the replication managers don't have to be running yet.
+func New(opts ...Option) (*AuctionClient, error) {
+	client := &AuctionClient{options: defaultOptions()}
+	for _, opt := range opts {
+		opt(&client.options)
+	}
+	client.token = client.options.token
+	if client.options.metrics != nil {
+		client.metrics = newFrontendMetrics(client, client.options.metrics)
+	}
+	if err := client.refresh(context.Background()); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Login checks the password of the bidder and returns a token for them.
+// The client sends the token with all later requests, unless the context of a request already has one.
+func (client *AuctionClient) Login(ctx context.Context, bidder string, password string) (*proto.Token, error) {
+	var token *proto.Token
+	err := client.askFirst(ctx, func(ctx context.Context, auction proto.AuctionClient) (err error) {
+		token, err = auction.Login(ctx, &proto.LoginRequest{Id: bidder, Password: password})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	client.mutex.Lock()
+	client.token = token.Token
+	client.mutex.Unlock()
+	return token, nil
+}
+
+// Bid sends the bid to all replication managers and returns the acknowledgement of the last one that answered.
+// With a signing key the bid is signed first.
+func (client *AuctionClient) Bid(ctx context.Context, bidder string, amount int32) (*proto.Acknowledgement, error) {
+	bidMessage := &proto.BidMessage{Id: bidder, Amount: amount, Timestamp: client.options.clock.Now().UnixMilli()}
+	if client.options.signingKey != nil {
+		signing.SignBid(client.options.signingKey, bidMessage)
+	}
+	return client.PlaceBid(ctx, bidMessage)
+}
+
+// PlaceBid sends the bid message as it is to all replication managers, fx a bid that the bidder signed themselves.
+// An unsigned bid without a timestamp gets the current time.
+func (client *AuctionClient) PlaceBid(ctx context.Context, bidMessage *proto.BidMessage) (*proto.Acknowledgement, error) {
+	if bidMessage.Signature == nil && bidMessage.Timestamp == 0 {
+		bidMessage = &proto.BidMessage{Id: bidMessage.Id, Amount: bidMessage.Amount, Timestamp: client.options.clock.Now().UnixMilli()}
+	}
+	if err := client.limitBidder(bidMessage.Id); err != nil {
+		return nil, err
+	}
+	var acks []*proto.Acknowledgement
+	err := client.sendToAll(ctx, func(ctx context.Context, auction proto.AuctionClient) error {
+		ack, err := auction.Bid(ctx, bidMessage)
+		if err == nil {
+			acks = append(acks, ack)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	//Without the byzantine fault tolerant mode, the servers are trusted, and the last answer is returned
+	if client.options.serverKeys != nil {
+		return client.quorumAck(bidMessage, acks)
+	}
+	return acks[len(acks)-1], nil
+}
+
+// Rejects the write of a bidder who bids too fast, before it is sent to the replication managers
+func (client *AuctionClient) limitBidder(bidder string) error {
+	if client.options.rateLimits == nil {
+		return nil
+	}
+	return client.options.rateLimits.CheckBidAt(bidder, client.options.clock.Now())
+}
+
+// Commit sends a sealed bid to all replication managers, see the sealed package for how to make the commitment.
+// It returns the acknowledgement of the last one that accepted it.
+func (client *AuctionClient) Commit(ctx context.Context, bidder string, commitment []byte) (*proto.Acknowledgement, error) {
+	if err := client.limitBidder(bidder); err != nil {
+		return nil, err
+	}
+	sentAt := client.options.clock.Now().UnixMilli()
+	var ack *proto.Acknowledgement
+	err := client.sendToAll(ctx, func(ctx context.Context, auction proto.AuctionClient) error {
+		answer, err := auction.CommitBid(ctx, &proto.Commitment{Id: bidder, Commitment: commitment, Timestamp: sentAt})
+		if err == nil {
+			ack = answer
+		}
+		return err
+	})
+	return ack, err
+}
+
+// Reveal discloses a sealed bid to all replication managers, in the reveal window after the bidding.
+// It returns the acknowledgement of the last one that accepted it.
+func (client *AuctionClient) Reveal(ctx context.Context, bidder string, amount int32, nonce []byte) (*proto.Acknowledgement, error) {
+	if err := client.limitBidder(bidder); err != nil {
+		return nil, err
+	}
+	sentAt := client.options.clock.Now().UnixMilli()
+	var ack *proto.Acknowledgement
+	err := client.sendToAll(ctx, func(ctx context.Context, auction proto.AuctionClient) error {
+		answer, err := auction.RevealBid(ctx, &proto.Reveal{Id: bidder, Amount: amount, Nonce: nonce, Timestamp: sentAt})
+		if err == nil {
+			ack = answer
+		}
+		return err
+	})
+	return ack, err
+}
+
+// CloseAuction ends the auction now on all replication managers, the highest bidder wins.
+// A sealed auction goes on to its reveal window, and closing it again ends the reveal window.
+// It returns the auction of the last one that closed it.
+func (client *AuctionClient) CloseAuction(ctx context.Context) (*proto.AuctionInfo, error) {
+	var info *proto.AuctionInfo
+	err := client.sendToAll(ctx, func(ctx context.Context, auction proto.AuctionClient) error {
+		answer, err := auction.CloseAuction(ctx, &proto.Empty{})
+		if err == nil {
+			info = answer
+		}
+		return err
+	})
+	return info, err
+}
+
+// CancelAuction ends the auction without a winner on all replication managers.
+// It returns the auction of the last one that cancelled it.
+func (client *AuctionClient) CancelAuction(ctx context.Context) (*proto.AuctionInfo, error) {
+	var info *proto.AuctionInfo
+	err := client.sendToAll(ctx, func(ctx context.Context, auction proto.AuctionClient) error {
+		answer, err := auction.CancelAuction(ctx, &proto.Empty{})
+		if err == nil {
+			info = answer
+		}
+		return err
+	})
+	return info, err
+}
+
+// Ban stops the bidder from bidding until the duration has passed, on all replication managers.
+// The end of the ban is decided here, so every replication manager lifts it at the same time. A duration of 0 lifts the ban.
+func (client *AuctionClient) Ban(ctx context.Context, bidder string, duration time.Duration) (*proto.Acknowledgement, error) {
+	banRequest := &proto.BanRequest{Id: bidder}
+	if duration > 0 {
+		banRequest.Until = client.options.clock.Now().Add(duration).UnixMilli()
+	}
+	var ack *proto.Acknowledgement
+	err := client.sendToAll(ctx, func(ctx context.Context, auction proto.AuctionClient) error {
+		answer, err := auction.BanBidder(ctx, banRequest)
+		if err == nil {
+			ack = answer
+		}
+		return err
+	})
+	return ack, err
+}
+
+// Result returns the highest bid, and the winner if the auction is over.
+// In the byzantine fault tolerant mode, it is the result that f+1 servers signed.
+func (client *AuctionClient) Result(ctx context.Context) (*proto.Outcome, error) {
+	if client.options.serverKeys != nil {
+		return client.quorumResult(ctx)
+	}
+	var outcome *proto.Outcome
+	err := client.askFirst(ctx, func(ctx context.Context, auction proto.AuctionClient) (err error) {
+		outcome, err = auction.GetResult(ctx, &proto.Empty{})
+		return err
+	})
+	return outcome, err
+}
+
+// History returns every accepted bid in the order it was accepted.
+func (client *AuctionClient) History(ctx context.Context) (*proto.BidHistory, error) {
+	var history *proto.BidHistory
+	err := client.askFirst(ctx, func(ctx context.Context, auction proto.AuctionClient) (err error) {
+		history, err = auction.GetBidHistory(ctx, &proto.Empty{})
+		return err
+	})
+	return history, err
+}
+
+// Auctions returns the state of the auctions.
+func (client *AuctionClient) Auctions(ctx context.Context) (*proto.AuctionList, error) {
+	var auctions *proto.AuctionList
+	err := client.askFirst(ctx, func(ctx context.Context, auction proto.AuctionClient) (err error) {
+		auctions, err = auction.ListAuctions(ctx, &proto.Empty{})
+		return err
+	})
+	return auctions, err
+}
+
+// Account returns the balance and holds of the bidder.
+func (client *AuctionClient) Account(ctx context.Context, bidder string) (*proto.Account, error) {
+	var account *proto.Account
+	err := client.askFirst(ctx, func(ctx context.Context, auction proto.AuctionClient) (err error) {
+		account, err = auction.GetAccount(ctx, &proto.AccountRequest{Id: bidder})
+		return err
+	})
+	return account, err
+}
+
+// Watch calls onUpdate with the current state of the auction and then every time it changes,
+// until the auction is over or the context is done. If the watched replication manager fails,
+// the next one is watched instead, which starts with its current state again.
+func (client *AuctionClient) Watch(ctx context.Context, onUpdate func(*proto.AuctionInfo)) error {
+	for {
+		var stream proto.Auction_WatchClient
+		var watched proto.AuctionClient
+		err := client.askFirst(ctx, func(callCtx context.Context, auction proto.AuctionClient) (err error) {
+			//The stream must live as long as the watch, so it doesn't get the timeout of a single call,
+			//but it is traced as part of the call
+			watched = auction
+			streamCtx := ctx
+			if spanContext, ok := tracing.SpanContextFromContext(callCtx); ok {
+				streamCtx = tracing.ContextWithSpanContext(ctx, spanContext)
+			}
+			stream, err = auction.Watch(client.outgoing(streamCtx), &proto.Empty{})
+			if err != nil {
+				return err
+			}
+			//The first snapshot is sent at once, so a lost replication manager is noticed here
+			info, err := stream.Recv()
+			if err != nil {
+				return err
+			}
+			onUpdate(info)
+			if info.IsOver {
+				stream = nil
+			}
+			return nil
+		})
+		if err != nil || stream == nil {
+			return err
+		}
+
+		for {
+			info, err := stream.Recv()
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				if !client.isFailure(ctx, err) {
+					return err
+				}
+				//Watch the next replication manager instead
+				client.dropAuction(watched, err)
+				break
+			}
+			onUpdate(info)
+			if info.IsOver {
+				return nil
+			}
+		}
+	}
+}
+
+// Close closes the connections to all replication managers.
+func (client *AuctionClient) Close() error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	var errs []error
+	for _, replica := range client.replicas {
+		errs = append(errs, replica.conn.Close())
+	}
+	client.replicas = nil
+	return errors.Join(errs...)
+}
+
+// Sends a request that changes the auction to all replication managers, one request at a time,
+// so every replication manager applies them in the same order
+// The request succeeds if any replication manager accepted it, if none did the first error is returned
+func (client *AuctionClient) sendToAll(ctx context.Context, request func(context.Context, proto.AuctionClient) error) error {
+	ctx = logging.EnsureRequestId(ctx)
+	ctx, span := client.options.tracer.Start(ctx, "frontend.sendToAll")
+	defer span.End()
+	client.writeMutex.Lock()
+	defer client.writeMutex.Unlock()
+
+	err := client.retry(ctx, func() error {
+		var firstError error
+		succeeded := false
+		for _, replica := range client.snapshot() {
+			err := client.call(ctx, replica, request)
+			switch {
+			case err == nil:
+				succeeded = true
+			case client.isFailure(ctx, err):
+				client.drop(replica, err)
+			case firstError == nil:
+				firstError = err
+			}
+		}
+		if succeeded {
+			return nil
+		}
+		if firstError != nil {
+			return firstError
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return ErrNoReplica
+	})
+	span.SetError(err)
+	return err
+}
+
+// Sends a request to the first replication manager
+// Since the first RM is always the first to be updated, it will always be the one with the most up-to-date result
+// If the first RM is down, it is dropped and the next RM is asked
+func (client *AuctionClient) askFirst(ctx context.Context, request func(context.Context, proto.AuctionClient) error) error {
+	ctx = logging.EnsureRequestId(ctx)
+	ctx, span := client.options.tracer.Start(ctx, "frontend.askFirst")
+	defer span.End()
+	err := client.retry(ctx, func() error {
+		for {
+			replicas := client.snapshot()
+			if len(replicas) == 0 {
+				return ErrNoReplica
+			}
+			err := client.call(ctx, replicas[0], request)
+			if err == nil || !client.isFailure(ctx, err) {
+				return err
+			}
+			client.drop(replicas[0], err)
+		}
+	})
+	span.SetError(err)
+	return err
+}
+
+// Runs the request, and if no replication manager could answer it, waits for the backoff,
+// asks the discovery for the replication managers again and runs the request again
+func (client *AuctionClient) retry(ctx context.Context, request func() error) error {
+	backoff := client.options.backoff
+	for attempt := 0; ; attempt++ {
+		err := request()
+		if !errors.Is(err, ErrNoReplica) || attempt >= client.options.retries {
+			return err
+		}
+		client.options.logger.WarnContext(ctx, "No replication manager answered, trying again", "backoff", backoff)
+		if client.metrics != nil {
+			client.metrics.retries.Inc()
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+		if err := client.refresh(ctx); err != nil {
+			client.options.logger.WarnContext(ctx, "Could not discover the replication managers", "err", err)
+		}
+	}
+}
+
+// Asks the discovery for the replication managers and connects to the ones that aren't connected
+func (client *AuctionClient) refresh(ctx context.Context) error {
+	addresses, err := client.options.discovery.Replicas(ctx)
+	if err != nil {
+		return err
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	connected := make(map[string]*replica)
+	for _, replica := range client.replicas {
+		connected[replica.address] = replica
+	}
+	replicas := make([]*replica, 0, len(addresses))
+	for _, address := range addresses {
+		if existing, ok := connected[address]; ok {
+			replicas = append(replicas, existing)
+			continue
+		}
+		conn, err := grpc.Dial(address, client.dialOptions()...)
+		if err != nil {
+			client.options.logger.Warn("Could not dial the replication manager", "address", address, "err", err)
+			continue
+		}
+		//Dialing doesn't wait for the connection, so the replication manager may not be up yet
+		client.options.logger.Info("Added replication manager", "address", address)
+		replicas = append(replicas, newReplica(address, conn))
+	}
+	client.replicas = replicas
+	return nil
+}
+
+func newReplica(address string, conn *grpc.ClientConn) *replica {
+	return &replica{address: address, conn: conn, auction: proto.NewAuctionClient(conn), admin: proto.NewAdminClient(conn)}
+}
+
+// The options to dial a replication manager with, with the interceptors of the fault injection after the given options
+func (client *AuctionClient) dialOptions() []grpc.DialOption {
+	if client.options.faults == nil {
+		return client.options.dialOptions
+	}
+	return append(append([]grpc.DialOption(nil), client.options.dialOptions...), client.options.faults.DialOptions()...)
+}
+
+// Returns a copy of the replication managers, so they can be called without holding the mutex
+func (client *AuctionClient) snapshot() []*replica {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	replicas := make([]*replica, len(client.replicas))
+	copy(replicas, client.replicas)
+	return replicas
+}
+
+// Removes a failed replication manager and closes the connection to it
+func (client *AuctionClient) drop(failed *replica, err error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	for i, replica := range client.replicas {
+		if replica == failed {
+			client.options.logger.Warn("Dropped replication manager", "address", failed.address, "err", err)
+			if client.metrics != nil {
+				client.metrics.failovers.Inc(failed.address)
+			}
+			client.replicas = append(client.replicas[:i:i], client.replicas[i+1:]...)
+			failed.conn.Close()
+			return
+		}
+	}
+}
+
+func (client *AuctionClient) dropAuction(auction proto.AuctionClient, err error) {
+	for _, replica := range client.snapshot() {
+		if replica.auction == auction {
+			client.drop(replica, err)
+		}
+	}
+}
+
+func (client *AuctionClient) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = client.outgoing(ctx)
+	if client.options.callTimeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, client.options.callTimeout)
+}
+
+// Adds the request id, the trace and the token from Login to the metadata of the context,
+// if it doesn't have a token already
+func (client *AuctionClient) outgoing(ctx context.Context) context.Context {
+	ctx = tracing.Inject(logging.Inject(ctx))
+	client.mutex.Lock()
+	token := client.token
+	client.mutex.Unlock()
+
+	if md, ok := metadata.FromOutgoingContext(ctx); token == "" || (ok && len(md.Get("authorization")) > 0) {
+		return ctx
+	}
+	return auth.WithToken(ctx, token)
+}
+
+// Tells whether an error means the replication manager is lost, and not that it rejected the request
+// or that the caller gave up on it
+func (client *AuctionClient) isFailure(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package frontend
+
+import (
+	proto "Auction/grpc"
+	"Auction/metrics"
+	"context"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// The metrics of the fan-out to the replication managers, nil when the frontend has no registry
+type frontendMetrics struct {
+	calls     *metrics.Counter
+	duration  *metrics.Histogram
+	failovers *metrics.Counter
+	retries   *metrics.Counter
+}
+
+func newFrontendMetrics(client *AuctionClient, registry *metrics.Registry) *frontendMetrics {
+	registry.GaugeFunc("auction_frontend_replicas", "The number of replication managers the frontend is connected to.", nil,
+		func(set func(float64, ...string)) {
+			set(float64(len(client.snapshot())))
+		})
+	return &frontendMetrics{
+		calls:     registry.Counter("auction_frontend_calls_total", "Calls from the frontend to each replication manager by the grpc status code.", "replica", "code"),
+		duration:  registry.Histogram("auction_frontend_call_duration_seconds", "The time each replication manager takes to answer the frontend.", metrics.DefaultBuckets, "replica"),
+		failovers: registry.Counter("auction_frontend_failovers_total", "Replication managers that were dropped by the frontend because they failed.", "replica"),
+		retries:   registry.Counter("auction_frontend_retries_total", "Times the frontend waited and asked the discovery again, because no replication manager answered."),
+	}
+}
+
+// Calls a replication manager with the timeout of the calls, and measures, logs and traces the call
+func (client *AuctionClient) call(ctx context.Context, replica *replica, request func(context.Context, proto.AuctionClient) error) error {
+	ctx, span := client.options.tracer.Start(ctx, "frontend.call")
+	defer span.End()
+	span.SetAttribute("replica", replica.address)
+	callCtx, cancel := client.callContext(ctx)
+	defer cancel()
+	start := time.Now()
+	err := request(callCtx, replica.auction)
+	if frontendMetrics := client.metrics; frontendMetrics != nil {
+		frontendMetrics.calls.Inc(replica.address, status.Code(err).String())
+		frontendMetrics.duration.Observe(time.Since(start).Seconds(), replica.address)
+	}
+	client.options.logger.DebugContext(ctx, "Called replication manager", "address", replica.address, "code", status.Code(err).String(), "duration", time.Since(start))
+	span.SetAttribute("code", status.Code(err).String())
+	span.SetError(err)
+	return err
+}
@@ -0,0 +1,174 @@
+package frontend
+
+import (
+	"Auction/clock"
+	"Auction/config"
+	"Auction/faults"
+	"Auction/logging"
+	"Auction/metrics"
+	"Auction/ratelimit"
+	"Auction/signing"
+	"Auction/tracing"
+	"crypto/ed25519"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type options struct {
+	discovery   Discovery
+	retries     int
+	backoff     time.Duration
+	callTimeout time.Duration
+	dialOptions []grpc.DialOption
+	logger      *slog.Logger
+	token       string
+	signingKey  ed25519.PrivateKey
+	serverKeys  []ed25519.PublicKey
+	metrics     *metrics.Registry
+	tracer      *tracing.Tracer
+	clock       clock.Clock
+	faults      *faults.Injector
+	rateLimits  *ratelimit.Limits
+}
+
+func defaultOptions() options {
+	return options{
+		discovery:   DefaultReplicas,
+		retries:     2,
+		backoff:     500 * time.Millisecond,
+		callTimeout: 5 * time.Second,
+		dialOptions: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		logger:      logging.Discard(),
+		clock:       clock.Real,
+	}
+}
+
+// Option configures an AuctionClient.
+type Option func(*options)
+
+// WithDiscovery sets how the replication managers are found, the default is DefaultReplicas.
+func WithDiscovery(discovery Discovery) Option {
+	return func(o *options) {
+		o.discovery = discovery
+	}
+}
+
+// WithRetries sets how many more times a request is tried when no replication manager could answer it.
+// Before every retry the client waits for the backoff, which doubles each time, and asks the discovery again.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.retries = retries
+		o.backoff = backoff
+	}
+}
+
+// WithCallTimeout limits how long a single call to one replication manager may take.
+// Zero means no limit besides the context of the request.
+func WithCallTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.callTimeout = timeout
+	}
+}
+
+// WithDialOptions replaces the options used to dial the replication managers,
+// the default is a connection without transport security.
+func WithDialOptions(dialOptions ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dialOptions = dialOptions
+	}
+}
+
+// WithLogger makes the client log when it loses or finds replication managers, and every call at the debug level.
+// The default is to log nothing.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithToken makes the client send the token with every request, as if Login had returned it.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithSigningKey signs every bid sent by Bid with the Ed25519 key of the bidder.
+func WithSigningKey(privateKey ed25519.PrivateKey) Option {
+	return func(o *options) {
+		o.signingKey = privateKey
+	}
+}
+
+// WithServerKeys turns on the byzantine fault tolerant mode, for servers that can't be trusted to tell the truth.
+// With the keys of 3f+1 servers, a result or the answer to a bid is only accepted when f+1 of the servers signed it,
+// so up to f lying servers can't fake a winner. The servers must sign their answers, see replica.WithReceiptKey.
+func WithServerKeys(serverKeys []ed25519.PublicKey) Option {
+	return func(o *options) {
+		o.serverKeys = serverKeys
+	}
+}
+
+// WithMetrics registers the metrics of the calls to the replication managers and of the failovers in the registry.
+func WithMetrics(registry *metrics.Registry) Option {
+	return func(o *options) {
+		o.metrics = registry
+	}
+}
+
+// WithTracer records spans for the requests and for every call to a replication manager,
+// and sends the trace context with the calls, so the replication managers continue the trace.
+func WithTracer(tracer *tracing.Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// WithClock sets the clock that the timestamps of the bids and the ends of bans are taken from,
+// which should be the clock of the replication managers. The default is the clock of the machine.
+func WithClock(auctionClock clock.Clock) Option {
+	return func(o *options) {
+		o.clock = auctionClock
+	}
+}
+
+// WithFaults injects the faults of the injector into the calls to the replication managers, for chaos testing.
+// The peer of a rule is then the address of a replication manager.
+func WithFaults(injector *faults.Injector) Option {
+	return func(o *options) {
+		o.faults = injector
+	}
+}
+
+// WithRateLimits rejects bids, commitments and reveals of a bidder who bids faster than the limit of the rate limits section,
+// before they are sent to any replication manager. The replication managers limit the bidders as well.
+func WithRateLimits(rateLimits config.RateLimits) Option {
+	return func(o *options) {
+		o.rateLimits = ratelimit.New(rateLimits)
+	}
+}
+
+// ClusterOptions returns the options for the cluster section of the configuration,
+// the servers of the cluster and their keys for the byzantine fault tolerant mode.
+func ClusterOptions(cluster config.Cluster) ([]Option, error) {
+	var opts []Option
+	if len(cluster.Replicas) > 0 {
+		opts = append(opts, WithDiscovery(StaticDiscovery(cluster.Replicas)))
+	}
+	if len(cluster.ServerKeys) > 0 {
+		serverKeys := make([]ed25519.PublicKey, len(cluster.ServerKeys))
+		for i, encoded := range cluster.ServerKeys {
+			serverKey, err := signing.ParsePublicKey(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("server key %d: %w", i, err)
+			}
+			serverKeys[i] = serverKey
+		}
+		opts = append(opts, WithServerKeys(serverKeys))
+	}
+	return opts, nil
+}
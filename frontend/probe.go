@@ -0,0 +1,94 @@
+package frontend
+
+import (
+	proto "Auction/grpc"
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+)
+
+// ReplicaStatus is the answer of a replication manager to Probe.
+type ReplicaStatus struct {
+	Address string
+	Up      bool
+	// Bids is the number of accepted bids in the history of the replication manager, if Err is nil
+	Bids int
+	Err  error
+}
+
+// ReplicaDigest is the answer of a replication manager to Digests.
+type ReplicaDigest struct {
+	Address string
+	Digest  *proto.StateDigest
+	Err     error
+}
+
+// Probe asks every replication manager found by the discovery for its history, also the ones that were dropped,
+// so it tells which replication managers are up and how far each of them has got.
+func (client *AuctionClient) Probe(ctx context.Context) ([]ReplicaStatus, error) {
+	return callEvery(ctx, client, func(ctx context.Context, replica *replica) ReplicaStatus {
+		var history *proto.BidHistory
+		err := client.call(ctx, replica, func(ctx context.Context, auction proto.AuctionClient) (err error) {
+			history, err = auction.GetBidHistory(ctx, &proto.Empty{})
+			return err
+		})
+		if err != nil {
+			//A replication manager that refuses the call, fx because the caller has no token, is still up
+			return ReplicaStatus{Address: replica.address, Up: !client.isFailure(ctx, err), Err: err}
+		}
+		return ReplicaStatus{Address: replica.address, Up: true, Bids: len(history.Bids)}
+	}, func(address string, err error) ReplicaStatus {
+		return ReplicaStatus{Address: address, Err: err}
+	})
+}
+
+// Digests asks every replication manager found by the discovery for the digest of its state, also the ones that were dropped,
+// so the digests can be compared, see the divergence package.
+func (client *AuctionClient) Digests(ctx context.Context) ([]ReplicaDigest, error) {
+	return callEvery(ctx, client, func(ctx context.Context, replica *replica) ReplicaDigest {
+		var digest *proto.StateDigest
+		err := client.call(ctx, replica, func(ctx context.Context, auction proto.AuctionClient) (err error) {
+			digest, err = replica.admin.GetDigest(ctx, &proto.Empty{})
+			return err
+		})
+		return ReplicaDigest{Address: replica.address, Digest: digest, Err: err}
+	}, func(address string, err error) ReplicaDigest {
+		return ReplicaDigest{Address: address, Err: err}
+	})
+}
+
+// Calls every replication manager found by the discovery at the same time, over a new connection if the frontend isn't connected to it,
+// and returns the answers in the order of the discovery. dialFailed makes the answer of a replication manager that couldn't be dialed
+func callEvery[T any](ctx context.Context, client *AuctionClient, call func(context.Context, *replica) T, dialFailed func(string, error) T) ([]T, error) {
+	addresses, err := client.options.discovery.Replicas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	connected := make(map[string]*replica)
+	for _, replica := range client.snapshot() {
+		connected[replica.address] = replica
+	}
+
+	answers := make([]T, len(addresses))
+	var wait sync.WaitGroup
+	for i, address := range addresses {
+		wait.Add(1)
+		go func(i int, address string) {
+			defer wait.Done()
+			replica := connected[address]
+			if replica == nil {
+				conn, err := grpc.Dial(address, client.dialOptions()...)
+				if err != nil {
+					answers[i] = dialFailed(address, err)
+					return
+				}
+				defer conn.Close()
+				replica = newReplica(address, conn)
+			}
+			answers[i] = call(ctx, replica)
+		}(i, address)
+	}
+	wait.Wait()
+	return answers, nil
+}
@@ -19,18 +19,51 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	Auction_Bid_FullMethodName       = "/Auction.Auction/Bid"
-	Auction_GetResult_FullMethodName = "/Auction.Auction/GetResult"
+	Auction_Bid_FullMethodName             = "/Auction.Auction/Bid"
+	Auction_GetResult_FullMethodName       = "/Auction.Auction/GetResult"
+	Auction_ListAuctions_FullMethodName    = "/Auction.Auction/ListAuctions"
+	Auction_GetBidHistory_FullMethodName   = "/Auction.Auction/GetBidHistory"
+	Auction_Watch_FullMethodName           = "/Auction.Auction/Watch"
+	Auction_Login_FullMethodName           = "/Auction.Auction/Login"
+	Auction_GetAccount_FullMethodName      = "/Auction.Auction/GetAccount"
+	Auction_CloseAuction_FullMethodName    = "/Auction.Auction/CloseAuction"
+	Auction_CancelAuction_FullMethodName   = "/Auction.Auction/CancelAuction"
+	Auction_BanBidder_FullMethodName       = "/Auction.Auction/BanBidder"
+	Auction_GetSignedResult_FullMethodName = "/Auction.Auction/GetSignedResult"
+	Auction_CommitBid_FullMethodName       = "/Auction.Auction/CommitBid"
+	Auction_RevealBid_FullMethodName       = "/Auction.Auction/RevealBid"
 )
 
 // AuctionClient is the client API for Auction service.
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type AuctionClient interface {
-	// given a bid, returns an outcome among {fail, success or exception}
+	//given a bid, returns an outcome among {fail, success or exception}
 	Bid(ctx context.Context, in *BidMessage, opts ...grpc.CallOption) (*Acknowledgement, error)
-	// if the auction is over, it returns the result, else highest bid.
+	//if the auction is over, it returns the result, else highest bid.
 	GetResult(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Outcome, error)
+	//returns the auctions hosted by the replication manager
+	ListAuctions(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*AuctionList, error)
+	//returns every accepted bid in the order it was accepted
+	GetBidHistory(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*BidHistory, error)
+	//streams a snapshot of the auction every time it changes
+	Watch(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Auction_WatchClient, error)
+	//checks the password of the bidder and returns a token, which must be sent with every bid
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*Token, error)
+	//returns the balance and holds of a bidder
+	GetAccount(ctx context.Context, in *AccountRequest, opts ...grpc.CallOption) (*Account, error)
+	//ends the auction now, the highest bidder wins. Only the seller and admins may close it
+	CloseAuction(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*AuctionInfo, error)
+	//ends the auction without a winner and releases all holds. Only admins may cancel it
+	CancelAuction(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*AuctionInfo, error)
+	//stops a bidder from bidding in the auction for a while. Only admins may ban bidders
+	BanBidder(ctx context.Context, in *BanRequest, opts ...grpc.CallOption) (*Acknowledgement, error)
+	//like GetResult, but signed by the server, so the frontend can compare the answers of the servers
+	GetSignedResult(ctx context.Context, in *ResultRequest, opts ...grpc.CallOption) (*SignedOutcome, error)
+	//places a sealed bid in a sealed auction, a later commitment replaces the earlier one
+	CommitBid(ctx context.Context, in *Commitment, opts ...grpc.CallOption) (*Acknowledgement, error)
+	//reveals a sealed bid in the reveal window after the bidding
+	RevealBid(ctx context.Context, in *Reveal, opts ...grpc.CallOption) (*Acknowledgement, error)
 }
 
 type auctionClient struct {
@@ -59,14 +92,158 @@ func (c *auctionClient) GetResult(ctx context.Context, in *Empty, opts ...grpc.C
 	return out, nil
 }
 
+func (c *auctionClient) ListAuctions(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*AuctionList, error) {
+	out := new(AuctionList)
+	err := c.cc.Invoke(ctx, Auction_ListAuctions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *auctionClient) GetBidHistory(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*BidHistory, error) {
+	out := new(BidHistory)
+	err := c.cc.Invoke(ctx, Auction_GetBidHistory_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *auctionClient) Watch(ctx context.Context, in *Empty, opts ...grpc.CallOption) (Auction_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Auction_ServiceDesc.Streams[0], Auction_Watch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &auctionWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Auction_WatchClient interface {
+	Recv() (*AuctionInfo, error)
+	grpc.ClientStream
+}
+
+type auctionWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *auctionWatchClient) Recv() (*AuctionInfo, error) {
+	m := new(AuctionInfo)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *auctionClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*Token, error) {
+	out := new(Token)
+	err := c.cc.Invoke(ctx, Auction_Login_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *auctionClient) GetAccount(ctx context.Context, in *AccountRequest, opts ...grpc.CallOption) (*Account, error) {
+	out := new(Account)
+	err := c.cc.Invoke(ctx, Auction_GetAccount_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *auctionClient) CloseAuction(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*AuctionInfo, error) {
+	out := new(AuctionInfo)
+	err := c.cc.Invoke(ctx, Auction_CloseAuction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *auctionClient) CancelAuction(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*AuctionInfo, error) {
+	out := new(AuctionInfo)
+	err := c.cc.Invoke(ctx, Auction_CancelAuction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *auctionClient) BanBidder(ctx context.Context, in *BanRequest, opts ...grpc.CallOption) (*Acknowledgement, error) {
+	out := new(Acknowledgement)
+	err := c.cc.Invoke(ctx, Auction_BanBidder_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *auctionClient) GetSignedResult(ctx context.Context, in *ResultRequest, opts ...grpc.CallOption) (*SignedOutcome, error) {
+	out := new(SignedOutcome)
+	err := c.cc.Invoke(ctx, Auction_GetSignedResult_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *auctionClient) CommitBid(ctx context.Context, in *Commitment, opts ...grpc.CallOption) (*Acknowledgement, error) {
+	out := new(Acknowledgement)
+	err := c.cc.Invoke(ctx, Auction_CommitBid_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *auctionClient) RevealBid(ctx context.Context, in *Reveal, opts ...grpc.CallOption) (*Acknowledgement, error) {
+	out := new(Acknowledgement)
+	err := c.cc.Invoke(ctx, Auction_RevealBid_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AuctionServer is the server API for Auction service.
 // All implementations must embed UnimplementedAuctionServer
 // for forward compatibility
 type AuctionServer interface {
-	// given a bid, returns an outcome among {fail, success or exception}
+	//given a bid, returns an outcome among {fail, success or exception}
 	Bid(context.Context, *BidMessage) (*Acknowledgement, error)
-	// if the auction is over, it returns the result, else highest bid.
+	//if the auction is over, it returns the result, else highest bid.
 	GetResult(context.Context, *Empty) (*Outcome, error)
+	//returns the auctions hosted by the replication manager
+	ListAuctions(context.Context, *Empty) (*AuctionList, error)
+	//returns every accepted bid in the order it was accepted
+	GetBidHistory(context.Context, *Empty) (*BidHistory, error)
+	//streams a snapshot of the auction every time it changes
+	Watch(*Empty, Auction_WatchServer) error
+	//checks the password of the bidder and returns a token, which must be sent with every bid
+	Login(context.Context, *LoginRequest) (*Token, error)
+	//returns the balance and holds of a bidder
+	GetAccount(context.Context, *AccountRequest) (*Account, error)
+	//ends the auction now, the highest bidder wins. Only the seller and admins may close it
+	CloseAuction(context.Context, *Empty) (*AuctionInfo, error)
+	//ends the auction without a winner and releases all holds. Only admins may cancel it
+	CancelAuction(context.Context, *Empty) (*AuctionInfo, error)
+	//stops a bidder from bidding in the auction for a while. Only admins may ban bidders
+	BanBidder(context.Context, *BanRequest) (*Acknowledgement, error)
+	//like GetResult, but signed by the server, so the frontend can compare the answers of the servers
+	GetSignedResult(context.Context, *ResultRequest) (*SignedOutcome, error)
+	//places a sealed bid in a sealed auction, a later commitment replaces the earlier one
+	CommitBid(context.Context, *Commitment) (*Acknowledgement, error)
+	//reveals a sealed bid in the reveal window after the bidding
+	RevealBid(context.Context, *Reveal) (*Acknowledgement, error)
 	mustEmbedUnimplementedAuctionServer()
 }
 
@@ -80,6 +257,39 @@ func (UnimplementedAuctionServer) Bid(context.Context, *BidMessage) (*Acknowledg
 func (UnimplementedAuctionServer) GetResult(context.Context, *Empty) (*Outcome, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetResult not implemented")
 }
+func (UnimplementedAuctionServer) ListAuctions(context.Context, *Empty) (*AuctionList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAuctions not implemented")
+}
+func (UnimplementedAuctionServer) GetBidHistory(context.Context, *Empty) (*BidHistory, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBidHistory not implemented")
+}
+func (UnimplementedAuctionServer) Watch(*Empty, Auction_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedAuctionServer) Login(context.Context, *LoginRequest) (*Token, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedAuctionServer) GetAccount(context.Context, *AccountRequest) (*Account, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAccount not implemented")
+}
+func (UnimplementedAuctionServer) CloseAuction(context.Context, *Empty) (*AuctionInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CloseAuction not implemented")
+}
+func (UnimplementedAuctionServer) CancelAuction(context.Context, *Empty) (*AuctionInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelAuction not implemented")
+}
+func (UnimplementedAuctionServer) BanBidder(context.Context, *BanRequest) (*Acknowledgement, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BanBidder not implemented")
+}
+func (UnimplementedAuctionServer) GetSignedResult(context.Context, *ResultRequest) (*SignedOutcome, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSignedResult not implemented")
+}
+func (UnimplementedAuctionServer) CommitBid(context.Context, *Commitment) (*Acknowledgement, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CommitBid not implemented")
+}
+func (UnimplementedAuctionServer) RevealBid(context.Context, *Reveal) (*Acknowledgement, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevealBid not implemented")
+}
 func (UnimplementedAuctionServer) mustEmbedUnimplementedAuctionServer() {}
 
 // UnsafeAuctionServer may be embedded to opt out of forward compatibility for this service.
@@ -129,6 +339,207 @@ func _Auction_GetResult_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Auction_ListAuctions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuctionServer).ListAuctions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auction_ListAuctions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuctionServer).ListAuctions(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auction_GetBidHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuctionServer).GetBidHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auction_GetBidHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuctionServer).GetBidHistory(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auction_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AuctionServer).Watch(m, &auctionWatchServer{stream})
+}
+
+type Auction_WatchServer interface {
+	Send(*AuctionInfo) error
+	grpc.ServerStream
+}
+
+type auctionWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *auctionWatchServer) Send(m *AuctionInfo) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Auction_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuctionServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auction_Login_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuctionServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auction_GetAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AccountRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuctionServer).GetAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auction_GetAccount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuctionServer).GetAccount(ctx, req.(*AccountRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auction_CloseAuction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuctionServer).CloseAuction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auction_CloseAuction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuctionServer).CloseAuction(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auction_CancelAuction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuctionServer).CancelAuction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auction_CancelAuction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuctionServer).CancelAuction(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auction_BanBidder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuctionServer).BanBidder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auction_BanBidder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuctionServer).BanBidder(ctx, req.(*BanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auction_GetSignedResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResultRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuctionServer).GetSignedResult(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auction_GetSignedResult_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuctionServer).GetSignedResult(ctx, req.(*ResultRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auction_CommitBid_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Commitment)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuctionServer).CommitBid(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auction_CommitBid_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuctionServer).CommitBid(ctx, req.(*Commitment))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Auction_RevealBid_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Reveal)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuctionServer).RevealBid(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Auction_RevealBid_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuctionServer).RevealBid(ctx, req.(*Reveal))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Auction_ServiceDesc is the grpc.ServiceDesc for Auction service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -144,6 +555,300 @@ var Auction_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetResult",
 			Handler:    _Auction_GetResult_Handler,
 		},
+		{
+			MethodName: "ListAuctions",
+			Handler:    _Auction_ListAuctions_Handler,
+		},
+		{
+			MethodName: "GetBidHistory",
+			Handler:    _Auction_GetBidHistory_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _Auction_Login_Handler,
+		},
+		{
+			MethodName: "GetAccount",
+			Handler:    _Auction_GetAccount_Handler,
+		},
+		{
+			MethodName: "CloseAuction",
+			Handler:    _Auction_CloseAuction_Handler,
+		},
+		{
+			MethodName: "CancelAuction",
+			Handler:    _Auction_CancelAuction_Handler,
+		},
+		{
+			MethodName: "BanBidder",
+			Handler:    _Auction_BanBidder_Handler,
+		},
+		{
+			MethodName: "GetSignedResult",
+			Handler:    _Auction_GetSignedResult_Handler,
+		},
+		{
+			MethodName: "CommitBid",
+			Handler:    _Auction_CommitBid_Handler,
+		},
+		{
+			MethodName: "RevealBid",
+			Handler:    _Auction_RevealBid_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Auction_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "grpc/proto.proto",
+}
+
+const (
+	Admin_Status_FullMethodName          = "/Auction.Admin/Status"
+	Admin_GetDigest_FullMethodName       = "/Auction.Admin/GetDigest"
+	Admin_CheckDivergence_FullMethodName = "/Auction.Admin/CheckDivergence"
+	Admin_GetFaults_FullMethodName       = "/Auction.Admin/GetFaults"
+	Admin_SetFaults_FullMethodName       = "/Auction.Admin/SetFaults"
+)
+
+// AdminClient is the client API for Admin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AdminClient interface {
+	//returns the identity, role and state of the replication manager
+	Status(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*NodeStatus, error)
+	//returns the state that must be the same on all replication managers
+	GetDigest(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*StateDigest, error)
+	//compares the digests of all replication managers and returns what differs
+	CheckDivergence(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*DivergenceReport, error)
+	//returns the rules of the fault injection
+	GetFaults(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*FaultRules, error)
+	//replaces the rules of the fault injection and returns them, only admins may call it
+	SetFaults(ctx context.Context, in *FaultRules, opts ...grpc.CallOption) (*FaultRules, error)
+}
+
+type adminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminClient(cc grpc.ClientConnInterface) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) Status(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*NodeStatus, error) {
+	out := new(NodeStatus)
+	err := c.cc.Invoke(ctx, Admin_Status_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetDigest(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*StateDigest, error) {
+	out := new(StateDigest)
+	err := c.cc.Invoke(ctx, Admin_GetDigest_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) CheckDivergence(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*DivergenceReport, error) {
+	out := new(DivergenceReport)
+	err := c.cc.Invoke(ctx, Admin_CheckDivergence_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetFaults(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*FaultRules, error) {
+	out := new(FaultRules)
+	err := c.cc.Invoke(ctx, Admin_GetFaults_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SetFaults(ctx context.Context, in *FaultRules, opts ...grpc.CallOption) (*FaultRules, error) {
+	out := new(FaultRules)
+	err := c.cc.Invoke(ctx, Admin_SetFaults_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServer is the server API for Admin service.
+// All implementations must embed UnimplementedAdminServer
+// for forward compatibility
+type AdminServer interface {
+	//returns the identity, role and state of the replication manager
+	Status(context.Context, *Empty) (*NodeStatus, error)
+	//returns the state that must be the same on all replication managers
+	GetDigest(context.Context, *Empty) (*StateDigest, error)
+	//compares the digests of all replication managers and returns what differs
+	CheckDivergence(context.Context, *Empty) (*DivergenceReport, error)
+	//returns the rules of the fault injection
+	GetFaults(context.Context, *Empty) (*FaultRules, error)
+	//replaces the rules of the fault injection and returns them, only admins may call it
+	SetFaults(context.Context, *FaultRules) (*FaultRules, error)
+	mustEmbedUnimplementedAdminServer()
+}
+
+// UnimplementedAdminServer must be embedded to have forward compatible implementations.
+type UnimplementedAdminServer struct {
+}
+
+func (UnimplementedAdminServer) Status(context.Context, *Empty) (*NodeStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedAdminServer) GetDigest(context.Context, *Empty) (*StateDigest, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDigest not implemented")
+}
+func (UnimplementedAdminServer) CheckDivergence(context.Context, *Empty) (*DivergenceReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckDivergence not implemented")
+}
+func (UnimplementedAdminServer) GetFaults(context.Context, *Empty) (*FaultRules, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFaults not implemented")
+}
+func (UnimplementedAdminServer) SetFaults(context.Context, *FaultRules) (*FaultRules, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetFaults not implemented")
+}
+func (UnimplementedAdminServer) mustEmbedUnimplementedAdminServer() {}
+
+// UnsafeAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServer will
+// result in compilation errors.
+type UnsafeAdminServer interface {
+	mustEmbedUnimplementedAdminServer()
+}
+
+func RegisterAdminServer(s grpc.ServiceRegistrar, srv AdminServer) {
+	s.RegisterService(&Admin_ServiceDesc, srv)
+}
+
+func _Admin_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_Status_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Status(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetDigest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetDigest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_GetDigest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetDigest(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_CheckDivergence_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).CheckDivergence(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_CheckDivergence_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).CheckDivergence(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_GetFaults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).GetFaults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_GetFaults_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).GetFaults(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_SetFaults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FaultRules)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).SetFaults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_SetFaults_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).SetFaults(ctx, req.(*FaultRules))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Admin_ServiceDesc is the grpc.ServiceDesc for Admin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Admin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "Auction.Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Status",
+			Handler:    _Admin_Status_Handler,
+		},
+		{
+			MethodName: "GetDigest",
+			Handler:    _Admin_GetDigest_Handler,
+		},
+		{
+			MethodName: "CheckDivergence",
+			Handler:    _Admin_CheckDivergence_Handler,
+		},
+		{
+			MethodName: "GetFaults",
+			Handler:    _Admin_GetFaults_Handler,
+		},
+		{
+			MethodName: "SetFaults",
+			Handler:    _Admin_SetFaults_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "grpc/proto.proto",
@@ -0,0 +1,211 @@
+// Package harness runs a cluster of replication managers and frontends inside one process, for tests and simulations.
+//
+// The nodes talk over in-memory connections of a Network, which can partition them and delay, drop and reorder their calls,
+// and the auctions run on a clock.Manual, so the end of the bidding happens when the clock is moved forward.
+// The replication managers are named rm-0, rm-1, ... and the frontends fe-0, fe-1, ...
+package harness
+
+import (
+	"Auction/clock"
+	"Auction/frontend"
+	"Auction/replica"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type options struct {
+	replicas        int
+	frontends       int
+	seed            int64
+	start           time.Time
+	replicaOptions  []replica.Option
+	frontendOptions []frontend.Option
+}
+
+// Option configures a Cluster.
+type Option func(*options)
+
+// WithReplicas sets the number of replication managers, the default is 3.
+func WithReplicas(replicas int) Option {
+	return func(o *options) {
+		o.replicas = replicas
+	}
+}
+
+// WithFrontends sets the number of frontends, the default is 1.
+func WithFrontends(frontends int) Option {
+	return func(o *options) {
+		o.frontends = frontends
+	}
+}
+
+// WithSeed sets the seed of the random drops and reordering of the network, the default is 1.
+func WithSeed(seed int64) Option {
+	return func(o *options) {
+		o.seed = seed
+	}
+}
+
+// WithStart sets the time the clock of the cluster starts at, the default is the time the cluster is created.
+func WithStart(start time.Time) Option {
+	return func(o *options) {
+		o.start = start
+	}
+}
+
+// WithReplicaOptions adds options to every replication manager, after the clock and identity set by the cluster.
+func WithReplicaOptions(replicaOptions ...replica.Option) Option {
+	return func(o *options) {
+		o.replicaOptions = append(o.replicaOptions, replicaOptions...)
+	}
+}
+
+// WithFrontendOptions adds options to every frontend, after the discovery, dial options and clock set by the cluster.
+func WithFrontendOptions(frontendOptions ...frontend.Option) Option {
+	return func(o *options) {
+		o.frontendOptions = append(o.frontendOptions, frontendOptions...)
+	}
+}
+
+// Cluster is a set of replication managers and frontends connected by a Network, it is safe for concurrent use.
+type Cluster struct {
+	// Clock is the clock of every auction and frontend
+	Clock *clock.Manual
+	// Network connects the nodes
+	Network *Network
+
+	options   options
+	mutex     sync.Mutex
+	replicas  []*replica.ReplicationManager
+	frontends []*frontend.AuctionClient
+}
+
+// New starts the replication managers and creates the frontends.
+func New(opts ...Option) (*Cluster, error) {
+	clusterOptions := options{replicas: 3, frontends: 1, seed: 1, start: time.Now()}
+	for _, opt := range opts {
+		opt(&clusterOptions)
+	}
+	if clusterOptions.replicas < 1 {
+		return nil, errors.New("a cluster needs at least one replication manager")
+	}
+	cluster := &Cluster{
+		Clock:     clock.NewManual(clusterOptions.start),
+		Network:   NewNetwork(clusterOptions.seed),
+		options:   clusterOptions,
+		replicas:  make([]*replica.ReplicationManager, clusterOptions.replicas),
+		frontends: make([]*frontend.AuctionClient, clusterOptions.frontends),
+	}
+	for i := range cluster.replicas {
+		if err := cluster.Restart(i); err != nil {
+			cluster.Close()
+			return nil, err
+		}
+	}
+	for i := range cluster.frontends {
+		auctionClient, err := cluster.NewFrontend(FrontendName(i))
+		if err != nil {
+			cluster.Close()
+			return nil, err
+		}
+		cluster.frontends[i] = auctionClient
+	}
+	return cluster, nil
+}
+
+// ReplicaName is the name of the ith replication manager on the network.
+func ReplicaName(i int) string {
+	return fmt.Sprintf("rm-%d", i)
+}
+
+// FrontendName is the name of the ith frontend on the network.
+func FrontendName(i int) string {
+	return fmt.Sprintf("fe-%d", i)
+}
+
+// ReplicaNames are the names of all replication managers, in the order the frontends go through them.
+func (cluster *Cluster) ReplicaNames() []string {
+	names := make([]string, cluster.options.replicas)
+	for i := range names {
+		names[i] = ReplicaName(i)
+	}
+	return names
+}
+
+// Replica returns the ith replication manager, or nil if it is crashed.
+func (cluster *Cluster) Replica(i int) *replica.ReplicationManager {
+	cluster.mutex.Lock()
+	defer cluster.mutex.Unlock()
+	return cluster.replicas[i]
+}
+
+// Frontend returns the ith frontend.
+func (cluster *Cluster) Frontend(i int) *frontend.AuctionClient {
+	cluster.mutex.Lock()
+	defer cluster.mutex.Unlock()
+	return cluster.frontends[i]
+}
+
+// NewFrontend creates another frontend of the cluster with the name on the network, the caller closes it.
+func (cluster *Cluster) NewFrontend(name string) (*frontend.AuctionClient, error) {
+	dialOptions := append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, cluster.Network.DialOptions(name)...)
+	frontendOptions := []frontend.Option{
+		frontend.WithDiscovery(frontend.StaticDiscovery(cluster.ReplicaNames())),
+		frontend.WithDialOptions(dialOptions...),
+		frontend.WithClock(cluster.Clock),
+		frontend.WithRetries(2, 10*time.Millisecond),
+		frontend.WithCallTimeout(time.Second),
+	}
+	return frontend.New(append(frontendOptions, cluster.options.frontendOptions...)...)
+}
+
+// Crash stops the ith replication manager at once, like a process that is killed. Its state is lost.
+func (cluster *Cluster) Crash(i int) {
+	cluster.mutex.Lock()
+	replicationManager := cluster.replicas[i]
+	cluster.replicas[i] = nil
+	cluster.mutex.Unlock()
+	if replicationManager != nil {
+		replicationManager.Stop()
+	}
+}
+
+// Restart crashes the ith replication manager if it is running, and starts a new one with an empty auction in its place.
+func (cluster *Cluster) Restart(i int) error {
+	cluster.Crash(i)
+	name := ReplicaName(i)
+	replicaOptions := append([]replica.Option{replica.WithClock(cluster.Clock), replica.WithIdentity(fmt.Sprint(i), name)}, cluster.options.replicaOptions...)
+	replicationManager := replica.New(replicaOptions...)
+	if err := replicationManager.Start(cluster.Network.Listen(name)); err != nil {
+		return err
+	}
+	cluster.mutex.Lock()
+	defer cluster.mutex.Unlock()
+	cluster.replicas[i] = replicationManager
+	return nil
+}
+
+// Advance moves the clock of the cluster forward, which ends the bidding of the auctions whose time has come.
+func (cluster *Cluster) Advance(duration time.Duration) {
+	cluster.Clock.Advance(duration)
+}
+
+// Close closes the frontends and stops the replication managers.
+func (cluster *Cluster) Close() {
+	cluster.mutex.Lock()
+	frontends := cluster.frontends
+	cluster.mutex.Unlock()
+	for _, auctionClient := range frontends {
+		if auctionClient != nil {
+			auctionClient.Close()
+		}
+	}
+	for i := range cluster.replicas {
+		cluster.Crash(i)
+	}
+}
@@ -0,0 +1,173 @@
+package harness
+
+import (
+	"Auction/auth"
+	"Auction/authz"
+	"Auction/config"
+	"Auction/replica"
+	"Auction/sealed"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Runs the scenarios of the README, every scenario has a cluster of its own so they run in parallel
+func TestScenarios(t *testing.T) {
+	for _, scenario := range Scenarios {
+		scenario := scenario
+		t.Run(scenario.Name, func(t *testing.T) {
+			t.Parallel()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			if err := scenario.Execute(ctx); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestIsolate(t *testing.T) {
+	network := NewNetwork(1)
+	network.Partition([]string{"a", "b"})
+	//Isolating a node again must not give the next isolated node the same partition
+	network.Isolate("c")
+	network.Isolate("c")
+	network.Isolate("d")
+	if network.partitioned("a", "b") {
+		t.Error("a and b are in the same partition")
+	}
+	for _, pair := range [][2]string{{"a", "c"}, {"c", "d"}, {"d", "c"}, {"b", "d"}} {
+		if !network.partitioned(pair[0], pair[1]) {
+			t.Errorf("%s and %s aren't partitioned", pair[0], pair[1])
+		}
+	}
+}
+
+// A replication manager that fails after another one answered must not take the answer away from the caller
+func TestRevealWhenTheLastReplicaCrashes(t *testing.T) {
+	cluster, err := New(WithReplicaOptions(replica.WithSealedBidding(config.Sealed{RevealWindow: config.Duration(time.Minute)})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	bid, err := sealed.NewBid("alice", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack, err := cluster.Frontend(0).Commit(ctx, bid.Bidder, bid.Commitment())
+	if err != nil || ack == nil || ack.Status != "success" {
+		t.Fatalf("expected the commitment to succeed, got %v and %v", ack, err)
+	}
+	cluster.Crash(2)
+	cluster.Advance(61 * time.Second)
+	ack, err = cluster.Frontend(0).Reveal(ctx, bid.Bidder, bid.Amount, bid.Nonce)
+	if err != nil || ack == nil || ack.Status != "success" {
+		t.Fatalf("expected the reveal to succeed, got %v and %v", ack, err)
+	}
+}
+
+// A cluster with authentication and the replica options, and a function that returns a context with the token of a user
+func authCluster(t *testing.T, replicaOptions ...replica.Option) (*Cluster, func(subject, role string) context.Context) {
+	keyFile := filepath.Join(t.TempDir(), "auth.key")
+	if err := os.WriteFile(keyFile, []byte(strings.Repeat("k", 32)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := auth.New(config.Auth{Algorithm: "HS256", KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster, err := New(WithReplicaOptions(append(replicaOptions, replica.WithAuthenticator(authenticator))...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	as := func(subject, role string) context.Context {
+		token, _, err := authenticator.Issue(subject, role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(auth.WithToken(context.Background(), token), time.Minute)
+		t.Cleanup(cancel)
+		return ctx
+	}
+	return cluster, as
+}
+
+func TestCloseWhenTheLastReplicaCrashes(t *testing.T) {
+	cluster, as := authCluster(t)
+	defer cluster.Close()
+	ctx := as("root", authz.Admin)
+	cluster.Crash(2)
+	info, err := cluster.Frontend(0).CloseAuction(ctx)
+	if err != nil || info == nil || !info.IsOver {
+		t.Fatalf("expected the auction to be closed, got %v and %v", info, err)
+	}
+}
+
+func TestCancelWhenTheLastReplicaCrashes(t *testing.T) {
+	cluster, as := authCluster(t)
+	defer cluster.Close()
+	ctx := as("root", authz.Admin)
+	cluster.Crash(2)
+	info, err := cluster.Frontend(0).CancelAuction(ctx)
+	if err != nil || info == nil || !info.IsCancelled {
+		t.Fatalf("expected the auction to be cancelled, got %v and %v", info, err)
+	}
+}
+
+func TestBanWhenTheLastReplicaCrashes(t *testing.T) {
+	cluster, as := authCluster(t)
+	defer cluster.Close()
+	ctx := as("root", authz.Admin)
+	cluster.Crash(2)
+	ack, err := cluster.Frontend(0).Ban(ctx, "mallory", time.Minute)
+	if err != nil || ack == nil || ack.Status != "success" {
+		t.Fatalf("expected mallory to be banned, got %v and %v", ack, err)
+	}
+}
+
+// With pseudonyms, the digests show the names of the bidders only to the callers who may see them
+func TestDigestShowsPseudonyms(t *testing.T) {
+	cluster, as := authCluster(t, replica.WithPseudonyms([]byte(strings.Repeat("p", 32))))
+	defer cluster.Close()
+	if ack, err := cluster.Frontend(0).Bid(as("alice", authz.Bidder), "alice", 10); err != nil || ack.Status != "success" {
+		t.Fatalf("expected the bid to succeed, got %v and %v", ack, err)
+	}
+	if ack, err := cluster.Frontend(0).Ban(as("root", authz.Admin), "mallory", time.Minute); err != nil || ack.Status != "success" {
+		t.Fatalf("expected mallory to be banned, got %v and %v", ack, err)
+	}
+
+	anonymous, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for _, caller := range []struct {
+		name  string
+		ctx   context.Context
+		names bool
+	}{{"anonymous", anonymous, false}, {"observer", as("eve", authz.Observer), false}, {"admin", as("root", authz.Admin), true}} {
+		digests, err := cluster.Frontend(0).Digests(caller.ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checksum := ""
+		for _, answer := range digests {
+			if answer.Err != nil {
+				t.Fatalf("%s: %v", caller.name, answer.Err)
+			}
+			//The pseudonyms are the same on every replication manager, so the digests can still be compared
+			if checksum != "" && answer.Digest.Checksum != checksum {
+				t.Errorf("%s: the checksums of the replication managers differ", caller.name)
+			}
+			checksum = answer.Digest.Checksum
+			auction := answer.Digest.Auctions[0]
+			shown := auction.Bids[0].Id == "alice" || auction.Bans[0].Id == "mallory"
+			if shown != caller.names {
+				t.Errorf("%s: the digest shows the bidder %s and the ban of %s", caller.name, auction.Bids[0].Id, auction.Bans[0].Id)
+			}
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package harness
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// The size of the buffer of every in-memory connection
+const bufferSize = 1024 * 1024
+
+// Link is how the network treats the calls from one node to another.
+// Delays are in real time, not on the clock of the cluster, since grpc waits in real time.
+type Link struct {
+	// Delay is added before every call is sent
+	Delay time.Duration
+	// Reorder adds a random delay of up to the duration to every call, so calls sent close together can overtake each other
+	Reorder time.Duration
+	// DropRequests is the chance that a call is lost on its way, it then never reaches the node
+	DropRequests float64
+	// DropResponses is the chance that the answer is lost, the node then handled the call but the caller never hears of it
+	DropResponses float64
+}
+
+// Network connects the nodes of a cluster in memory, and can partition them and delay, drop and reorder their calls.
+// It is safe for concurrent use.
+type Network struct {
+	mutex     sync.Mutex
+	listeners map[string]*bufconn.Listener
+	links     map[[2]string]Link
+	// The partition every node is in, nodes that aren't in any partition reach everybody
+	partitions map[string]int
+	// Counts the isolated nodes, so every isolated node gets a partition no other node is in
+	isolated int
+	random   *rand.Rand
+}
+
+// NewNetwork creates a network without nodes, the seed makes the drops and reordering repeatable.
+func NewNetwork(seed int64) *Network {
+	return &Network{
+		listeners:  make(map[string]*bufconn.Listener),
+		links:      make(map[[2]string]Link),
+		partitions: make(map[string]int),
+		random:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Listen creates the listener of the node, which replaces an earlier listener of the node.
+func (network *Network) Listen(node string) net.Listener {
+	network.mutex.Lock()
+	defer network.mutex.Unlock()
+	listener := bufconn.Listen(bufferSize)
+	network.listeners[node] = listener
+	return listener
+}
+
+// Partition splits the network into the groups of nodes, which can only reach the nodes of their own group.
+// Nodes that aren't in any group can still reach everybody. An earlier partition is replaced.
+func (network *Network) Partition(groups ...[]string) {
+	network.mutex.Lock()
+	defer network.mutex.Unlock()
+	network.partitions = make(map[string]int)
+	for i, group := range groups {
+		for _, node := range group {
+			network.partitions[node] = i + 1
+		}
+	}
+}
+
+// Isolate puts the node in a partition of its own, so it reaches nobody and nobody reaches it.
+func (network *Network) Isolate(node string) {
+	network.mutex.Lock()
+	defer network.mutex.Unlock()
+	network.isolated++
+	network.partitions[node] = -network.isolated
+}
+
+// Heal removes the partitions and the links, so every call goes through at once.
+func (network *Network) Heal() {
+	network.mutex.Lock()
+	defer network.mutex.Unlock()
+	network.partitions = make(map[string]int)
+	network.links = make(map[[2]string]Link)
+}
+
+// SetLink sets how the calls from one node to another are treated, a zero Link sends them at once.
+func (network *Network) SetLink(from, to string, link Link) {
+	network.mutex.Lock()
+	defer network.mutex.Unlock()
+	if link == (Link{}) {
+		delete(network.links, [2]string{from, to})
+		return
+	}
+	network.links[[2]string{from, to}] = link
+}
+
+// Delay delays the calls from one node to another.
+func (network *Network) Delay(from, to string, delay time.Duration) {
+	link := network.link(from, to)
+	link.Delay = delay
+	network.SetLink(from, to, link)
+}
+
+// Drop loses the calls from one node to another, or their answers, with the chances.
+func (network *Network) Drop(from, to string, requests, responses float64) {
+	link := network.link(from, to)
+	link.DropRequests, link.DropResponses = requests, responses
+	network.SetLink(from, to, link)
+}
+
+// Reorder delays the calls from one node to another at random by up to the window, so they can arrive out of order.
+func (network *Network) Reorder(from, to string, window time.Duration) {
+	link := network.link(from, to)
+	link.Reorder = window
+	network.SetLink(from, to, link)
+}
+
+func (network *Network) link(from, to string) Link {
+	network.mutex.Lock()
+	defer network.mutex.Unlock()
+	return network.links[[2]string{from, to}]
+}
+
+// Whether the nodes are in different partitions
+func (network *Network) partitioned(from, to string) bool {
+	fromPartition, toPartition := network.partitions[from], network.partitions[to]
+	return (fromPartition != 0 || toPartition != 0) && fromPartition != toPartition
+}
+
+// DialOptions are the options for the frontends of the node, so they dial the other nodes through the network.
+func (network *Network) DialOptions(from string) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, to string) (net.Conn, error) {
+			return network.dial(ctx, from, to)
+		}),
+		grpc.WithChainUnaryInterceptor(network.unaryClientInterceptor(from)),
+		grpc.WithChainStreamInterceptor(network.streamClientInterceptor(from)),
+	}
+}
+
+func (network *Network) dial(ctx context.Context, from, to string) (net.Conn, error) {
+	network.mutex.Lock()
+	listener := network.listeners[to]
+	partitioned := network.partitioned(from, to)
+	network.mutex.Unlock()
+	if listener == nil {
+		return nil, fmt.Errorf("there is no node %s", to)
+	}
+	if partitioned {
+		return nil, fmt.Errorf("%s can't reach %s, they are partitioned", from, to)
+	}
+	return listener.DialContext(ctx)
+}
+
+// What happens to a call: how long it waits before it is sent, and whether the call or its answer is lost
+type fate struct {
+	wait            time.Duration
+	partitioned     bool
+	requestDropped  bool
+	responseDropped bool
+}
+
+func (network *Network) fate(from, to string) fate {
+	network.mutex.Lock()
+	defer network.mutex.Unlock()
+	link := network.links[[2]string{from, to}]
+	callFate := fate{
+		wait:            link.Delay,
+		partitioned:     network.partitioned(from, to),
+		requestDropped:  network.random.Float64() < link.DropRequests,
+		responseDropped: network.random.Float64() < link.DropResponses,
+	}
+	if link.Reorder > 0 {
+		callFate.wait += time.Duration(network.random.Int63n(int64(link.Reorder)))
+	}
+	return callFate
+}
+
+// Waits for the delay of the call, and returns the error of a call that is partitioned or whose request is lost
+// A lost call fails like a call to a node that is down, after the delay
+func (callFate fate) send(ctx context.Context, from, to string) error {
+	if callFate.wait > 0 {
+		select {
+		case <-time.After(callFate.wait):
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	if callFate.partitioned {
+		return status.Errorf(codes.Unavailable, "%s can't reach %s, they are partitioned", from, to)
+	}
+	if callFate.requestDropped {
+		return status.Errorf(codes.Unavailable, "the call from %s to %s was lost", from, to)
+	}
+	return nil
+}
+
+func (network *Network) unaryClientInterceptor(from string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, request, reply any, conn *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		to := conn.Target()
+		callFate := network.fate(from, to)
+		if err := callFate.send(ctx, from, to); err != nil {
+			return err
+		}
+		err := invoker(ctx, method, request, reply, conn, opts...)
+		if err == nil && callFate.responseDropped {
+			return status.Errorf(codes.Unavailable, "the answer from %s to %s was lost", to, from)
+		}
+		return err
+	}
+}
+
+// Streams are only delayed, partitioned or lost when they are opened
+func (network *Network) streamClientInterceptor(from string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, conn *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		to := conn.Target()
+		if err := network.fate(from, to).send(ctx, from, to); err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, conn, method, opts...)
+	}
+}
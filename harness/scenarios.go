@@ -0,0 +1,298 @@
+package harness
+
+import (
+	"Auction/config"
+	"Auction/divergence"
+	proto "Auction/grpc"
+	"Auction/linearizability"
+	"Auction/replica"
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// How long a step of a scenario may take
+const stepTimeout = 5 * time.Second
+
+// Scenario is a repeatable run of a cluster that checks how it handles a failure, fx the crash scenarios of the README.
+type Scenario struct {
+	Name        string
+	Description string
+	Options     []Option
+	Run         func(ctx context.Context, cluster *Cluster) error
+}
+
+// Execute runs the scenario on a new cluster, and returns why it failed.
+func (scenario Scenario) Execute(ctx context.Context) error {
+	cluster, err := New(scenario.Options...)
+	if err != nil {
+		return err
+	}
+	defer cluster.Close()
+	return scenario.Run(ctx, cluster)
+}
+
+// Scenarios are the failures the cluster is known to handle, and the ones where the replication managers are known to diverge.
+var Scenarios = []Scenario{
+	{
+		Name:        "crash-replica",
+		Description: "a replication manager crashes during the auction, and the auction goes on with the others",
+		Run: func(ctx context.Context, cluster *Cluster) error {
+			if err := expectBid(ctx, cluster.Frontend(0), "alice", 10, "success"); err != nil {
+				return err
+			}
+			cluster.Crash(0)
+			if err := expectBid(ctx, cluster.Frontend(0), "bob", 20, "success"); err != nil {
+				return err
+			}
+			if err := expectBid(ctx, cluster.Frontend(0), "alice", 15, "fail - bid too low"); err != nil {
+				return err
+			}
+			cluster.Advance(61 * time.Second)
+			return expectResult(ctx, cluster.Frontend(0), 20, "bob")
+		},
+	},
+	{
+		Name:        "crash-all-but-one",
+		Description: "two of three replication managers crash, and the last one still takes bids",
+		Run: func(ctx context.Context, cluster *Cluster) error {
+			if err := expectBid(ctx, cluster.Frontend(0), "alice", 10, "success"); err != nil {
+				return err
+			}
+			cluster.Crash(0)
+			cluster.Crash(1)
+			if err := expectBid(ctx, cluster.Frontend(0), "bob", 20, "success"); err != nil {
+				return err
+			}
+			return expectResult(ctx, cluster.Frontend(0), 20, "")
+		},
+	},
+	{
+		Name:        "crash-client",
+		Description: "a client crashes, and the other clients go on with the auction",
+		Options:     []Option{WithFrontends(2)},
+		Run: func(ctx context.Context, cluster *Cluster) error {
+			if err := expectBid(ctx, cluster.Frontend(0), "alice", 10, "success"); err != nil {
+				return err
+			}
+			cluster.Frontend(0).Close()
+			if err := expectBid(ctx, cluster.Frontend(1), "bob", 20, "success"); err != nil {
+				return err
+			}
+			cluster.Advance(61 * time.Second)
+			return expectResult(ctx, cluster.Frontend(1), 20, "bob")
+		},
+	},
+	{
+		Name:        "bidding-ends-on-time",
+		Description: "the bidding ends when the time after the first bid has run out, and later bids are rejected",
+		Run: func(ctx context.Context, cluster *Cluster) error {
+			if err := expectBid(ctx, cluster.Frontend(0), "alice", 10, "success"); err != nil {
+				return err
+			}
+			cluster.Advance(59 * time.Second)
+			if err := expectBid(ctx, cluster.Frontend(0), "bob", 20, "success"); err != nil {
+				return err
+			}
+			cluster.Advance(2 * time.Second)
+			if err := expectBid(ctx, cluster.Frontend(0), "alice", 30, "fail - bidding is over"); err != nil {
+				return err
+			}
+			return expectResult(ctx, cluster.Frontend(0), 20, "bob")
+		},
+	},
+	{
+		Name:        "lost-response",
+		Description: "the answers of a replication manager are lost, the bid is applied on all of them and succeeds",
+		Run: func(ctx context.Context, cluster *Cluster) error {
+			cluster.Network.Drop(FrontendName(0), ReplicaName(2), 0, 1)
+			if err := expectBid(ctx, cluster.Frontend(0), "alice", 10, "success"); err != nil {
+				return err
+			}
+			return expectDifferences(cluster, 0)
+		},
+	},
+	{
+		Name:        "lost-request",
+		Description: "the calls to a replication manager are lost, the bid succeeds but that replication manager diverges",
+		Run: func(ctx context.Context, cluster *Cluster) error {
+			cluster.Network.Drop(FrontendName(0), ReplicaName(1), 1, 0)
+			if err := expectBid(ctx, cluster.Frontend(0), "alice", 10, "success"); err != nil {
+				return err
+			}
+			cluster.Network.Heal()
+			if err := expectResult(ctx, cluster.Frontend(0), 10, ""); err != nil {
+				return err
+			}
+			return expectDifferences(cluster, 1)
+		},
+	},
+	{
+		Name:        "partitioned-replica",
+		Description: "a replication manager is cut off from the frontend, misses a bid and is behind the others when the partition heals",
+		Run: func(ctx context.Context, cluster *Cluster) error {
+			if err := expectBid(ctx, cluster.Frontend(0), "alice", 10, "success"); err != nil {
+				return err
+			}
+			cluster.Network.Isolate(ReplicaName(2))
+			if err := expectBid(ctx, cluster.Frontend(0), "bob", 20, "success"); err != nil {
+				return err
+			}
+			cluster.Network.Heal()
+			return expectDifferences(cluster, 1)
+		},
+	},
+	{
+		Name:        "slow-replica",
+		Description: "the calls to a replication manager are delayed, the bids still reach every replication manager in the same order",
+		Run: func(ctx context.Context, cluster *Cluster) error {
+			cluster.Network.Delay(FrontendName(0), ReplicaName(1), 50*time.Millisecond)
+			for i, amount := range []int32{10, 20, 30} {
+				if err := expectBid(ctx, cluster.Frontend(0), fmt.Sprintf("bidder-%d", i), amount, "success"); err != nil {
+					return err
+				}
+			}
+			return expectDifferences(cluster, 0)
+		},
+	},
+	{
+		Name:        "rate-limited-burst",
+		Description: "a bidder bids faster than the rate limits while the frontend reads from one replication manager, and every replication manager rejects the same bids",
+		Options: []Option{WithReplicaOptions(replica.WithRateLimits(config.RateLimits{
+			BidsPerSecond: 1, BidBurst: 3, CallsPerSecond: 1, CallBurst: 5,
+		}))},
+		Run: func(ctx context.Context, cluster *Cluster) error {
+			//The reads only go to the first replication manager and use up the calls of its connection, but not the bids
+			for amount := int32(10); amount <= 30; amount += 10 {
+				if err := expectBid(ctx, cluster.Frontend(0), "alice", amount, "success"); err != nil {
+					return err
+				}
+				if err := expectResult(ctx, cluster.Frontend(0), amount, ""); err != nil {
+					return err
+				}
+			}
+			for amount := int32(40); amount <= 60; amount += 10 {
+				if err := expectLimited(ctx, cluster.Frontend(0), "alice", amount); err != nil {
+					return err
+				}
+			}
+			cluster.Advance(time.Second)
+			if err := expectBid(ctx, cluster.Frontend(0), "alice", 70, "success"); err != nil {
+				return err
+			}
+			if err := expectLimited(ctx, cluster.Frontend(0), "alice", 80); err != nil {
+				return err
+			}
+			return expectDifferences(cluster, 0)
+		},
+	},
+	{
+		Name:        "linearizable-with-faults",
+		Description: "concurrent bidders share a frontend whose calls are delayed and reordered while a replication manager crashes, and the history is linearizable",
+		Run: func(ctx context.Context, cluster *Cluster) error {
+			for _, replicaName := range cluster.ReplicaNames() {
+				cluster.Network.Reorder(FrontendName(0), replicaName, 2*time.Millisecond)
+			}
+			recorder := linearizability.NewRecorder()
+			var wait sync.WaitGroup
+			for client := 0; client < 4; client++ {
+				wait.Add(1)
+				go func(client int) {
+					defer wait.Done()
+					random := rand.New(rand.NewSource(int64(client)))
+					for i := 0; i < 50; i++ {
+						stepCtx, cancel := context.WithTimeout(ctx, stepTimeout)
+						if random.Intn(3) == 0 {
+							recorder.Result(stepCtx, client, cluster.Frontend(0))
+						} else {
+							recorder.Bid(stepCtx, client, cluster.Frontend(0), fmt.Sprintf("bidder-%d", client), int32(i*4+random.Intn(6)))
+						}
+						cancel()
+						if client == 0 && i == 25 {
+							cluster.Crash(0)
+						}
+					}
+				}(client)
+			}
+			wait.Wait()
+			result, err := linearizability.Check(ctx, linearizability.AuctionModel, recorder.History())
+			if err != nil {
+				return err
+			}
+			if !result.Ok {
+				return fmt.Errorf("the history of %d operations is not linearizable", len(recorder.History()))
+			}
+			return nil
+		},
+	},
+}
+
+// Divergence compares the digests of the running replication managers. They are asked directly, not through the network.
+func (cluster *Cluster) Divergence() *proto.DivergenceReport {
+	names := cluster.ReplicaNames()
+	digests := make([]*proto.StateDigest, len(names))
+	errs := make([]error, len(names))
+	for i := range names {
+		replicationManager := cluster.Replica(i)
+		if replicationManager == nil {
+			errs[i] = fmt.Errorf("%s is crashed", names[i])
+			continue
+		}
+		digests[i] = replicationManager.Digest()
+	}
+	return divergence.Check(names, digests, errs)
+}
+
+func expectBid(ctx context.Context, auction linearizability.Auction, bidder string, amount int32, expected string) error {
+	ctx, cancel := context.WithTimeout(ctx, stepTimeout)
+	defer cancel()
+	ack, err := auction.Bid(ctx, bidder, amount)
+	if err != nil {
+		return fmt.Errorf("the bid of %d by %s failed: %w", amount, bidder, err)
+	}
+	if ack.Status != expected {
+		return fmt.Errorf("the bid of %d by %s got %q, expected %q", amount, bidder, ack.Status, expected)
+	}
+	return nil
+}
+
+// Expects every replication manager to reject the bid, because the bidder bids too fast
+func expectLimited(ctx context.Context, auction linearizability.Auction, bidder string, amount int32) error {
+	ctx, cancel := context.WithTimeout(ctx, stepTimeout)
+	defer cancel()
+	ack, err := auction.Bid(ctx, bidder, amount)
+	if status.Code(err) != codes.ResourceExhausted {
+		return fmt.Errorf("the bid of %d by %s got %v and %v, expected it to be rate limited", amount, bidder, ack, err)
+	}
+	return nil
+}
+
+func expectResult(ctx context.Context, auction linearizability.Auction, highestBid int32, winner string) error {
+	ctx, cancel := context.WithTimeout(ctx, stepTimeout)
+	defer cancel()
+	outcome, err := auction.Result(ctx)
+	if err != nil {
+		return fmt.Errorf("the result failed: %w", err)
+	}
+	if outcome.HighestBid != highestBid || outcome.Winner != winner {
+		return fmt.Errorf("the result is %d by %q, expected %d by %q", outcome.HighestBid, outcome.Winner, highestBid, winner)
+	}
+	return nil
+}
+
+func expectDifferences(cluster *Cluster, expected int) error {
+	report := cluster.Divergence()
+	if len(report.Differences) != expected {
+		text := fmt.Sprintf("the replication managers differ in %d items, expected %d", len(report.Differences), expected)
+		for _, difference := range report.Differences {
+			text += "\n  " + divergence.Format(difference)
+		}
+		return fmt.Errorf("%s", text)
+	}
+	return nil
+}
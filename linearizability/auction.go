@@ -0,0 +1,86 @@
+package linearizability
+
+import "strings"
+
+// The kinds of operations on the auction
+const (
+	KindBid    = "bid"
+	KindResult = "result"
+)
+
+// AuctionInput is a bid of the bidder, or a call of GetResult.
+type AuctionInput struct {
+	Kind   string `json:"kind"`
+	Bidder string `json:"bidder,omitempty"`
+	Amount int32  `json:"amount,omitempty"`
+}
+
+// AuctionOutput is the status of the acknowledgement of a bid, or the outcome of GetResult.
+// Unknown is set when the call failed, so a bid may or may not have been applied.
+type AuctionOutput struct {
+	Status     string `json:"status,omitempty"`
+	HighestBid int32  `json:"highestBid,omitempty"`
+	Winner     string `json:"winner,omitempty"`
+	Unknown    bool   `json:"unknown,omitempty"`
+}
+
+// AuctionState is the highest bid and its bidder, and whether the bidding is over.
+type AuctionState struct {
+	HighestBid int32
+	Bidder     string
+	Over       bool
+}
+
+// AuctionModel is the auction of a replication manager without sealed bids, accounts, bans and pseudonyms:
+// a bid of at least the highest bid is accepted until the bidding is over, and the winner is only shown after it.
+// The bidding can end between any two operations, since it ends when its time runs out.
+// Bids that are rejected for other reasons, fx by the rate limits, don't change the auction.
+var AuctionModel = Model[AuctionState, AuctionInput, AuctionOutput]{
+	Init: func() AuctionState { return AuctionState{} },
+	Step: stepAuction,
+}
+
+func stepAuction(state AuctionState, input AuctionInput, output AuctionOutput) []AuctionState {
+	next := stepAuctionState(state, input, output)
+	//The bidding may also have ended just before the operation
+	if !state.Over {
+		over := state
+		over.Over = true
+		next = append(next, stepAuctionState(over, input, output)...)
+	}
+	return next
+}
+
+func stepAuctionState(state AuctionState, input AuctionInput, output AuctionOutput) []AuctionState {
+	switch input.Kind {
+	case KindBid:
+		applied := AuctionState{HighestBid: input.Amount, Bidder: input.Bidder}
+		accepts := !state.Over && input.Amount >= state.HighestBid
+		switch {
+		case output.Unknown && accepts:
+			return []AuctionState{applied, state}
+		case output.Unknown:
+			return []AuctionState{state}
+		case output.Status == "success" && accepts:
+			return []AuctionState{applied}
+		case output.Status == "fail - bidding is over" && state.Over:
+			return []AuctionState{state}
+		case output.Status == "fail - bid too low" && !state.Over && input.Amount < state.HighestBid:
+			return []AuctionState{state}
+		case strings.HasPrefix(output.Status, "fail - ") && output.Status != "fail - bidding is over" && output.Status != "fail - bid too low":
+			return []AuctionState{state}
+		}
+	case KindResult:
+		if output.Unknown {
+			return []AuctionState{state}
+		}
+		winner := ""
+		if state.Over {
+			winner = state.Bidder
+		}
+		if output.HighestBid == state.HighestBid && output.Winner == winner {
+			return []AuctionState{state}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+// Package linearizability checks that a history of concurrent operations could have happened one at a time,
+// in an order that respects when every operation was called and returned, on a sequential model of the object.
+//
+// It is the algorithm of Wing and Gong with the cache of Lowe, as in Porcupine: the operations are linearized
+// one by one in a depth first search, and a set of linearized operations with a state that was already tried
+// isn't tried again.
+package linearizability
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+)
+
+// ErrTimeout is returned by Check when the search took longer than the context allowed.
+var ErrTimeout = errors.New("the linearizability check timed out")
+
+// Pending is the return time of an operation whose output is unknown, fx because the call failed.
+// It may take effect at any time after it was called, or never.
+const Pending = math.MaxInt64
+
+// Operation is a call of a client and its output. Call and Return are times on the same clock,
+// fx nanoseconds since the history started. The output of an operation that never returned is decided by the model.
+type Operation[I, O any] struct {
+	Client int   `json:"client"`
+	Input  I     `json:"input"`
+	Call   int64 `json:"call"`
+	Output O     `json:"output"`
+	Return int64 `json:"return"`
+}
+
+// Model is the sequential specification of the object.
+// Step returns the states the object can be in after the operation, and none if the output isn't possible in the state.
+// More than one state lets the object change on its own, fx an auction whose time runs out.
+type Model[S comparable, I, O any] struct {
+	Init func() S
+	Step func(state S, input I, output O) []S
+}
+
+// Result is the answer of Check. Order is the operations in the order they took effect if the history is linearizable,
+// otherwise the longest order that was found, and Stuck the operations that couldn't follow it.
+type Result struct {
+	Ok    bool
+	Order []int
+	Stuck []int
+}
+
+// The search state: the linearized operations and the state of the model after them
+type cacheKey[S comparable] struct {
+	linearized string
+	state      S
+}
+
+type search[S comparable, I, O any] struct {
+	ctx        context.Context
+	model      Model[S, I, O]
+	history    []Operation[I, O]
+	byCall     []int
+	linearized []bool
+	order      []int
+	longest    []int
+	cache      map[cacheKey[S]]bool
+	steps      int
+}
+
+// Check searches for an order of the operations that the model accepts. The search can take exponential time,
+// so it returns ErrTimeout when the context is done.
+func Check[S comparable, I, O any](ctx context.Context, model Model[S, I, O], history []Operation[I, O]) (Result, error) {
+	search := &search[S, I, O]{
+		ctx:        ctx,
+		model:      model,
+		history:    history,
+		byCall:     make([]int, len(history)),
+		linearized: make([]bool, len(history)),
+		cache:      make(map[cacheKey[S]]bool),
+	}
+	for i := range history {
+		search.byCall[i] = i
+	}
+	sort.SliceStable(search.byCall, func(a, b int) bool {
+		return history[search.byCall[a]].Call < history[search.byCall[b]].Call
+	})
+
+	ok, err := search.linearize(model.Init())
+	if err != nil {
+		return Result{}, err
+	}
+	if ok {
+		return Result{Ok: true, Order: search.order}, nil
+	}
+	//The operations that could have come next after the longest order, but none of them could
+	result := Result{Order: search.longest}
+	done := make([]bool, len(history))
+	for _, i := range search.longest {
+		done[i] = true
+	}
+	search.linearized = done
+	minimumReturn := search.minimumReturn()
+	for _, i := range search.byCall {
+		if !done[i] && history[i].Call <= minimumReturn {
+			result.Stuck = append(result.Stuck, i)
+		}
+	}
+	return result, nil
+}
+
+// Linearizes the rest of the operations after the state, and returns whether it is possible
+func (search *search[S, I, O]) linearize(state S) (bool, error) {
+	if len(search.order) == len(search.history) {
+		return true, nil
+	}
+	search.steps++
+	if search.steps%1000 == 0 && search.ctx.Err() != nil {
+		return false, ErrTimeout
+	}
+	key := cacheKey[S]{linearized: search.bits(), state: state}
+	if search.cache[key] {
+		return false, nil
+	}
+	search.cache[key] = true
+
+	//An operation can come next if no other operation that is left returned before it was called
+	minimumReturn := search.minimumReturn()
+	for _, i := range search.byCall {
+		operation := search.history[i]
+		if operation.Call > minimumReturn {
+			break
+		}
+		if search.linearized[i] {
+			continue
+		}
+		for _, next := range search.model.Step(state, operation.Input, operation.Output) {
+			search.linearized[i] = true
+			search.order = append(search.order, i)
+			if len(search.order) > len(search.longest) {
+				search.longest = append(search.longest[:0], search.order...)
+			}
+			ok, err := search.linearize(next)
+			if ok || err != nil {
+				return ok, err
+			}
+			search.linearized[i] = false
+			search.order = search.order[:len(search.order)-1]
+		}
+	}
+	return false, nil
+}
+
+func (search *search[S, I, O]) minimumReturn() int64 {
+	minimum := int64(Pending)
+	for i, operation := range search.history {
+		if !search.linearized[i] && operation.Return < minimum {
+			minimum = operation.Return
+		}
+	}
+	return minimum
+}
+
+// The linearized operations as a string, so they can be part of the key of the cache
+func (search *search[S, I, O]) bits() string {
+	bits := make([]byte, (len(search.linearized)+7)/8)
+	for i, linearized := range search.linearized {
+		if linearized {
+			bits[i/8] |= 1 << (i % 8)
+		}
+	}
+	return string(bits)
+}
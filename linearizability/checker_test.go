@@ -0,0 +1,222 @@
+package linearizability_test
+
+import (
+	"Auction/harness"
+	"Auction/linearizability"
+	"context"
+	"testing"
+	"time"
+)
+
+func bid(client int, bidder string, amount int32, call, ret int64, status string) linearizability.AuctionOperation {
+	return linearizability.AuctionOperation{
+		Client: client,
+		Input:  linearizability.AuctionInput{Kind: linearizability.KindBid, Bidder: bidder, Amount: amount},
+		Call:   call,
+		Output: linearizability.AuctionOutput{Status: status},
+		Return: ret,
+	}
+}
+
+// A bid whose call failed, so it may have been applied at any time after it was called, or never
+func failedBid(client int, bidder string, amount int32, call int64) linearizability.AuctionOperation {
+	operation := bid(client, bidder, amount, call, linearizability.Pending, "")
+	operation.Output.Unknown = true
+	return operation
+}
+
+func result(client int, highestBid int32, winner string, call, ret int64) linearizability.AuctionOperation {
+	return linearizability.AuctionOperation{
+		Client: client,
+		Input:  linearizability.AuctionInput{Kind: linearizability.KindResult},
+		Call:   call,
+		Output: linearizability.AuctionOutput{HighestBid: highestBid, Winner: winner},
+		Return: ret,
+	}
+}
+
+func TestCheckAuction(t *testing.T) {
+	tests := []struct {
+		name         string
+		history      []linearizability.AuctionOperation
+		linearizable bool
+	}{
+		{
+			name: "sequential bids",
+			history: []linearizability.AuctionOperation{
+				bid(0, "alice", 10, 0, 1, "success"),
+				bid(1, "bob", 5, 2, 3, "fail - bid too low"),
+				result(2, 10, "", 4, 5),
+			},
+			linearizable: true,
+		},
+		{
+			name: "concurrent bids both accepted in the order of the amounts",
+			history: []linearizability.AuctionOperation{
+				bid(0, "alice", 20, 0, 10, "success"),
+				bid(1, "bob", 10, 1, 9, "success"),
+				result(2, 20, "", 11, 12),
+			},
+			linearizable: true,
+		},
+		{
+			name: "concurrent bids both accepted but the lower one is the highest",
+			history: []linearizability.AuctionOperation{
+				bid(0, "alice", 20, 0, 10, "success"),
+				bid(1, "bob", 10, 1, 9, "success"),
+				result(2, 10, "", 11, 12),
+			},
+		},
+		{
+			name: "a read that overlaps a bid may see it or not",
+			history: []linearizability.AuctionOperation{
+				bid(0, "alice", 10, 0, 1, "success"),
+				bid(1, "bob", 20, 2, 10, "success"),
+				result(2, 10, "", 3, 4),
+				result(2, 20, "", 5, 6),
+			},
+			linearizable: true,
+		},
+		{
+			name: "a stale read after a higher bid was accepted",
+			history: []linearizability.AuctionOperation{
+				bid(0, "alice", 10, 0, 1, "success"),
+				bid(1, "bob", 20, 2, 3, "success"),
+				result(2, 10, "", 4, 5),
+			},
+		},
+		{
+			name: "a read that goes back to an older bid",
+			history: []linearizability.AuctionOperation{
+				bid(0, "alice", 10, 0, 1, "success"),
+				bid(1, "bob", 20, 2, 10, "success"),
+				result(2, 20, "", 3, 4),
+				result(2, 10, "", 5, 6),
+			},
+		},
+		{
+			name: "a winner before the bidding is over",
+			history: []linearizability.AuctionOperation{
+				bid(0, "alice", 10, 0, 1, "success"),
+				result(1, 10, "alice", 2, 3),
+				bid(0, "bob", 20, 4, 5, "success"),
+			},
+		},
+		{
+			name: "a bid after the winner was shown is rejected",
+			history: []linearizability.AuctionOperation{
+				bid(0, "alice", 10, 0, 1, "success"),
+				result(1, 10, "alice", 2, 3),
+				bid(0, "bob", 20, 4, 5, "fail - bidding is over"),
+			},
+			linearizable: true,
+		},
+		{
+			name: "the bidding ends between two operations",
+			history: []linearizability.AuctionOperation{
+				bid(0, "alice", 10, 0, 1, "success"),
+				bid(0, "bob", 20, 2, 3, "fail - bidding is over"),
+				result(1, 10, "alice", 4, 5),
+			},
+			linearizable: true,
+		},
+		{
+			name: "a failed bid that was applied later",
+			history: []linearizability.AuctionOperation{
+				failedBid(0, "alice", 10, 0),
+				result(1, 0, "", 1, 2),
+				result(1, 10, "", 3, 4),
+			},
+			linearizable: true,
+		},
+		{
+			name: "a failed bid that was never applied",
+			history: []linearizability.AuctionOperation{
+				failedBid(0, "alice", 10, 0),
+				bid(1, "bob", 5, 1, 2, "success"),
+				result(1, 5, "", 3, 4),
+			},
+			linearizable: true,
+		},
+		{
+			name: "a failed bid can't be undone",
+			history: []linearizability.AuctionOperation{
+				failedBid(0, "alice", 10, 0),
+				result(1, 10, "", 1, 2),
+				result(1, 0, "", 3, 4),
+			},
+		},
+		{
+			name: "a failed bid can't take effect before it was called",
+			history: []linearizability.AuctionOperation{
+				result(1, 10, "", 0, 1),
+				failedBid(0, "alice", 10, 2),
+			},
+		},
+		{
+			name: "a crashed client doesn't stop the others",
+			history: []linearizability.AuctionOperation{
+				failedBid(0, "alice", 30, 0),
+				bid(1, "bob", 10, 1, 2, "success"),
+				bid(2, "carol", 20, 3, 4, "success"),
+				result(1, 20, "", 5, 6),
+			},
+			linearizable: true,
+		},
+		{
+			name: "rejections for other reasons don't change the auction",
+			history: []linearizability.AuctionOperation{
+				bid(0, "alice", 10, 0, 1, "fail - rate limited"),
+				result(1, 0, "", 2, 3),
+			},
+			linearizable: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checked, err := linearizability.Check(context.Background(), linearizability.AuctionModel, test.history)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if checked.Ok != test.linearizable {
+				t.Fatalf("linearizable = %v, want %v, the order was %v and the stuck operations %v", checked.Ok, test.linearizable, checked.Order, checked.Stuck)
+			}
+			if checked.Ok && len(checked.Order) != len(test.history) {
+				t.Errorf("the order %v doesn't have all %d operations", checked.Order, len(test.history))
+			}
+			if !checked.Ok && len(checked.Stuck) == 0 {
+				t.Error("no operations are stuck")
+			}
+		})
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	//Many concurrent failed bids give the search a lot of orders to try
+	var history []linearizability.AuctionOperation
+	for i := 0; i < 40; i++ {
+		history = append(history, failedBid(i, "alice", int32(i), 0))
+	}
+	history = append(history, result(40, -1, "", 1, 2))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := linearizability.Check(ctx, linearizability.AuctionModel, history); err != linearizability.ErrTimeout {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+}
+
+// The recorded history of clients bidding on a cluster with delayed and reordered calls and a crash is linearizable
+func TestLinearizableWithFaults(t *testing.T) {
+	for _, scenario := range harness.Scenarios {
+		if scenario.Name != "linearizable-with-faults" {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := scenario.Execute(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	t.Fatal("there is no scenario linearizable-with-faults")
+}
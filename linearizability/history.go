@@ -0,0 +1,129 @@
+package linearizability
+
+import (
+	proto "Auction/grpc"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuctionOperation is an operation on the auction, as recorded by the Recorder.
+type AuctionOperation = Operation[AuctionInput, AuctionOutput]
+
+// Auction is what the Recorder calls, fx a *frontend.AuctionClient.
+type Auction interface {
+	Bid(ctx context.Context, bidder string, amount int32) (*proto.Acknowledgement, error)
+	Result(ctx context.Context) (*proto.Outcome, error)
+}
+
+// Recorder records the bids and results of concurrent clients with the times they were called and returned,
+// it is safe for concurrent use.
+type Recorder struct {
+	mutex      sync.Mutex
+	start      time.Time
+	operations []AuctionOperation
+}
+
+// NewRecorder creates a Recorder whose clock starts now.
+func NewRecorder() *Recorder {
+	return &Recorder{start: time.Now()}
+}
+
+// Bid places the bid through the auction and records it. A failed bid is recorded with an unknown output,
+// since it may have been applied on some of the servers.
+func (recorder *Recorder) Bid(ctx context.Context, client int, auction Auction, bidder string, amount int32) (*proto.Acknowledgement, error) {
+	call := recorder.now()
+	ack, err := auction.Bid(ctx, bidder, amount)
+	operation := AuctionOperation{Client: client, Input: AuctionInput{Kind: KindBid, Bidder: bidder, Amount: amount}, Call: call, Return: recorder.now()}
+	if err != nil {
+		operation.Output.Unknown = true
+		operation.Return = Pending
+	} else {
+		operation.Output.Status = ack.Status
+	}
+	recorder.add(operation)
+	return ack, err
+}
+
+// Result asks the auction for the result and records it. A failed call isn't recorded, since it read nothing.
+func (recorder *Recorder) Result(ctx context.Context, client int, auction Auction) (*proto.Outcome, error) {
+	call := recorder.now()
+	outcome, err := auction.Result(ctx)
+	if err == nil {
+		recorder.add(AuctionOperation{Client: client, Input: AuctionInput{Kind: KindResult}, Call: call,
+			Output: AuctionOutput{HighestBid: outcome.HighestBid, Winner: outcome.Winner}, Return: recorder.now()})
+	}
+	return outcome, err
+}
+
+// History returns a copy of the recorded operations.
+func (recorder *Recorder) History() []AuctionOperation {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	return append([]AuctionOperation(nil), recorder.operations...)
+}
+
+func (recorder *Recorder) add(operation AuctionOperation) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.operations = append(recorder.operations, operation)
+}
+
+// The time since the recorder started, in nanoseconds
+func (recorder *Recorder) now() int64 {
+	return int64(time.Since(recorder.start))
+}
+
+// WriteHistory writes the operations as lines of JSON, which ReadHistory reads.
+func WriteHistory(writer io.Writer, history []AuctionOperation) error {
+	buffered := bufio.NewWriter(writer)
+	encoder := json.NewEncoder(buffered)
+	for _, operation := range history {
+		if err := encoder.Encode(operation); err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
+
+// ReadHistory reads the operations written by WriteHistory.
+func ReadHistory(reader io.Reader) ([]AuctionOperation, error) {
+	var history []AuctionOperation
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		var operation AuctionOperation
+		if err := json.Unmarshal(scanner.Bytes(), &operation); err != nil {
+			return nil, fmt.Errorf("line %d is not an operation: %w", line, err)
+		}
+		history = append(history, operation)
+	}
+	return history, scanner.Err()
+}
+
+// Describe writes the operation on one line, fx "client 3 bid alice 20 -> success [1.2ms, 3.4ms]".
+func Describe(operation AuctionOperation) string {
+	input := operation.Input.Kind
+	if operation.Input.Kind == KindBid {
+		input = fmt.Sprintf("bid %s %d", operation.Input.Bidder, operation.Input.Amount)
+	}
+	var output string
+	switch {
+	case operation.Output.Unknown:
+		output = "unknown"
+	case operation.Input.Kind == KindBid:
+		output = operation.Output.Status
+	case operation.Output.Winner != "":
+		output = fmt.Sprintf("%d won by %s", operation.Output.HighestBid, operation.Output.Winner)
+	default:
+		output = fmt.Sprint(operation.Output.HighestBid)
+	}
+	returned := "pending"
+	if operation.Return != Pending {
+		returned = time.Duration(operation.Return).String()
+	}
+	return fmt.Sprintf("client %d %s -> %s [%s, %s]", operation.Client, input, output, time.Duration(operation.Call), returned)
+}
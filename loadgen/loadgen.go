@@ -0,0 +1,350 @@
+// Load generator for the auction application, it measures how many bids per second the servers sustain
+package main
+
+import (
+	"Auction/auth"
+	"Auction/config"
+	"Auction/frontend"
+	"Auction/harness"
+	"Auction/tlsconfig"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// How often the rate hands out bids to the bidders
+const rateTick = 10 * time.Millisecond
+
+// How long a bidder waits before it asks its strategy again, after sitting a bid out
+const sitOutPause = 10 * time.Millisecond
+
+// How long a single bid may take
+const bidTimeout = 10 * time.Second
+
+// How long the auction runs after the first bid, when the configuration doesn't say
+const defaultAuctionDuration = 60 * time.Second
+
+func main() {
+	bidders := flag.Int("bidders", 1000, "the number of concurrent bidders")
+	frontends := flag.Int("frontends", 4, "the number of frontends the bidders share, every frontend sends its bids one at a time")
+	rate := flag.Float64("rate", 0, "the bids per second of all bidders together, 0 sends them as fast as the servers answer")
+	duration := flag.Duration("duration", 10*time.Second, "how long to send bids, 0 until the auction of the servers is over, an interrupt stops earlier")
+	strategyText := flag.String("strategy", "random", "the strategies of the bidders, fx \"increment\" or \"random:3,sniper:1\" to spread the bidders over them by weight, the strategies are "+strings.Join(strategyNames(), ", "))
+	replicas := flag.Int("replicas", 0, "start a cluster with the number of servers inside the process, instead of sending the bids to running servers")
+	servers := flag.String("servers", "", "comma separated grpc addresses of the servers, the default is the cluster section of -config or localhost:5000-5002")
+	configPath := flag.String("config", "", "read the servers from the cluster section of the JSON configuration file")
+	caFile := flag.String("ca", "", "connect with TLS, trusting the certificate authority in the file")
+	prefix := flag.String("prefix", "load", "the bidders are named prefix-0, prefix-1 and so on")
+	offset := flag.Int("offset", 0, "the number of the first bidder in the -strategy list, so the bidders of several loadgens are spread over the strategies")
+	users := flag.String("users", "", "comma separated users the bidders log in as, with -password or $AUCTION_PASSWORD, instead of bidding as prefix-N without logging in")
+	password := flag.String("password", os.Getenv("AUCTION_PASSWORD"), "the password of the -users")
+	follow := flag.Bool("follow", false, "read the highest bid after a bid that was too low, so the bidders follow the bids of other loadgens and clients")
+	jsonOutput := flag.Bool("json", false, "write the report as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-bidders n] [-frontends n] [-rate bids/s] [-duration time] [-strategy list] [-users list] [-replicas n | -servers addresses | -config file] [-json]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+
+	mix, err := parseStrategies(*strategyText)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *bidders < 1 || *frontends < 1 {
+		log.Fatal("there must be at least one bidder and one frontend")
+	}
+
+	//The frontends, either of a cluster inside the process or of the running servers
+	clients := make([]*frontend.AuctionClient, *frontends)
+	var target string
+	if *replicas > 0 {
+		if *duration == 0 {
+			log.Fatal("the servers inside the process don't end their auction, give a -duration")
+		}
+		cluster, err := harness.New(harness.WithReplicas(*replicas), harness.WithFrontends(*frontends))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer cluster.Close()
+		for i := range clients {
+			clients[i] = cluster.Frontend(i)
+		}
+		target = fmt.Sprintf("%d servers inside the process", *replicas)
+	} else {
+		frontendOptions, addresses, err := clusterOptions(*servers, *configPath, *caFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i := range clients {
+			clients[i], err = frontend.New(frontendOptions...)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer clients[i].Close()
+		}
+		target = addresses
+	}
+
+	if *duration == 0 {
+		*duration, err = untilOver(clients[0], *configPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	names := make([]string, *bidders)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%d", *prefix, i)
+	}
+	var tokens map[string]string
+	if *users != "" {
+		userNames := strings.Split(*users, ",")
+		for i := range names {
+			names[i] = userNames[i%len(userNames)]
+		}
+		tokens, err = login(clients[0], userNames, *password)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	summary := runLoad(load{clients: clients, names: names, tokens: tokens, mix: mix, offset: *offset, rate: *rate, duration: *duration, follow: *follow})
+	summary.Target = target
+	summary.Bidders = *bidders
+	summary.Frontends = *frontends
+	summary.Strategies = *strategyText
+	summary.TargetRate = *rate
+	if *jsonOutput {
+		if err := summary.writeJson(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	summary.writeText(os.Stdout)
+}
+
+// How long the auction of the servers has left, or the duration of the auction in the configuration if it hasn't started,
+// since it starts with the first bid
+func untilOver(client *frontend.AuctionClient, configPath string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), bidTimeout)
+	defer cancel()
+	auctions, err := client.Auctions(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("could not ask the servers when the auction is over: %w", err)
+	}
+	if len(auctions.Auctions) > 0 {
+		auction := auctions.Auctions[0]
+		remaining := time.Until(time.UnixMilli(auction.EndsAt))
+		if auction.IsOver || auction.IsCancelled || (auction.EndsAt > 0 && remaining <= 0) {
+			return 0, errors.New("the auction is already over")
+		}
+		if auction.EndsAt > 0 {
+			return remaining, nil
+		}
+	}
+	duration := defaultAuctionDuration
+	if configPath != "" {
+		clusterConfig, err := config.Load(configPath)
+		if err != nil {
+			return 0, err
+		}
+		if clusterConfig.Auction != nil && clusterConfig.Auction.Duration > 0 {
+			duration = time.Duration(clusterConfig.Auction.Duration)
+		}
+	}
+	return duration, nil
+}
+
+// Logs the users in, every user once, and returns their tokens
+func login(client *frontend.AuctionClient, users []string, password string) (map[string]string, error) {
+	tokens := make(map[string]string)
+	for _, user := range users {
+		ctx, cancel := context.WithTimeout(context.Background(), bidTimeout)
+		token, err := client.Login(ctx, user, password)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("could not log in as %s: %w", user, err)
+		}
+		tokens[user] = token.Token
+	}
+	return tokens, nil
+}
+
+// The options of the frontends for the running servers, and the servers for the report
+func clusterOptions(servers, configPath, caFile string) ([]frontend.Option, string, error) {
+	var options []frontend.Option
+	addresses := strings.Join(frontend.DefaultReplicas, ",")
+	if configPath != "" {
+		clusterConfig, err := config.Load(configPath)
+		if err != nil {
+			return nil, "", err
+		}
+		if clusterConfig.Cluster != nil {
+			clusterFrontendOptions, err := frontend.ClusterOptions(*clusterConfig.Cluster)
+			if err != nil {
+				return nil, "", err
+			}
+			options = append(options, clusterFrontendOptions...)
+			if len(clusterConfig.Cluster.Replicas) > 0 {
+				addresses = strings.Join(clusterConfig.Cluster.Replicas, ",")
+			}
+		}
+	}
+	if servers != "" {
+		options = append(options, frontend.WithDiscovery(frontend.StaticDiscovery(strings.Split(servers, ","))))
+		addresses = servers
+	}
+	if caFile != "" {
+		tlsConfig, err := tlsconfig.ClientConfig(caFile)
+		if err != nil {
+			return nil, "", err
+		}
+		options = append(options, frontend.WithDialOptions(grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))))
+	}
+	return options, addresses, nil
+}
+
+// The bidders and how they bid
+type load struct {
+	clients []*frontend.AuctionClient
+	// The names of the bidders, and the tokens of the names that log in
+	names  []string
+	tokens map[string]string
+	// The strategy of the ith bidder is the strategy of bidder offset+i in the mix
+	mix      *strategyMix
+	offset   int
+	rate     float64
+	duration time.Duration
+	follow   bool
+}
+
+// Lets the bidders bid until the time is up. With a rate the bids are handed out to the bidders at that rate,
+// whether or not the earlier bids have been answered, so a slow cluster makes the bids pile up instead of slowing the load
+func runLoad(load load) *report {
+	clients, names, tokens, rate, duration := load.clients, load.names, load.tokens, load.rate, load.duration
+	//An interrupt ends the run early, and the report covers the bids until then
+	interrupted, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(interrupted, duration)
+	defer cancel()
+	collector := newCollector()
+	var highest atomic.Int32
+	start := time.Now()
+
+	var tickets chan struct{}
+	if rate > 0 {
+		tickets = make(chan struct{}, max(1, int(rate*rateTick.Seconds())))
+		go handOut(ctx, tickets, rate, collector)
+	}
+
+	var wait sync.WaitGroup
+	for i := range names {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			random := rand.New(rand.NewSource(time.Now().UnixNano() + int64(i)))
+			bidder := names[i]
+			client := clients[i%len(clients)]
+			_, strategy := load.mix.forBidder(load.offset + i)
+			for {
+				if tickets != nil {
+					select {
+					case <-tickets:
+					case <-ctx.Done():
+						return
+					}
+				} else if ctx.Err() != nil {
+					return
+				}
+				amount, ok := strategy.next(random, highest.Load(), progress(start, duration))
+				if !ok {
+					//A bidder that sits out gives its bid to another bidder
+					if tickets != nil {
+						select {
+						case tickets <- struct{}{}:
+						default:
+						}
+					}
+					time.Sleep(sitOutPause)
+					continue
+				}
+				bidCtx, bidCancel := context.WithTimeout(context.Background(), bidTimeout)
+				if token, ok := tokens[bidder]; ok {
+					bidCtx = auth.WithToken(bidCtx, token)
+				}
+				callStart := time.Now()
+				ack, err := client.Bid(bidCtx, bidder, amount)
+				latency := time.Since(callStart)
+				bidCancel()
+				ackStatus := ""
+				if ack != nil {
+					ackStatus = ack.Status
+				}
+				collector.add(latency, outcome(ackStatus, err))
+				if ackStatus == "success" {
+					raise(&highest, amount)
+				}
+				//The read isn't part of the report, it only tells the bidders what was bid elsewhere
+				if load.follow && ackStatus == "fail - bid too low" {
+					resultCtx, resultCancel := context.WithTimeout(ctx, bidTimeout)
+					if token, ok := tokens[bidder]; ok {
+						resultCtx = auth.WithToken(resultCtx, token)
+					}
+					if result, err := client.Result(resultCtx); err == nil {
+						raise(&highest, result.HighestBid)
+					}
+					resultCancel()
+				}
+			}
+		}(i)
+	}
+	wait.Wait()
+	return collector.report(time.Since(start))
+}
+
+// Raises the highest bid the bidders know to the amount, if it is higher
+func raise(highest *atomic.Int32, amount int32) {
+	for {
+		current := highest.Load()
+		if amount <= current || highest.CompareAndSwap(current, amount) {
+			return
+		}
+	}
+}
+
+// Hands out the bids at the rate, and counts the bids that no bidder was free to take
+func handOut(ctx context.Context, tickets chan struct{}, rate float64, collector *collector) {
+	ticker := time.NewTicker(rateTick)
+	defer ticker.Stop()
+	start := time.Now()
+	handedOut := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		due := int(rate*time.Since(start).Seconds()) - handedOut
+		for ; due > 0; due-- {
+			select {
+			case tickets <- struct{}{}:
+			default:
+				collector.miss()
+			}
+			handedOut++
+		}
+	}
+}
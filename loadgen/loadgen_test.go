@@ -0,0 +1,199 @@
+package main
+
+import (
+	"Auction/harness"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNearestRank(t *testing.T) {
+	var latencies []time.Duration
+	for i := 1; i <= 100; i++ {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	tests := []struct {
+		fraction float64
+		want     time.Duration
+	}{
+		{0, time.Millisecond},
+		{0.5, 50 * time.Millisecond},
+		{0.9, 90 * time.Millisecond},
+		{0.99, 99 * time.Millisecond},
+		{0.999, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond},
+	}
+	for _, test := range tests {
+		if got := nearestRank(latencies, test.fraction); got != test.want {
+			t.Errorf("nearestRank(1..100ms, %v) = %v, want %v", test.fraction, got, test.want)
+		}
+	}
+	if got := nearestRank([]time.Duration{7}, 0.999); got != 7 {
+		t.Errorf("nearestRank of a single latency = %v, want 7", got)
+	}
+}
+
+func TestReport(t *testing.T) {
+	collector := newCollector()
+	for i := 1; i <= 10; i++ {
+		result := accepted
+		if i%2 == 0 {
+			result = "bid too low"
+		}
+		collector.add(time.Duration(i)*time.Millisecond, result)
+	}
+	collector.miss()
+	summary := collector.report(2 * time.Second)
+	if summary.Bids != 10 || summary.Missed != 1 {
+		t.Errorf("bids = %d and missed = %d, want 10 and 1", summary.Bids, summary.Missed)
+	}
+	if summary.Throughput != 5 || summary.Accepted != 2.5 {
+		t.Errorf("throughput = %v and accepted = %v, want 5 and 2.5", summary.Throughput, summary.Accepted)
+	}
+	want := map[string]float64{"mean": 5.5, "p50": 5, "p90": 9, "p99": 10, "max": 10}
+	for name, milliseconds := range want {
+		if summary.Latency[name] != milliseconds {
+			t.Errorf("%s = %v, want %v", name, summary.Latency[name], milliseconds)
+		}
+	}
+	if summary.Outcomes[accepted] != 5 || summary.Outcomes["bid too low"] != 5 {
+		t.Errorf("outcomes = %v", summary.Outcomes)
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	tests := []struct {
+		ackStatus string
+		err       error
+		want      string
+	}{
+		{"success", nil, accepted},
+		{"fail - bid too low", nil, "bid too low"},
+		{"fail - banned until 12:00:00", nil, "banned"},
+		{"", status.Error(codes.Unavailable, "down"), "error Unavailable"},
+		{"", errors.New("broken"), "error Unknown"},
+	}
+	for _, test := range tests {
+		if got := outcome(test.ackStatus, test.err); got != test.want {
+			t.Errorf("outcome(%q, %v) = %q, want %q", test.ackStatus, test.err, got, test.want)
+		}
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		if amount, ok := strategies["increment"].next(random, 100, 0); !ok || amount != 101 {
+			t.Fatalf("increment bid %d, %v, want 101", amount, ok)
+		}
+		if amount, ok := strategies["random"].next(random, 100, 0); !ok || amount < 95 || amount > 105 {
+			t.Fatalf("random bid %d, %v, want 95 to 105", amount, ok)
+		}
+		if amount, _ := strategies["random"].next(random, 0, 0); amount < 1 {
+			t.Fatalf("random bid %d on an empty auction, want at least 1", amount)
+		}
+		if _, ok := strategies["sniper"].next(random, 100, 0.79); ok {
+			t.Fatal("sniper bid before the last fifth of the run")
+		}
+		if amount, ok := strategies["sniper"].next(random, 100, 0.8); !ok || amount <= 100 {
+			t.Fatalf("sniper bid %d, %v at the end of the run, want above 100", amount, ok)
+		}
+	}
+
+	bids := 0
+	for i := 0; i < 10000; i++ {
+		amount, ok := strategies["jump"].next(random, 100, 0)
+		if !ok {
+			continue
+		}
+		bids++
+		if amount < 110 {
+			t.Fatalf("jump bid %d, want at least 110", amount)
+		}
+	}
+	if bids < 800 || bids > 1200 {
+		t.Errorf("jump bid %d times out of 10000, want about 1000", bids)
+	}
+}
+
+func TestParseStrategies(t *testing.T) {
+	mix, err := parseStrategies("random:3, sniper:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for i := 0; i < 8; i++ {
+		name, _ := mix.forBidder(i)
+		names = append(names, name)
+	}
+	if got, want := fmt.Sprint(names), "[random random random sniper random random random sniper]"; got != want {
+		t.Errorf("the strategies of the bidders are %s, want %s", got, want)
+	}
+	for _, text := range []string{"greedy", "random:0", "random:x", ""} {
+		if _, err := parseStrategies(text); err == nil {
+			t.Errorf("parseStrategies(%q) didn't fail", text)
+		}
+	}
+}
+
+// A bid sent by one frontend to every replication manager of a cluster inside the process
+func BenchmarkClusterBid(b *testing.B) {
+	for _, replicas := range []int{1, 3, 5} {
+		b.Run(fmt.Sprintf("replicas=%d", replicas), func(b *testing.B) {
+			cluster, err := harness.New(harness.WithReplicas(replicas))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer cluster.Close()
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ack, err := cluster.Frontend(0).Bid(ctx, "bench", int32(i+1))
+				if err != nil {
+					b.Fatal(err)
+				}
+				if ack.Status != "success" {
+					b.Fatalf("bid %d: %s", i+1, ack.Status)
+				}
+			}
+		})
+	}
+}
+
+// Bids sent at the same time by a frontend per goroutine, so the bids only wait for each other in the replication managers
+func BenchmarkClusterBidParallel(b *testing.B) {
+	cluster, err := harness.New(harness.WithReplicas(3), harness.WithFrontends(0))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer cluster.Close()
+	var amount atomic.Int32
+	var frontends atomic.Int32
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		name := harness.FrontendName(int(frontends.Add(1)))
+		auctionClient, err := cluster.NewFrontend(name)
+		if err != nil {
+			b.Error(err)
+			return
+		}
+		defer auctionClient.Close()
+		ctx := context.Background()
+		for pb.Next() {
+			//Bids may be outbid by the other goroutines, only errors fail the benchmark
+			if _, err := auctionClient.Bid(ctx, name, amount.Add(1)); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// The outcome of a bid that was accepted
+const accepted = "accepted"
+
+// Collects the latency and outcome of every bid, it is safe for concurrent use
+type collector struct {
+	mutex     sync.Mutex
+	latencies []time.Duration
+	outcomes  map[string]int
+	// Bids the rate asked for that no bidder was free to send
+	missed int
+}
+
+func newCollector() *collector {
+	return &collector{outcomes: make(map[string]int)}
+}
+
+func (collector *collector) add(latency time.Duration, outcome string) {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	collector.latencies = append(collector.latencies, latency)
+	collector.outcomes[outcome]++
+}
+
+func (collector *collector) miss() {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	collector.missed++
+}
+
+// The outcome of a bid: accepted, the reason it was rejected without the times, or the grpc code of the error
+func outcome(ackStatus string, err error) string {
+	if err != nil {
+		return "error " + status.Code(err).String()
+	}
+	if ackStatus == "success" {
+		return accepted
+	}
+	reason := strings.TrimPrefix(ackStatus, "fail - ")
+	reason, _, _ = strings.Cut(reason, " until ")
+	reason, _, _ = strings.Cut(reason, ",")
+	return reason
+}
+
+// The summary of a run
+type report struct {
+	Target     string         `json:"target"`
+	Bidders    int            `json:"bidders"`
+	Frontends  int            `json:"frontends"`
+	Strategies string         `json:"strategies"`
+	TargetRate float64        `json:"targetRate,omitempty"`
+	Duration   float64        `json:"durationSeconds"`
+	Bids       int            `json:"bids"`
+	Throughput float64        `json:"bidsPerSecond"`
+	Accepted   float64        `json:"acceptedPerSecond"`
+	Missed     int            `json:"missed,omitempty"`
+	Latency    map[string]any `json:"latencyMilliseconds"`
+	Outcomes   map[string]int `json:"outcomes"`
+}
+
+// The percentiles in the report, as their names and fractions
+var percentiles = []struct {
+	name     string
+	fraction float64
+}{{"p50", 0.5}, {"p90", 0.9}, {"p99", 0.99}, {"p99.9", 0.999}}
+
+func (collector *collector) report(elapsed time.Duration) *report {
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	latencies := append([]time.Duration(nil), collector.latencies...)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	summary := &report{
+		Duration: elapsed.Seconds(),
+		Bids:     len(latencies),
+		Missed:   collector.missed,
+		Latency:  make(map[string]any),
+		Outcomes: make(map[string]int),
+	}
+	for outcome, count := range collector.outcomes {
+		summary.Outcomes[outcome] = count
+	}
+	if elapsed > 0 {
+		summary.Throughput = float64(len(latencies)) / elapsed.Seconds()
+		summary.Accepted = float64(collector.outcomes[accepted]) / elapsed.Seconds()
+	}
+	if len(latencies) == 0 {
+		return summary
+	}
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+	summary.Latency["mean"] = milliseconds(total / time.Duration(len(latencies)))
+	for _, percentile := range percentiles {
+		summary.Latency[percentile.name] = milliseconds(nearestRank(latencies, percentile.fraction))
+	}
+	summary.Latency["max"] = milliseconds(latencies[len(latencies)-1])
+	return summary
+}
+
+// The smallest latency that the fraction of the sorted latencies is at or below, which must not be empty
+func nearestRank(sorted []time.Duration, fraction float64) time.Duration {
+	rank := int(math.Ceil(float64(len(sorted)) * fraction))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
+
+func milliseconds(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
+
+func (summary *report) writeJson(writer io.Writer) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(summary)
+}
+
+func (summary *report) writeText(writer io.Writer) {
+	fmt.Fprintf(writer, "%d bidders (%s) on %d frontends against %s for %.1fs\n", summary.Bidders, summary.Strategies, summary.Frontends, summary.Target, summary.Duration)
+	if summary.TargetRate > 0 {
+		fmt.Fprintf(writer, "Target rate:  %.0f bids/s\n", summary.TargetRate)
+	}
+	fmt.Fprintf(writer, "Throughput:   %.1f bids/s, %.1f accepted/s, %d bids\n", summary.Throughput, summary.Accepted, summary.Bids)
+	if summary.Missed > 0 {
+		fmt.Fprintf(writer, "Missed:       %d bids, every bidder was busy when the rate asked for them\n", summary.Missed)
+	}
+	if len(summary.Latency) > 0 {
+		fmt.Fprintf(writer, "Latency (ms): mean %.2f", summary.Latency["mean"])
+		for _, percentile := range percentiles {
+			fmt.Fprintf(writer, "  %s %.2f", percentile.name, summary.Latency[percentile.name])
+		}
+		fmt.Fprintf(writer, "  max %.2f\n", summary.Latency["max"])
+	}
+
+	fmt.Fprintln(writer, "\nOutcomes:")
+	outcomes := make([]string, 0, len(summary.Outcomes))
+	for outcome := range summary.Outcomes {
+		outcomes = append(outcomes, outcome)
+	}
+	sort.Slice(outcomes, func(i, j int) bool { return summary.Outcomes[outcomes[i]] > summary.Outcomes[outcomes[j]] })
+	table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	for _, outcome := range outcomes {
+		fmt.Fprintf(table, "  %s\t%d\t%.1f%%\n", outcome, summary.Outcomes[outcome], 100*float64(summary.Outcomes[outcome])/float64(max(1, summary.Bids)))
+	}
+	table.Flush()
+}
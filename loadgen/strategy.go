@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A strategy decides what a bidder bids, from the highest bid the bidders have seen and how far the run has got.
+// It returns false when the bidder sits this bid out.
+type strategy interface {
+	next(random *rand.Rand, highest int32, progress float64) (int32, bool)
+}
+
+var strategies = map[string]strategy{
+	// Always just above the highest bid
+	"increment": incrementStrategy{},
+	// Around the highest bid, so some of the bids are too low
+	"random": randomStrategy{},
+	// Only bids in the last fifth of the run, and then a lot above the highest bid
+	"sniper": sniperStrategy{},
+	// Rarely bids, but then far above the highest bid
+	"jump": jumpStrategy{},
+}
+
+type incrementStrategy struct{}
+
+func (incrementStrategy) next(random *rand.Rand, highest int32, progress float64) (int32, bool) {
+	return highest + 1, true
+}
+
+type randomStrategy struct{}
+
+func (randomStrategy) next(random *rand.Rand, highest int32, progress float64) (int32, bool) {
+	return max(1, highest+int32(random.Intn(11))-5), true
+}
+
+type sniperStrategy struct{}
+
+func (sniperStrategy) next(random *rand.Rand, highest int32, progress float64) (int32, bool) {
+	if progress < 0.8 {
+		return 0, false
+	}
+	return highest + int32(random.Intn(20)) + 1, true
+}
+
+type jumpStrategy struct{}
+
+func (jumpStrategy) next(random *rand.Rand, highest int32, progress float64) (int32, bool) {
+	if random.Intn(10) != 0 {
+		return 0, false
+	}
+	return highest + int32(random.Intn(100)) + 10, true
+}
+
+// The strategies of the bidders from a list like "increment" or "random:3,sniper:1", where the numbers are weights
+type strategyMix struct {
+	names   []string
+	weights []int
+	total   int
+}
+
+func parseStrategies(text string) (*strategyMix, error) {
+	mix := &strategyMix{}
+	for _, part := range strings.Split(text, ",") {
+		name, weightText, hasWeight := strings.Cut(strings.TrimSpace(part), ":")
+		if _, ok := strategies[name]; !ok {
+			return nil, fmt.Errorf("there is no strategy %q, the strategies are %s", name, strings.Join(strategyNames(), ", "))
+		}
+		weight := 1
+		if hasWeight {
+			if _, err := fmt.Sscan(weightText, &weight); err != nil || weight < 1 {
+				return nil, fmt.Errorf("the weight of %s must be a positive number", name)
+			}
+		}
+		mix.names = append(mix.names, name)
+		mix.weights = append(mix.weights, weight)
+		mix.total += weight
+	}
+	return mix, nil
+}
+
+// The strategy of the ith bidder, so the bidders are spread over the strategies by their weights
+func (mix *strategyMix) forBidder(i int) (string, strategy) {
+	slot := i % mix.total
+	for j, weight := range mix.weights {
+		if slot < weight {
+			return mix.names[j], strategies[mix.names[j]]
+		}
+		slot -= weight
+	}
+	return mix.names[0], strategies[mix.names[0]]
+}
+
+func strategyNames() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// How far the run has got, from 0 to 1
+func progress(start time.Time, duration time.Duration) float64 {
+	return min(1, float64(time.Since(start))/float64(duration))
+}
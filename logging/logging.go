@@ -0,0 +1,91 @@
+// Package logging sets up the structured logs of the client and the servers, and carries request ids.
+//
+// A request id is made by the client for every command and sent in the x-request-id metadata of the grpc calls,
+// so the frontend and every replication manager log the request with the same id. The loggers made by New
+// add the request id of the context to every record logged with a context, fx with InfoContext.
+package logging
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// The formats of the logs
+const (
+	FormatText = "text"
+	FormatJson = "json"
+)
+
+// Flags are the command line flags of the logs, see AddFlags.
+type Flags struct {
+	level  *string
+	format *string
+}
+
+// AddFlags adds -log-level and -log-format to the flag set.
+func AddFlags(flags *flag.FlagSet) *Flags {
+	return &Flags{
+		level:  flags.String("log-level", "info", "log the messages of the level and above: debug, info, warn or error"),
+		format: flags.String("log-format", FormatText, "write the logs as text or json"),
+	}
+}
+
+// New creates the logger chosen with the flags.
+func (flags *Flags) New(writer io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*flags.level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q", *flags.level)
+	}
+	return New(writer, *flags.format, level)
+}
+
+// New creates a logger that writes records of the level and above in the format, FormatText or FormatJson.
+func New(writer io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	handlerOptions := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case FormatText:
+		handler = slog.NewTextHandler(writer, handlerOptions)
+	case FormatJson:
+		handler = slog.NewJSONHandler(writer, handlerOptions)
+	default:
+		return nil, fmt.Errorf("unknown log format %q, it must be %s or %s", format, FormatText, FormatJson)
+	}
+	return slog.New(requestIdHandler{handler}), nil
+}
+
+// Discard returns a logger that logs nothing.
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// Fatal logs the message at the error level with the default logger and exits.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// Adds the request id of the context to the records
+type requestIdHandler struct {
+	slog.Handler
+}
+
+func (handler requestIdHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestId := RequestIdFromContext(ctx); requestId != "" {
+		record.AddAttrs(slog.String("request_id", requestId))
+	}
+	return handler.Handler.Handle(ctx, record)
+}
+
+func (handler requestIdHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIdHandler{handler.Handler.WithAttrs(attrs)}
+}
+
+func (handler requestIdHandler) WithGroup(name string) slog.Handler {
+	return requestIdHandler{handler.Handler.WithGroup(name)}
+}
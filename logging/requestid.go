@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// The key of the request id in the grpc metadata, and as X-Request-Id the header of the HTTP API
+const requestIdKey = "x-request-id"
+
+// Request ids are at most this long, a longer id from a caller is replaced
+const maxRequestIdLength = 64
+
+type contextKey struct{}
+
+// NewRequestId returns a random request id.
+func NewRequestId() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// ContextWithRequestId returns a context with the request id.
+func ContextWithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, contextKey{}, requestId)
+}
+
+// RequestIdFromContext returns the request id of the context, or "" if it has none.
+func RequestIdFromContext(ctx context.Context) string {
+	requestId, _ := ctx.Value(contextKey{}).(string)
+	return requestId
+}
+
+// EnsureRequestId returns the context with a new request id if it doesn't have one yet.
+func EnsureRequestId(ctx context.Context) context.Context {
+	if RequestIdFromContext(ctx) != "" {
+		return ctx
+	}
+	return ContextWithRequestId(ctx, NewRequestId())
+}
+
+// Inject adds the request id of the context to the outgoing grpc metadata.
+func Inject(ctx context.Context) context.Context {
+	requestId := RequestIdFromContext(ctx)
+	if requestId == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, requestIdKey, requestId)
+}
+
+// Extract returns the context with the request id of the incoming grpc metadata,
+// or with a new request id if the caller didn't send one.
+func Extract(ctx context.Context) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIdKey); len(values) > 0 && validRequestId(values[0]) {
+			return ContextWithRequestId(ctx, values[0])
+		}
+	}
+	return ContextWithRequestId(ctx, NewRequestId())
+}
+
+// ExtractHttp returns the context of the request with the request id of its X-Request-Id header,
+// or with a new request id, and sets the header of the response to the request id.
+func ExtractHttp(writer http.ResponseWriter, request *http.Request) context.Context {
+	requestId := request.Header.Get(requestIdKey)
+	if !validRequestId(requestId) {
+		requestId = NewRequestId()
+	}
+	writer.Header().Set(requestIdKey, requestId)
+	return ContextWithRequestId(request.Context(), requestId)
+}
+
+// The request id is written to the logs, so it must be short and printable
+func validRequestId(requestId string) bool {
+	if requestId == "" || len(requestId) > maxRequestIdLength {
+		return false
+	}
+	for _, r := range requestId {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// UnaryServerInterceptor gives every call the request id of the caller, and logs the call at the debug level.
+func UnaryServerInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx = Extract(ctx)
+		start := time.Now()
+		response, err := handler(ctx, request)
+		logCall(ctx, logger, info.FullMethod, start, err)
+		return response, err
+	}
+}
+
+// StreamServerInterceptor gives every stream the request id of the caller, and logs the stream at the debug level
+// when it ends.
+func StreamServerInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(server any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := Extract(stream.Context())
+		start := time.Now()
+		err := handler(server, &contextStream{ServerStream: stream, ctx: ctx})
+		logCall(ctx, logger, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, method string, start time.Time, err error) {
+	logger.DebugContext(ctx, "Handled call", "method", method, "code", status.Code(err).String(), "duration", time.Since(start))
+}
+
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *contextStream) Context() context.Context {
+	return stream.ctx
+}
@@ -0,0 +1,232 @@
+// Package metrics collects counters, gauges and histograms and serves them in the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds of the latency histograms, in seconds.
+var DefaultBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// A metric with its series, which are keyed by their label values
+type family struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	mutex   sync.Mutex
+	series  map[string]*series
+	// For gauges that are computed when the metrics are read
+	collect func(set func(value float64, labelValues ...string))
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	// For histograms, the counts of the buckets and the sum of the observations
+	counts []uint64
+	sum    float64
+}
+
+// Registry holds the metrics. It is safe for concurrent use.
+type Registry struct {
+	mutex    sync.Mutex
+	families []*family
+	byName   map[string]*family
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{byName: make(map[string]*family)}
+}
+
+// Returns the metric with the name, and creates it if it doesn't exist
+// A metric registered twice is shared, fx by two frontends that use the same registry
+func (registry *Registry) register(newFamily *family) *family {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if existing, ok := registry.byName[newFamily.name]; ok {
+		return existing
+	}
+	newFamily.series = make(map[string]*series)
+	registry.byName[newFamily.name] = newFamily
+	registry.families = append(registry.families, newFamily)
+	return newFamily
+}
+
+// Counter is a value that only goes up, fx the number of requests.
+type Counter struct{ family *family }
+
+// Gauge is a value that goes up and down, fx the number of connected servers.
+type Gauge struct{ family *family }
+
+// Histogram counts observations in buckets, fx the latency of requests.
+type Histogram struct{ family *family }
+
+// Counter registers a counter with the label names.
+func (registry *Registry) Counter(name string, help string, labels ...string) *Counter {
+	return &Counter{registry.register(&family{name: name, help: help, kind: "counter", labels: labels})}
+}
+
+// Gauge registers a gauge with the label names.
+func (registry *Registry) Gauge(name string, help string, labels ...string) *Gauge {
+	return &Gauge{registry.register(&family{name: name, help: help, kind: "gauge", labels: labels})}
+}
+
+// GaugeFunc registers a gauge whose values are computed by collect every time the metrics are read.
+func (registry *Registry) GaugeFunc(name string, help string, labels []string, collect func(set func(value float64, labelValues ...string))) {
+	registry.register(&family{name: name, help: help, kind: "gauge", labels: labels, collect: collect})
+}
+
+// Histogram registers a histogram with the upper bounds of the buckets and the label names.
+func (registry *Registry) Histogram(name string, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{registry.register(&family{name: name, help: help, kind: "histogram", labels: labels, buckets: buckets})}
+}
+
+// Add adds the value to the series with the label values.
+func (counter *Counter) Add(value float64, labelValues ...string) {
+	counter.family.update(labelValues, func(s *series) { s.value += value })
+}
+
+// Inc adds one to the series with the label values.
+func (counter *Counter) Inc(labelValues ...string) {
+	counter.Add(1, labelValues...)
+}
+
+// Set sets the series with the label values.
+func (gauge *Gauge) Set(value float64, labelValues ...string) {
+	gauge.family.update(labelValues, func(s *series) { s.value = value })
+}
+
+// Add adds the value, which may be negative, to the series with the label values.
+func (gauge *Gauge) Add(value float64, labelValues ...string) {
+	gauge.family.update(labelValues, func(s *series) { s.value += value })
+}
+
+// Observe adds an observation to the series with the label values.
+func (histogram *Histogram) Observe(value float64, labelValues ...string) {
+	buckets := histogram.family.buckets
+	histogram.family.update(labelValues, func(s *series) {
+		if s.counts == nil {
+			s.counts = make([]uint64, len(buckets))
+		}
+		for i, bound := range buckets {
+			if value <= bound {
+				s.counts[i]++
+			}
+		}
+		s.sum += value
+		s.value++
+	})
+}
+
+func (family *family) update(labelValues []string, apply func(*series)) {
+	if len(labelValues) != len(family.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", family.name, len(family.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	family.mutex.Lock()
+	defer family.mutex.Unlock()
+	s, ok := family.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		family.series[key] = s
+	}
+	apply(s)
+}
+
+// Returns a copy of the series sorted by their label values, so the output is stable
+func (family *family) snapshot() []series {
+	var snapshot []series
+	if family.collect != nil {
+		family.collect(func(value float64, labelValues ...string) {
+			snapshot = append(snapshot, series{labelValues: labelValues, value: value})
+		})
+	} else {
+		family.mutex.Lock()
+		for _, s := range family.series {
+			copied := *s
+			copied.counts = append([]uint64(nil), s.counts...)
+			snapshot = append(snapshot, copied)
+		}
+		family.mutex.Unlock()
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		return strings.Join(snapshot[i].labelValues, "\xff") < strings.Join(snapshot[j].labelValues, "\xff")
+	})
+	return snapshot
+}
+
+// Write writes all metrics in the Prometheus text format.
+func (registry *Registry) Write(writer io.Writer) error {
+	registry.mutex.Lock()
+	families := append([]*family(nil), registry.families...)
+	registry.mutex.Unlock()
+
+	buffered := bufio.NewWriter(writer)
+	for _, family := range families {
+		fmt.Fprintf(buffered, "# HELP %s %s\n# TYPE %s %s\n", family.name, helpEscaper.Replace(family.help), family.name, family.kind)
+		for _, s := range family.snapshot() {
+			if family.kind != "histogram" {
+				fmt.Fprintf(buffered, "%s%s %s\n", family.name, formatLabels(family.labels, s.labelValues, ""), formatValue(s.value))
+				continue
+			}
+			for i, bound := range family.buckets {
+				fmt.Fprintf(buffered, "%s_bucket%s %d\n", family.name, formatLabels(family.labels, s.labelValues, formatValue(bound)), s.counts[i])
+			}
+			fmt.Fprintf(buffered, "%s_bucket%s %s\n", family.name, formatLabels(family.labels, s.labelValues, "+Inf"), formatValue(s.value))
+			fmt.Fprintf(buffered, "%s_sum%s %s\n", family.name, formatLabels(family.labels, s.labelValues, ""), formatValue(s.sum))
+			fmt.Fprintf(buffered, "%s_count%s %s\n", family.name, formatLabels(family.labels, s.labelValues, ""), formatValue(s.value))
+		}
+	}
+	return buffered.Flush()
+}
+
+// Handler serves the metrics, for the /metrics path.
+func (registry *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		registry.Write(writer)
+	})
+}
+
+// Formats the labels as {name="value",...}, with the le label of a histogram bucket if it is given
+func formatLabels(names []string, values []string, le string) string {
+	if len(names) == 0 && le == "" {
+		return ""
+	}
+	pairs := make([]string, 0, len(names)+1)
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+labelEscaper.Replace(values[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// The text format escapes backslashes and newlines in help texts, and also quotes in label values
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
@@ -0,0 +1,82 @@
+// Package ratelimit limits how fast bidders and connections may call the servers, with token buckets.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Buckets that haven't been used for this long are full again and can be forgotten
+const idleTimeout = 10 * time.Minute
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// Limiter has a token bucket for every key. A bucket holds up to burst tokens and gains rate tokens per second,
+// and every request takes one token.
+type Limiter struct {
+	rate      float64
+	burst     float64
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewLimiter creates a Limiter, a burst below 1 is raised to 1.
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of the key, and tells whether there was one.
+func (limiter *Limiter) Allow(key string) bool {
+	return limiter.AllowAt(key, limiter.now())
+}
+
+// AllowAt is Allow at the given time instead of the time of the machine. The bucket only gains tokens
+// when the time is later than the last time it was used, so the same requests at the same times
+// get the same answers on every machine.
+func (limiter *Limiter) AllowAt(key string, now time.Time) bool {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.sweep(now)
+
+	b, ok := limiter.buckets[key]
+	if !ok {
+		b = &bucket{tokens: limiter.burst, lastSeen: now}
+		limiter.buckets[key] = b
+	}
+	if now.After(b.lastSeen) {
+		b.tokens = min(limiter.burst, b.tokens+now.Sub(b.lastSeen).Seconds()*limiter.rate)
+		b.lastSeen = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Forgets the buckets that haven't been used for a while, so the map doesn't grow with every caller ever seen
+func (limiter *Limiter) sweep(now time.Time) {
+	if now.Sub(limiter.lastSweep) < idleTimeout {
+		return
+	}
+	limiter.lastSweep = now
+	for key, b := range limiter.buckets {
+		if now.Sub(b.lastSeen) > idleTimeout {
+			delete(limiter.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowAt(t *testing.T) {
+	limiter := NewLimiter(1, 2)
+	start := time.Unix(1000, 0)
+	allowed := func(at time.Time) bool { return limiter.AllowAt("alice", at) }
+
+	if !allowed(start) || !allowed(start) {
+		t.Fatal("the burst isn't allowed")
+	}
+	if allowed(start) {
+		t.Fatal("a bid above the burst is allowed")
+	}
+	//An earlier time doesn't fill the bucket, and doesn't stop the bucket from filling later
+	if allowed(start.Add(-time.Hour)) {
+		t.Fatal("a bid sent earlier is allowed")
+	}
+	if !allowed(start.Add(time.Second)) {
+		t.Fatal("the bucket didn't gain a token after a second")
+	}
+	if allowed(start.Add(time.Second)) {
+		t.Fatal("the bucket gained more than one token after a second")
+	}
+}
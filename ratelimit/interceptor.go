@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"Auction/config"
+	proto "Auction/grpc"
+	"Auction/tlsconfig"
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Limits has a bucket for every bidder and one for every connection. A nil limiter means the limit is off.
+type Limits struct {
+	bids  *Limiter
+	calls *Limiter
+}
+
+// New creates the limits from the rate limits section of the configuration.
+func New(rateLimits config.RateLimits) *Limits {
+	limits := &Limits{}
+	if rateLimits.BidsPerSecond > 0 {
+		limits.bids = NewLimiter(rateLimits.BidsPerSecond, rateLimits.BidBurst)
+	}
+	if rateLimits.CallsPerSecond > 0 {
+		limits.calls = NewLimiter(rateLimits.CallsPerSecond, rateLimits.CallBurst)
+	}
+	return limits
+}
+
+// CheckBid returns a RESOURCE_EXHAUSTED error if the bidder has sent too many bids.
+func (limits *Limits) CheckBid(bidder string) error {
+	if limits.bids == nil || bidder == "" || limits.bids.Allow(bidder) {
+		return nil
+	}
+	return status.Errorf(codes.ResourceExhausted, "%s is bidding too fast, slow down", bidder)
+}
+
+// CheckBidAt is CheckBid for a bid sent at the given time. The replication managers use the time the frontend
+// sent the bid, so they all reject the same bids when they apply the bids in the same order.
+func (limits *Limits) CheckBidAt(bidder string, sentAt time.Time) error {
+	if limits.bids == nil || bidder == "" || limits.bids.AllowAt(bidder, sentAt) {
+		return nil
+	}
+	return status.Errorf(codes.ResourceExhausted, "%s is bidding too fast, slow down", bidder)
+}
+
+// CheckConnection returns a RESOURCE_EXHAUSTED error if too many calls came from the address.
+func (limits *Limits) CheckConnection(address string) error {
+	if limits.calls == nil || limits.calls.Allow(address) {
+		return nil
+	}
+	return status.Errorf(codes.ResourceExhausted, "too many requests from %s, slow down", address)
+}
+
+// UnaryServerInterceptor limits the calls of every connection.
+// Bids, commitments and reveals are left out: a frontend sends them to every replication manager, but reads only to one,
+// so the bucket of its connection could be empty on one replication manager and not on the others, and they would disagree
+// on which bids were placed. The bids are limited by their bidder with CheckBidAt instead, in the order they are applied.
+func (limits *Limits) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		switch request.(type) {
+		case *proto.BidMessage, *proto.Commitment, *proto.Reveal:
+			return handler(ctx, request)
+		}
+		if err := limits.checkPeer(ctx); err != nil {
+			return nil, err
+		}
+		return handler(ctx, request)
+	}
+}
+
+// StreamServerInterceptor limits how often every connection may open a stream.
+func (limits *Limits) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(server any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := limits.checkPeer(stream.Context()); err != nil {
+			return err
+		}
+		return handler(server, stream)
+	}
+}
+
+// The other servers of the cluster forward the calls of many users, fx the HTTP gateway, so they are not limited
+func (limits *Limits) checkPeer(ctx context.Context) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok || tlsconfig.IsClusterMember(ctx) {
+		return nil
+	}
+	return limits.CheckConnection(p.Addr.String())
+}
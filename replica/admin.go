@@ -0,0 +1,87 @@
+package replica
+
+import (
+	proto "Auction/grpc"
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc/stats"
+)
+
+// The roles in the status, see NodeStatus in the proto file
+const (
+	roleLeader   = "leader"
+	roleFollower = "follower"
+)
+
+// The Admin service of the replication manager, it is a type of its own so its methods don't mix with the auction
+type adminServer struct {
+	proto.UnimplementedAdminServer
+	replicationManager *ReplicationManager
+}
+
+func (admin *adminServer) Status(ctx context.Context, empty *proto.Empty) (*proto.NodeStatus, error) {
+	replicationManager := admin.replicationManager
+	nodeStatus := &proto.NodeStatus{
+		Id:          replicationManager.options.id,
+		Address:     replicationManager.options.address,
+		Auctions:    1,
+		Connections: int32(replicationManager.connections.open.Load()),
+	}
+
+	//The other replication managers are asked before taking the mutex, so a slow one doesn't hold up the bids
+	//The role needs the address of this replication manager to find it among them
+	if peers := replicationManager.options.peers; peers != nil {
+		statuses, err := peers.Probe(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, peerStatus := range statuses {
+			nodeStatus.Members = append(nodeStatus.Members, &proto.Member{Address: peerStatus.Address, Up: peerStatus.Up, Bids: int32(peerStatus.Bids)})
+			//The frontends go through the replication managers in the order of the discovery
+			if peerStatus.Up && nodeStatus.Role == "" && nodeStatus.Address != "" {
+				nodeStatus.Role = roleFollower
+				if peerStatus.Address == nodeStatus.Address {
+					nodeStatus.Role = roleLeader
+				}
+			}
+		}
+	}
+
+	//The checksum covers the names of the bidders as the caller is shown them
+	show := replicationManager.viewer(ctx)
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+	nodeStatus.LastApplied = replicationManager.applied
+	nodeStatus.Bids = int32(len(replicationManager.bidHistory))
+	nodeStatus.Watchers = int32(len(replicationManager.watchers))
+	nodeStatus.Checksum = replicationManager.checksum(show)
+	if !replicationManager.startedAt.IsZero() {
+		nodeStatus.StartedAt = replicationManager.startedAt.UnixMilli()
+	}
+	return nodeStatus, nil
+}
+
+// Counts the open grpc connections, for the status
+type connectionCounter struct {
+	open atomic.Int64
+}
+
+func (counter *connectionCounter) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (counter *connectionCounter) HandleRPC(ctx context.Context, rpcStats stats.RPCStats) {}
+
+func (counter *connectionCounter) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (counter *connectionCounter) HandleConn(ctx context.Context, connStats stats.ConnStats) {
+	switch connStats.(type) {
+	case *stats.ConnBegin:
+		counter.open.Add(1)
+	case *stats.ConnEnd:
+		counter.open.Add(-1)
+	}
+}
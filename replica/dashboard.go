@@ -0,0 +1,18 @@
+// Browser dashboard of the auction, embedded in the server binary
+
+package replica
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// Serves the dashboard at the root of the HTTP server, it only talks to the HTTP API under /v1/
+func dashboardHandler() http.Handler {
+	files, _ := fs.Sub(dashboardFiles, "dashboard")
+	return http.FileServer(http.FS(files))
+}
@@ -0,0 +1,128 @@
+// Dashboard of the auction, it is updated by the server-sent events from /v1/events
+const highestBid = document.getElementById("highest-bid");
+const countdown = document.getElementById("countdown");
+const winner = document.getElementById("winner");
+const bids = document.getElementById("bids");
+const message = document.getElementById("message");
+const signInForm = document.getElementById("sign-in");
+const bidForm = document.getElementById("bid");
+
+let endsAt = 0;
+let isOver = false;
+
+// The bidder name and token are kept for the browser session
+// The token is missing when the server doesn't have authentication enabled
+function bidder() {
+    return sessionStorage.getItem("bidder");
+}
+
+function token() {
+    return sessionStorage.getItem("token");
+}
+
+function signOut() {
+    sessionStorage.removeItem("bidder");
+    sessionStorage.removeItem("token");
+    showBidder();
+}
+
+function showBidder() {
+    signInForm.hidden = bidder() !== null;
+    bidForm.hidden = bidder() === null;
+    document.getElementById("signed-in").textContent = bidder() ? "Bidding as " + bidder() : "";
+}
+
+function showMessage(text, isError) {
+    message.textContent = text;
+    message.className = isError ? "error" : "";
+}
+
+function updateCountdown() {
+    if (isOver) {
+        countdown.textContent = "closed";
+    } else if (endsAt === 0) {
+        countdown.textContent = "not started";
+    } else {
+        const seconds = Math.max(0, Math.ceil((endsAt - Date.now()) / 1000));
+        countdown.textContent = seconds + " s";
+    }
+}
+
+async function loadHistory() {
+    const response = await fetch("v1/bids");
+    const history = await response.json();
+    bids.replaceChildren(...history.bids.slice().reverse().map(bid => {
+        const row = document.createElement("tr");
+        for (const text of [new Date(Number(bid.timestamp)).toLocaleTimeString(), bid.id, bid.amount]) {
+            const cell = document.createElement("td");
+            cell.textContent = text;
+            row.appendChild(cell);
+        }
+        return row;
+    }));
+}
+
+function watch() {
+    const events = new EventSource("v1/events");
+    events.addEventListener("auction", event => {
+        const auction = JSON.parse(event.data);
+        highestBid.textContent = auction.highestBid;
+        winner.textContent = auction.isCancelled ? "cancelled" : (auction.winner || "-");
+        endsAt = Number(auction.endsAt);
+        isOver = auction.isOver;
+        updateCountdown();
+        loadHistory();
+    });
+}
+
+signInForm.addEventListener("submit", async event => {
+    event.preventDefault();
+    const name = document.getElementById("name").value;
+    const response = await fetch("v1/login", {
+        method: "POST",
+        headers: {"Content-Type": "application/json"},
+        body: JSON.stringify({id: name, password: document.getElementById("password").value}),
+    });
+    if (response.ok) {
+        sessionStorage.setItem("token", (await response.json()).token);
+    } else if (response.status !== 501) {
+        // 501 means authentication is not enabled, then the name is enough to bid
+        showMessage(await response.text(), true);
+        return;
+    }
+    document.getElementById("password").value = "";
+    sessionStorage.setItem("bidder", name);
+    showMessage("", false);
+    showBidder();
+});
+
+document.getElementById("sign-out").addEventListener("click", signOut);
+
+bidForm.addEventListener("submit", async event => {
+    event.preventDefault();
+    const amount = Number(document.getElementById("amount").value);
+    const headers = {"Content-Type": "application/json"};
+    if (token()) {
+        headers["Authorization"] = "Bearer " + token();
+    }
+    const response = await fetch("v1/bids", {
+        method: "POST",
+        headers: headers,
+        body: JSON.stringify({id: bidder(), amount: amount}),
+    });
+    if (response.status === 401) {
+        signOut();
+        showMessage("Your session has expired, please sign in again", true);
+        return;
+    }
+    if (!response.ok) {
+        showMessage(await response.text(), true);
+        return;
+    }
+    const ack = await response.json();
+    showMessage("Bid of " + amount + ": " + ack.status, ack.status !== "success");
+});
+
+showBidder();
+watch();
+setInterval(updateCountdown, 1000);
@@ -0,0 +1,76 @@
+package replica
+
+import (
+	proto "Auction/grpc"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+)
+
+// Helper method to compute the digest of the state that the frontends replicate, the mutex must be held by the caller
+// Only what every replication manager decides the same way is included, so the times the bids arrived are left out,
+// but the ends of the bans are in, since the frontend sends them
+// The bidders are shown with show, also in the checksums, so a caller who may only see pseudonyms can't check guesses of the names
+func (replicationManager *ReplicationManager) stateDigest(show func(string) string) *proto.StateDigest {
+	auction := &proto.AuctionDigest{
+		Id:          auctionId,
+		IsOver:      replicationManager.isBiddingOver,
+		IsCancelled: replicationManager.isCancelled,
+		IsRevealing: replicationManager.isRevealing,
+	}
+	for _, bid := range replicationManager.bidHistory {
+		auction.Bids = append(auction.Bids, &proto.BidRecord{Id: show(bid.Id), Amount: bid.Amount})
+	}
+	for _, bidder := range sortedKeys(replicationManager.sealedBids) {
+		sealed := replicationManager.sealedBids[bidder]
+		auction.SealedBids = append(auction.SealedBids, &proto.SealedDigest{Id: show(bidder), Commitment: sealed.commitment, Revealed: sealed.revealed, Amount: sealed.amount})
+	}
+	for _, bidder := range sortedKeys(replicationManager.bans) {
+		auction.Bans = append(auction.Bans, &proto.BanRequest{Id: show(bidder), Auction: auctionId, Until: replicationManager.bans[bidder].UnixMilli()})
+	}
+	auction.Checksum = auctionChecksum(auction)
+
+	//The checksum of the replication manager covers the checksums of all its auctions
+	digest := sha256.New()
+	writeState(digest, "auction %q %s\n", auction.Id, auction.Checksum)
+	return &proto.StateDigest{
+		Checksum:    hex.EncodeToString(digest.Sum(nil)),
+		LastApplied: replicationManager.applied,
+		Auctions:    []*proto.AuctionDigest{auction},
+	}
+}
+
+// Helper method to compute the checksum of the state as the caller is shown it, the mutex must be held by the caller
+func (replicationManager *ReplicationManager) checksum(show func(string) string) string {
+	return replicationManager.stateDigest(show).Checksum
+}
+
+func auctionChecksum(auction *proto.AuctionDigest) string {
+	digest := sha256.New()
+	writeState(digest, "over %t cancelled %t revealing %t\n", auction.IsOver, auction.IsCancelled, auction.IsRevealing)
+	for _, bid := range auction.Bids {
+		writeState(digest, "bid %q %d\n", bid.Id, bid.Amount)
+	}
+	for _, sealed := range auction.SealedBids {
+		writeState(digest, "sealed %q %x %t %d\n", sealed.Id, sealed.Commitment, sealed.Revealed, sealed.Amount)
+	}
+	for _, ban := range auction.Bans {
+		writeState(digest, "ban %q %d\n", ban.Id, ban.Until)
+	}
+	return hex.EncodeToString(digest.Sum(nil))
+}
+
+func writeState(digest hash.Hash, format string, args ...any) {
+	fmt.Fprintf(digest, format, args...)
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
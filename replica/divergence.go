@@ -0,0 +1,165 @@
+package replica
+
+import (
+	"Auction/auth"
+	"Auction/divergence"
+	proto "Auction/grpc"
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// How long CheckDivergence waits before it checks again, so the writes that were in flight during the first check are applied
+const divergenceSettle = time.Second
+
+// The periodic comparison of the digests, it has a mutex of its own, since the checks call the other replication managers
+type divergenceChecker struct {
+	mutex    sync.Mutex
+	previous *proto.DivergenceReport
+	report   *proto.DivergenceReport
+	stop     chan struct{}
+}
+
+func (replicationManager *ReplicationManager) startDivergenceCheck() {
+	interval := replicationManager.options.divergenceCheck
+	if interval <= 0 || replicationManager.options.peers == nil {
+		return
+	}
+	checker := replicationManager.divergence
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+	checker.stop = make(chan struct{})
+	go func(stop chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				replicationManager.checkPeriodically()
+			}
+		}
+	}(checker.stop)
+}
+
+func (replicationManager *ReplicationManager) stopDivergenceCheck() {
+	checker := replicationManager.divergence
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+	if checker.stop != nil {
+		close(checker.stop)
+		checker.stop = nil
+	}
+}
+
+// Compares the digests, and reports the differences that the previous check also found
+func (replicationManager *ReplicationManager) checkPeriodically() {
+	logger := replicationManager.options.logger
+	ctx, cancel := context.WithTimeout(context.Background(), replicationManager.options.divergenceCheck)
+	defer cancel()
+	report, err := replicationManager.compareDigests(ctx)
+	if err != nil {
+		logger.Warn("Could not compare the digests of the replication managers", "err", err)
+		return
+	}
+
+	checker := replicationManager.divergence
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+	//The first check has nothing to confirm its differences
+	previous := checker.previous
+	if previous == nil {
+		previous = &proto.DivergenceReport{}
+	}
+	persistent := divergence.Persistent(previous, report)
+	//Only the new differences are logged, not the ones the last check already logged
+	logged := make(map[string]bool)
+	if checker.report != nil {
+		for _, difference := range checker.report.Differences {
+			logged[difference.Auction+" "+difference.Item] = true
+		}
+	}
+	for _, difference := range persistent.Differences {
+		if !logged[difference.Auction+" "+difference.Item] {
+			logger.Warn("Replication managers diverged", "auction", difference.Auction, "item", difference.Item, "difference", divergence.Format(difference))
+		}
+	}
+	if len(persistent.Differences) == 0 && len(logged) > 0 {
+		logger.Info("Replication managers agree again")
+	}
+	checker.previous = report
+	checker.report = persistent
+}
+
+// Asks every replication manager for its digest and compares them
+func (replicationManager *ReplicationManager) compareDigests(ctx context.Context) (*proto.DivergenceReport, error) {
+	answers, err := replicationManager.options.peers.Digests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, len(answers))
+	digests := make([]*proto.StateDigest, len(answers))
+	errs := make([]error, len(answers))
+	for i, answer := range answers {
+		addresses[i], digests[i], errs[i] = answer.Address, answer.Digest, answer.Err
+	}
+	return divergence.Check(addresses, digests, errs), nil
+}
+
+// The number of differences found by the last periodic check, for the metrics
+func (replicationManager *ReplicationManager) divergentItems() int {
+	checker := replicationManager.divergence
+	checker.mutex.Lock()
+	defer checker.mutex.Unlock()
+	if checker.report == nil {
+		return 0
+	}
+	return len(checker.report.Differences)
+}
+
+// Digest returns the digest of the state of the auction with the names of the bidders.
+// The Admin service returns it from GetDigest, with pseudonyms for callers who may not see the names.
+func (replicationManager *ReplicationManager) Digest() *proto.StateDigest {
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+	return replicationManager.stateDigest(realName)
+}
+
+func (admin *adminServer) GetDigest(ctx context.Context, empty *proto.Empty) (*proto.StateDigest, error) {
+	replicationManager := admin.replicationManager
+	show := replicationManager.viewer(ctx)
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+	return replicationManager.stateDigest(show), nil
+}
+
+// CheckDivergence compares the digests twice, divergenceSettle apart, and returns the differences that both checks found
+// The digests are asked for with the token of the caller, so the report only has the names of the bidders the caller may see
+func (admin *adminServer) CheckDivergence(ctx context.Context, empty *proto.Empty) (*proto.DivergenceReport, error) {
+	replicationManager := admin.replicationManager
+	if replicationManager.options.peers == nil {
+		return nil, status.Error(codes.FailedPrecondition, "the replication manager doesn't know the other replication managers")
+	}
+	ctx = auth.ForwardToken(ctx)
+	first, err := replicationManager.compareDigests(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	if len(first.Differences) == 0 {
+		return first, nil
+	}
+	select {
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	case <-time.After(divergenceSettle):
+	}
+	second, err := replicationManager.compareDigests(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return divergence.Persistent(first, second), nil
+}
@@ -0,0 +1,44 @@
+package replica
+
+import (
+	"Auction/auth"
+	"Auction/authz"
+	"Auction/faults"
+	proto "Auction/grpc"
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (admin *adminServer) GetFaults(ctx context.Context, empty *proto.Empty) (*proto.FaultRules, error) {
+	injector := admin.replicationManager.options.faults
+	if injector == nil {
+		return &proto.FaultRules{}, nil
+	}
+	return faults.ToProto(injector.Rules()), nil
+}
+
+// SetFaults can only be called when the configuration turned fault injection on, and with authentication only by admins,
+// whatever the policy says, since a crash rule stops the server
+func (admin *adminServer) SetFaults(ctx context.Context, rules *proto.FaultRules) (*proto.FaultRules, error) {
+	replicationManager := admin.replicationManager
+	injector := replicationManager.options.faults
+	if injector == nil {
+		return nil, status.Error(codes.FailedPrecondition, "fault injection is not enabled, add a faults section to the configuration")
+	}
+	if replicationManager.options.authenticator != nil {
+		claims := auth.ClaimsFromContext(ctx)
+		if claims == nil {
+			return nil, status.Error(codes.Unauthenticated, "a token is required, log in first")
+		}
+		if claims.Role != authz.Admin {
+			return nil, status.Errorf(codes.PermissionDenied, "only admins may inject faults, %s is a %s", claims.Subject, claims.Role)
+		}
+	}
+	if err := injector.SetRules(faults.FromProto(rules)); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	replicationManager.options.logger.WarnContext(ctx, "Changed the fault rules", "rules", len(rules.Rules))
+	return faults.ToProto(injector.Rules()), nil
+}
@@ -0,0 +1,353 @@
+// HTTP/JSON API of the replication manager
+
+package replica
+
+import (
+	"Auction/auth"
+	"Auction/frontend"
+	proto "Auction/grpc"
+	"Auction/logging"
+	"Auction/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// An HTTP route, the grpc method it serves and the proto messages it takes and returns
+// The same table is used to register the handlers and to generate the OpenAPI document
+type route struct {
+	method   string
+	path     string
+	rpc      string
+	summary  string
+	request  protobuf.Message
+	response protobuf.Message
+	handler  func(replicationManager *ReplicationManager, gateway *frontend.AuctionClient, writer http.ResponseWriter, request *http.Request)
+}
+
+var routes = []route{
+	{http.MethodPost, "/v1/login", "Auction/Login", "Log in and get a token for the Authorization header", &proto.LoginRequest{}, &proto.Token{}, handleLogin},
+	{http.MethodPost, "/v1/bids", "Auction/Bid", "Place a bid on all replication managers, with the token from /v1/login as bearer token if authentication is enabled, and signed if the servers require signed bids", &proto.BidMessage{}, &proto.Acknowledgement{}, handleBid},
+	{http.MethodPost, "/v1/commitments", "Auction/CommitBid", "Place a sealed bid on all replication managers in a sealed auction, the commitment is base64", &proto.Commitment{}, &proto.Acknowledgement{}, handleCommit},
+	{http.MethodPost, "/v1/reveals", "Auction/RevealBid", "Reveal a sealed bid on all replication managers after the bidding, the nonce is base64", &proto.Reveal{}, &proto.Acknowledgement{}, handleReveal},
+	{http.MethodGet, "/v1/bids", "Auction/GetBidHistory", "Get the history of accepted bids", nil, &proto.BidHistory{}, handleBidHistory},
+	{http.MethodGet, "/v1/result", "Auction/GetResult", "Get the highest bid, or the winner if the auction is over", nil, &proto.Outcome{}, handleResult},
+	{http.MethodGet, "/v1/account", "Auction/GetAccount", "Get the balance and holds of a bidder, with a bearer token if authentication is enabled", &proto.AccountRequest{}, &proto.Account{}, handleAccount},
+	{http.MethodGet, "/v1/auctions", "Auction/ListAuctions", "List the auctions", nil, &proto.AuctionList{}, handleListAuctions},
+	{http.MethodPost, "/v1/close", "Auction/CloseAuction", "End the auction now on all replication managers, for the seller and admins", nil, &proto.AuctionInfo{}, handleClose},
+	{http.MethodPost, "/v1/cancel", "Auction/CancelAuction", "End the auction without a winner on all replication managers, for admins", nil, &proto.AuctionInfo{}, handleCancel},
+	{http.MethodPost, "/v1/bans", "Auction/BanBidder", "Stop a bidder from bidding until the time in unix milliseconds on all replication managers, for admins", &proto.BanRequest{}, &proto.Acknowledgement{}, handleBan},
+	{http.MethodGet, "/v1/events", "Auction/Watch", "Server-sent events with a snapshot of the auction on every change", nil, &proto.AuctionInfo{}, handleEvents},
+}
+
+var jsonMarshaller = protojson.MarshalOptions{EmitUnpopulated: true}
+
+// HttpHandler serves the HTTP/JSON API and the dashboard of the replication manager.
+// Bids placed over HTTP are sent through the gateway, so they reach every replication manager
+// like a bid from the client and not only the one serving the request.
+func (replicationManager *ReplicationManager) HttpHandler(gateway *frontend.AuctionClient) http.Handler {
+	mux := http.NewServeMux()
+
+	//Group the routes by path, since GET and POST can share a path
+	handlers := make(map[string]map[string]route)
+	for _, r := range routes {
+		if handlers[r.path] == nil {
+			handlers[r.path] = make(map[string]route)
+		}
+		handlers[r.path][r.method] = r
+	}
+	for path, methods := range handlers {
+		methods := methods
+		mux.HandleFunc(path, func(writer http.ResponseWriter, request *http.Request) {
+			r, ok := methods[request.Method]
+			if !ok {
+				http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			//Like the grpc calls, the request keeps the request id of its X-Request-Id header,
+			//and continues the trace of the caller if it sent a traceparent header
+			request = request.WithContext(logging.ExtractHttp(writer, request))
+			ctx, span := replicationManager.options.tracer.Start(tracing.ExtractHttp(request), "HTTP "+r.method+" "+r.path)
+			defer span.End()
+			request = request.WithContext(ctx)
+			if err := replicationManager.limitHttp(request); err != nil {
+				writeError(writer, err)
+				return
+			}
+			//The handlers call the replication manager directly, so the token and role are checked here
+			//like the grpc interceptors check them
+			ctx, err := replicationManager.authorizeHttp(request, r.rpc)
+			if err != nil {
+				writeError(writer, err)
+				return
+			}
+			r.handler(replicationManager, gateway, writer, request.WithContext(ctx))
+		})
+	}
+	mux.HandleFunc("/v1/openapi.json", handleOpenApi)
+	replicationManager.registerPeerMetrics(gateway)
+	mux.Handle("/metrics", replicationManager.Metrics().Handler())
+	mux.Handle("/", dashboardHandler())
+	return mux
+}
+
+func handleLogin(replicationManager *ReplicationManager, gateway *frontend.AuctionClient, writer http.ResponseWriter, request *http.Request) {
+	loginRequest := &proto.LoginRequest{}
+	if !readMessage(writer, request, loginRequest) {
+		return
+	}
+	token, err := replicationManager.Login(request.Context(), loginRequest)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeMessage(writer, token)
+}
+
+func handleBid(replicationManager *ReplicationManager, gateway *frontend.AuctionClient, writer http.ResponseWriter, request *http.Request) {
+	bidMessage := &proto.BidMessage{}
+	if !readMessage(writer, request, bidMessage) {
+		return
+	}
+
+	//The message is sent as it is, so a bid signed by the bidder keeps its signature
+	ack, err := gateway.PlaceBid(forwardToken(request), bidMessage)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeMessage(writer, ack)
+}
+
+func handleCommit(replicationManager *ReplicationManager, gateway *frontend.AuctionClient, writer http.ResponseWriter, request *http.Request) {
+	commitment := &proto.Commitment{}
+	if !readMessage(writer, request, commitment) {
+		return
+	}
+	ack, err := gateway.Commit(forwardToken(request), commitment.Id, commitment.Commitment)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeMessage(writer, ack)
+}
+
+func handleReveal(replicationManager *ReplicationManager, gateway *frontend.AuctionClient, writer http.ResponseWriter, request *http.Request) {
+	reveal := &proto.Reveal{}
+	if !readMessage(writer, request, reveal) {
+		return
+	}
+	ack, err := gateway.Reveal(forwardToken(request), reveal.Id, reveal.Amount, reveal.Nonce)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeMessage(writer, ack)
+}
+
+func handleClose(replicationManager *ReplicationManager, gateway *frontend.AuctionClient, writer http.ResponseWriter, request *http.Request) {
+	info, err := gateway.CloseAuction(forwardToken(request))
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeMessage(writer, info)
+}
+
+func handleCancel(replicationManager *ReplicationManager, gateway *frontend.AuctionClient, writer http.ResponseWriter, request *http.Request) {
+	info, err := gateway.CancelAuction(forwardToken(request))
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeMessage(writer, info)
+}
+
+func handleBan(replicationManager *ReplicationManager, gateway *frontend.AuctionClient, writer http.ResponseWriter, request *http.Request) {
+	banRequest := &proto.BanRequest{}
+	if !readMessage(writer, request, banRequest) {
+		return
+	}
+	if banRequest.Auction != "" && banRequest.Auction != auctionId {
+		writeError(writer, status.Errorf(codes.NotFound, "there is no auction %s", banRequest.Auction))
+		return
+	}
+
+	//The gateway sends the end of the ban again, a time in the past lifts the ban
+	//The time left is measured on the clock of the auction, which also decides when the ban is over
+	var duration time.Duration
+	if banRequest.Until != 0 {
+		duration = time.UnixMilli(banRequest.Until).Sub(replicationManager.options.clock.Now())
+	}
+	ack, err := gateway.Ban(forwardToken(request), banRequest.Id, duration)
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeMessage(writer, ack)
+}
+
+// Returns the context of the request with its token, so the gateway passes it on to the replication managers
+func forwardToken(request *http.Request) context.Context {
+	ctx := request.Context()
+	if token := auth.TokenFromHeader(request.Header.Get("Authorization")); token != "" {
+		ctx = auth.WithToken(ctx, token)
+	}
+	return ctx
+}
+
+func handleBidHistory(replicationManager *ReplicationManager, gateway *frontend.AuctionClient, writer http.ResponseWriter, request *http.Request) {
+	history, err := replicationManager.GetBidHistory(request.Context(), &proto.Empty{})
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeMessage(writer, history)
+}
+
+func handleResult(replicationManager *ReplicationManager, gateway *frontend.AuctionClient, writer http.ResponseWriter, request *http.Request) {
+	outcome, err := replicationManager.GetResult(request.Context(), &proto.Empty{})
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeMessage(writer, outcome)
+}
+
+func handleAccount(replicationManager *ReplicationManager, gateway *frontend.AuctionClient, writer http.ResponseWriter, request *http.Request) {
+	account, err := replicationManager.GetAccount(request.Context(), &proto.AccountRequest{Id: request.URL.Query().Get("id")})
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeMessage(writer, account)
+}
+
+func handleListAuctions(replicationManager *ReplicationManager, gateway *frontend.AuctionClient, writer http.ResponseWriter, request *http.Request) {
+	auctions, err := replicationManager.ListAuctions(request.Context(), &proto.Empty{})
+	if err != nil {
+		writeError(writer, err)
+		return
+	}
+	writeMessage(writer, auctions)
+}
+
+func handleEvents(replicationManager *ReplicationManager, gateway *frontend.AuctionClient, writer http.ResponseWriter, request *http.Request) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		http.Error(writer, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+
+	updates, cancel := replicationManager.subscribe()
+	defer cancel()
+
+	show := replicationManager.viewer(request.Context())
+	for {
+		select {
+		case info := <-updates:
+			data, _ := jsonMarshaller.Marshal(showAuction(show, info))
+			fmt.Fprintf(writer, "event: auction\ndata: %s\n\n", data)
+			flusher.Flush()
+		case <-request.Context().Done():
+			return
+		}
+	}
+}
+
+// Verifies the bearer token of the request, if it has one, and checks that its role may call the grpc method
+// Returns a context with the claims of the token like the grpc interceptors do
+func (replicationManager *ReplicationManager) authorizeHttp(request *http.Request, rpc string) (context.Context, error) {
+	authenticator := replicationManager.options.authenticator
+	if authenticator == nil {
+		return request.Context(), nil
+	}
+	ctx := request.Context()
+	if token := auth.TokenFromHeader(request.Header.Get("Authorization")); token != "" {
+		claims, err := authenticator.Verify(token)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		ctx = auth.ContextWithClaims(ctx, claims)
+	}
+	if err := replicationManager.policy().Check(ctx, rpc); err != nil {
+		return nil, err
+	}
+	return ctx, nil
+}
+
+// Limits the requests from every address like the grpc interceptor limits the calls of every connection
+// Bids over HTTP are sent through the gateway, so the limits of the bidders are checked by the gateway and when the bids are applied
+func (replicationManager *ReplicationManager) limitHttp(request *http.Request) error {
+	limits := replicationManager.options.rateLimits
+	if limits == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+	return limits.CheckConnection(host)
+}
+
+// Reads the JSON body into the message, or writes an error and returns false
+func readMessage(writer http.ResponseWriter, request *http.Request, message protobuf.Message) bool {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if err := protojson.Unmarshal(body, message); err != nil {
+		http.Error(writer, fmt.Sprintf("invalid %s: %v", message.ProtoReflect().Descriptor().Name(), err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// Writes a grpc error with the matching HTTP status
+func writeError(writer http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		code = http.StatusBadRequest
+	case codes.Unauthenticated:
+		code = http.StatusUnauthorized
+	case codes.PermissionDenied:
+		code = http.StatusForbidden
+	case codes.NotFound:
+		code = http.StatusNotFound
+	case codes.ResourceExhausted:
+		code = http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		code = http.StatusConflict
+	case codes.Unimplemented:
+		code = http.StatusNotImplemented
+	case codes.Unavailable:
+		code = http.StatusServiceUnavailable
+	}
+	if errors.Is(err, frontend.ErrNoReplica) {
+		code = http.StatusServiceUnavailable
+	}
+	http.Error(writer, status.Convert(err).Message(), code)
+}
+
+func writeMessage(writer http.ResponseWriter, message protobuf.Message) {
+	data, err := jsonMarshaller.Marshal(message)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Write(data)
+}
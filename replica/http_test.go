@@ -0,0 +1,78 @@
+package replica_test
+
+import (
+	"Auction/auth"
+	"Auction/authz"
+	"Auction/config"
+	"Auction/harness"
+	"Auction/replica"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A ban over HTTP ends at the time of the request on the clock of the auction, not on the clock of the machine
+func TestHttpBanUsesTheClockOfTheAuction(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "auth.key")
+	if err := os.WriteFile(keyFile, []byte(strings.Repeat("k", 32)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := auth.New(config.Auth{Algorithm: "HS256", KeyFile: keyFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminToken, _, err := authenticator.Issue("root", authz.Admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bidderToken, _, err := authenticator.Issue("casper", authz.Bidder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//The auction runs a year in the past, so a ban until shortly after its clock is over on the clock of the machine
+	start := time.Now().AddDate(-1, 0, 0)
+	cluster, err := harness.New(harness.WithReplicas(1), harness.WithStart(start), harness.WithReplicaOptions(replica.WithAuthenticator(authenticator)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cluster.Close()
+	server := httptest.NewServer(cluster.Replica(0).HttpHandler(cluster.Frontend(0)))
+	defer server.Close()
+
+	until := start.Add(10 * time.Second).UnixMilli()
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/v1/bans", strings.NewReader(fmt.Sprintf(`{"id": "casper", "until": "%d"}`, until)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header.Set("Authorization", "Bearer "+adminToken)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("the ban answered %s", response.Status)
+	}
+
+	ctx, cancel := context.WithTimeout(auth.WithToken(context.Background(), bidderToken), 10*time.Second)
+	defer cancel()
+	ack, err := cluster.Frontend(0).Bid(ctx, "casper", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(ack.Status, "fail - banned") {
+		t.Fatalf("expected casper to be banned, the bid answered %q", ack.Status)
+	}
+	//The bidding lasts a minute, so it isn't over yet when the ban is
+	cluster.Advance(20 * time.Second)
+	if ack, err = cluster.Frontend(0).Bid(ctx, "casper", 10); err != nil || ack.Status != "success" {
+		t.Fatalf("expected the ban to be over, the bid answered %v and %v", ack, err)
+	}
+}
@@ -0,0 +1,89 @@
+package replica
+
+import (
+	"Auction/config"
+	proto "Auction/grpc"
+)
+
+// The ledger keeps the balance of every bidder and the amounts held for their highest bids.
+// Every replication manager applies the same bids in the same order, so their ledgers stay the same.
+// The methods must be called with the mutex of the replication manager held.
+type ledger struct {
+	accounts config.Accounts
+	// What each bidder has spent on won auctions
+	spent map[string]int32
+	// The amount held for each bidder, per auction where they have the highest bid
+	holds map[string]map[string]int32
+}
+
+func newLedger(accounts config.Accounts) *ledger {
+	return &ledger{
+		accounts: accounts,
+		spent:    make(map[string]int32),
+		holds:    make(map[string]map[string]int32),
+	}
+}
+
+func (ledger *ledger) balance(bidder string) int32 {
+	balance, ok := ledger.accounts.Balances[bidder]
+	if !ok {
+		balance = ledger.accounts.DefaultBalance
+	}
+	return balance - ledger.spent[bidder]
+}
+
+func (ledger *ledger) held(bidder string) int32 {
+	var held int32
+	for _, amount := range ledger.holds[bidder] {
+		held += amount
+	}
+	return held
+}
+
+// Tells whether the bidder can afford the bid. A hold the bidder already has in the same auction
+// doesn't count, since the new bid replaces it.
+func (ledger *ledger) canAfford(bidder string, auction string, amount int32) bool {
+	available := ledger.balance(bidder) - ledger.held(bidder) + ledger.holds[bidder][auction]
+	return amount <= available
+}
+
+// Holds the amount of the new highest bid, and releases the hold of the bidder it outbid
+func (ledger *ledger) hold(bidder string, auction string, amount int32, outbid string) {
+	if outbid != "" {
+		delete(ledger.holds[outbid], auction)
+	}
+	if ledger.holds[bidder] == nil {
+		ledger.holds[bidder] = make(map[string]int32)
+	}
+	ledger.holds[bidder][auction] = amount
+}
+
+// Turns the hold of the winner of a closed auction into spent money
+func (ledger *ledger) capture(winner string, auction string) {
+	amount, ok := ledger.holds[winner][auction]
+	if !ok {
+		return
+	}
+	delete(ledger.holds[winner], auction)
+	ledger.spent[winner] += amount
+}
+
+// Charges a fine to the bidder, at most their available funds, and returns what was charged
+func (ledger *ledger) charge(bidder string, fine int32) int32 {
+	fine = min(fine, max(ledger.balance(bidder)-ledger.held(bidder), 0))
+	ledger.spent[bidder] += fine
+	return fine
+}
+
+// Releases the holds of all bidders in a cancelled auction
+func (ledger *ledger) release(auction string) {
+	for _, holds := range ledger.holds {
+		delete(holds, auction)
+	}
+}
+
+func (ledger *ledger) account(bidder string) *proto.Account {
+	balance := ledger.balance(bidder)
+	held := ledger.held(bidder)
+	return &proto.Account{Id: bidder, Balance: balance, Held: held, Available: balance - held}
+}
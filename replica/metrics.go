@@ -0,0 +1,157 @@
+package replica
+
+import (
+	"Auction/frontend"
+	proto "Auction/grpc"
+	"Auction/metrics"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// The metrics of a replication manager
+type replicaMetrics struct {
+	bidsAccepted *metrics.Counter
+	bidsRejected *metrics.Counter
+	rpcDuration  *metrics.Histogram
+}
+
+func newReplicaMetrics(replicationManager *ReplicationManager, registry *metrics.Registry) *replicaMetrics {
+	registry.GaugeFunc("auction_highest_bid", "The current highest bid of the auction.", []string{"auction"},
+		func(set func(float64, ...string)) {
+			replicationManager.mutex.Lock()
+			defer replicationManager.mutex.Unlock()
+			_, highestBid := replicationManager.getHighestBid()
+			set(float64(highestBid), auctionId)
+		})
+	registry.GaugeFunc("auction_time_remaining_seconds", "The time left of the bidding, or of the reveal window of a sealed auction, 0 when the auction hasn't started or is over.", []string{"auction"},
+		func(set func(float64, ...string)) {
+			replicationManager.mutex.Lock()
+			defer replicationManager.mutex.Unlock()
+			set(replicationManager.timeRemaining().Seconds(), auctionId)
+		})
+	registry.GaugeFunc("auction_bids_applied", "The number of accepted bids in the history, which is the same on replication managers that are in sync.", []string{"auction"},
+		func(set func(float64, ...string)) {
+			replicationManager.mutex.Lock()
+			defer replicationManager.mutex.Unlock()
+			set(float64(len(replicationManager.bidHistory)), auctionId)
+		})
+	registry.GaugeFunc("auction_watchers", "The number of open Watch streams and server-sent event streams.", nil,
+		func(set func(float64, ...string)) {
+			replicationManager.mutex.Lock()
+			defer replicationManager.mutex.Unlock()
+			set(float64(len(replicationManager.watchers)))
+		})
+	if replicationManager.options.divergenceCheck > 0 {
+		registry.GaugeFunc("auction_divergent_items", "The items of the auctions that differed between the replication managers in the last two divergence checks.", nil,
+			func(set func(float64, ...string)) {
+				set(float64(replicationManager.divergentItems()))
+			})
+	}
+	return &replicaMetrics{
+		bidsAccepted: registry.Counter("auction_bids_accepted_total", "Accepted bids, commitments and reveals.", "auction"),
+		bidsRejected: registry.Counter("auction_bids_rejected_total", "Rejected bids, commitments and reveals by the reason they were rejected.", "auction", "reason"),
+		rpcDuration:  registry.Histogram("auction_rpc_duration_seconds", "The time it takes to answer a grpc call.", metrics.DefaultBuckets, "method", "code"),
+	}
+}
+
+// Registers the health of the other replication managers and how far they are behind this one,
+// which are found through the gateway every time the metrics are read
+func (replicationManager *ReplicationManager) registerPeerMetrics(gateway *frontend.AuctionClient) {
+	registry := replicationManager.Metrics()
+	var mutex sync.Mutex
+	var statuses []frontend.ReplicaStatus
+	//Both metrics are collected by one probe, which is only repeated when the metrics are read again
+	var probedAt time.Time
+	probe := func() []frontend.ReplicaStatus {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if time.Since(probedAt) > time.Second {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			statuses, _ = gateway.Probe(ctx)
+			cancel()
+			probedAt = time.Now()
+		}
+		return statuses
+	}
+
+	registry.GaugeFunc("auction_peer_up", "1 if the replication manager answers, 0 if it doesn't.", []string{"peer"},
+		func(set func(float64, ...string)) {
+			for _, peer := range probe() {
+				up := 0.0
+				if peer.Up {
+					up = 1
+				}
+				set(up, peer.Address)
+			}
+		})
+	registry.GaugeFunc("auction_replication_lag_bids", "How many accepted bids the replication manager is behind this one, negative if it is ahead.", []string{"peer"},
+		func(set func(float64, ...string)) {
+			peers := probe()
+			replicationManager.mutex.Lock()
+			applied := len(replicationManager.bidHistory)
+			replicationManager.mutex.Unlock()
+			for _, peer := range peers {
+				if peer.Err == nil {
+					set(float64(applied-peer.Bids), peer.Address)
+				}
+			}
+		})
+}
+
+// The time left of the current phase, the mutex must be held by the caller
+func (replicationManager *ReplicationManager) timeRemaining() time.Duration {
+	end := replicationManager.endTime
+	if replicationManager.isRevealing {
+		end = replicationManager.revealEndTime
+	}
+	if end.IsZero() || replicationManager.isBiddingOver {
+		return 0
+	}
+	return max(end.Sub(replicationManager.options.clock.Now()), 0)
+}
+
+// Measures every call, and counts the answers to bids by their result
+// It comes first, so calls rejected by the other interceptors are counted as well
+func (replicaMetrics *replicaMetrics) unaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		response, err := handler(ctx, request)
+		method := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+		replicaMetrics.rpcDuration.Observe(time.Since(start).Seconds(), method, status.Code(err).String())
+
+		switch request.(type) {
+		case *proto.BidMessage, *proto.Commitment, *proto.Reveal:
+			replicaMetrics.countBid(response, err)
+		}
+		return response, err
+	}
+}
+
+func (replicaMetrics *replicaMetrics) countBid(response any, err error) {
+	if err != nil {
+		replicaMetrics.bidsRejected.Inc(auctionId, status.Code(err).String())
+		return
+	}
+	ack, ok := response.(*proto.Acknowledgement)
+	if !ok {
+		return
+	}
+	if ack.Status == "success" {
+		replicaMetrics.bidsAccepted.Inc(auctionId)
+		return
+	}
+	replicaMetrics.bidsRejected.Inc(auctionId, rejectionReason(ack.Status))
+}
+
+// Turns "fail - banned until 12:00:00" into "banned", so the reasons don't contain times
+func rejectionReason(ackStatus string) string {
+	reason := strings.TrimPrefix(ackStatus, "fail - ")
+	reason, _, _ = strings.Cut(reason, " until ")
+	reason, _, _ = strings.Cut(reason, ",")
+	return reason
+}
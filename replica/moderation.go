@@ -0,0 +1,111 @@
+package replica
+
+import (
+	"Auction/audit"
+	"Auction/auth"
+	"Auction/authz"
+	"Auction/config"
+	proto "Auction/grpc"
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (replicationManager *ReplicationManager) CloseAuction(ctx context.Context, empty *proto.Empty) (*proto.AuctionInfo, error) {
+	if err := replicationManager.checkModerator(ctx, true); err != nil {
+		return nil, err
+	}
+
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+	replicationManager.applied++
+
+	if replicationManager.isBiddingOver {
+		return nil, status.Error(codes.FailedPrecondition, "the auction is already over")
+	}
+	//An auction without bids can also be closed, it then ends without a winner
+	if replicationManager.endTime.IsZero() {
+		replicationManager.endTime = replicationManager.options.clock.Now()
+	}
+	//A sealed auction goes on to the reveal window, and closing it again ends the reveal window
+	replicationManager.endPhase(ctx)
+	return replicationManager.auctionInfo(), nil
+}
+
+func (replicationManager *ReplicationManager) CancelAuction(ctx context.Context, empty *proto.Empty) (*proto.AuctionInfo, error) {
+	if err := replicationManager.checkModerator(ctx, false); err != nil {
+		return nil, err
+	}
+
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+	replicationManager.applied++
+
+	if replicationManager.isBiddingOver {
+		return nil, status.Error(codes.FailedPrecondition, "the auction is already over")
+	}
+	if replicationManager.endTime.IsZero() {
+		replicationManager.endTime = replicationManager.options.clock.Now()
+	}
+	//Nobody wins a cancelled auction, so the holds are released instead of paid
+	replicationManager.isCancelled = true
+	replicationManager.isRevealing = false
+	if replicationManager.ledger != nil {
+		replicationManager.ledger.release(auctionId)
+	}
+	replicationManager.closeBidding(ctx)
+	return replicationManager.auctionInfo(), nil
+}
+
+func (replicationManager *ReplicationManager) BanBidder(ctx context.Context, banRequest *proto.BanRequest) (*proto.Acknowledgement, error) {
+	if err := replicationManager.checkModerator(ctx, false); err != nil {
+		return nil, err
+	}
+	if banRequest.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "the id of the bidder is required")
+	}
+	if banRequest.Auction != "" && banRequest.Auction != auctionId {
+		return nil, status.Errorf(codes.NotFound, "there is no auction %s", banRequest.Auction)
+	}
+
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+	replicationManager.applied++
+
+	//The frontend decides when the ban ends, so it ends at the same time on every replication manager
+	if banRequest.Until == 0 {
+		delete(replicationManager.bans, banRequest.Id)
+		replicationManager.record(ctx, audit.Entry{Event: audit.Unbanned, Auction: auctionId, Bidder: banRequest.Id})
+		return &proto.Acknowledgement{Status: "success"}, nil
+	}
+	replicationManager.bans[banRequest.Id] = time.UnixMilli(banRequest.Until)
+	replicationManager.record(ctx, audit.Entry{Event: audit.Banned, Auction: auctionId, Bidder: banRequest.Id, Detail: "until " + time.UnixMilli(banRequest.Until).Format(time.RFC3339)})
+	return &proto.Acknowledgement{Status: "success"}, nil
+}
+
+// Closing, cancelling and banning needs authentication, since without it anybody could claim to be the seller
+// The role is checked by the policy, this checks that a seller is the seller of this auction
+func (replicationManager *ReplicationManager) checkModerator(ctx context.Context, sellerMayCall bool) error {
+	if replicationManager.options.authenticator == nil {
+		return status.Error(codes.FailedPrecondition, "moderating the auction needs authentication to be enabled")
+	}
+	claims := auth.ClaimsFromContext(ctx)
+	if claims == nil {
+		return status.Error(codes.Unauthenticated, "a token is required, log in first")
+	}
+	if claims.Role == authz.Seller && (!sellerMayCall || claims.Subject != replicationManager.options.seller) {
+		return status.Errorf(codes.PermissionDenied, "%s is not the seller of the auction", claims.Subject)
+	}
+	return nil
+}
+
+// The policy of the roles, the default policy if none is configured
+func (replicationManager *ReplicationManager) policy() *authz.Policy {
+	if replicationManager.options.policy != nil {
+		return replicationManager.options.policy
+	}
+	policy, _ := authz.NewPolicy(config.Authorization{})
+	return policy
+}
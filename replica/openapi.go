@@ -0,0 +1,134 @@
+// OpenAPI document for the HTTP API, generated from the proto definitions
+
+package replica
+
+import (
+	proto "Auction/grpc"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func handleOpenApi(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(openApiDocument())
+}
+
+// Builds the OpenAPI 3 document from the routes and the message descriptors of the proto file
+func openApiDocument() map[string]any {
+	schemas := make(map[string]any)
+	messages := proto.File_grpc_proto_proto.Messages()
+	for i := 0; i < messages.Len(); i++ {
+		message := messages.Get(i)
+		schemas[string(message.Name())] = messageSchema(message)
+	}
+
+	paths := make(map[string]map[string]any)
+	for _, r := range routes {
+		if paths[r.path] == nil {
+			paths[r.path] = make(map[string]any)
+		}
+		contentType := "application/json"
+		if r.path == "/v1/events" {
+			contentType = "text/event-stream"
+		}
+		operation := map[string]any{
+			"summary": r.summary,
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "OK",
+					"content": map[string]any{
+						contentType: map[string]any{"schema": schemaRef(r.response.ProtoReflect().Descriptor())},
+					},
+				},
+			},
+		}
+		if r.request != nil && r.method == http.MethodGet {
+			//A GET request has the fields of its message as query parameters
+			operation["parameters"] = queryParameters(r.request.ProtoReflect().Descriptor())
+		} else if r.request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": schemaRef(r.request.ProtoReflect().Descriptor())},
+				},
+			}
+		}
+		paths[r.path][strings.ToLower(r.method)] = operation
+	}
+
+	return map[string]any{
+		"openapi":    "3.0.3",
+		"info":       map[string]any{"title": "Auction", "version": "1"},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}
+}
+
+func queryParameters(message protoreflect.MessageDescriptor) []any {
+	var parameters []any
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		parameters = append(parameters, map[string]any{
+			"name":   field.JSONName(),
+			"in":     "query",
+			"schema": fieldSchema(field),
+		})
+	}
+	return parameters
+}
+
+func schemaRef(message protoreflect.MessageDescriptor) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + string(message.Name())}
+}
+
+// Maps a message to a JSON schema the way protojson encodes it
+func messageSchema(message protoreflect.MessageDescriptor) map[string]any {
+	properties := make(map[string]any)
+	fields := message.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		schema := fieldSchema(field)
+		if field.IsList() {
+			schema = map[string]any{"type": "array", "items": schema}
+		}
+		properties[field.JSONName()] = schema
+	}
+	return map[string]any{"type": "object", "properties": properties}
+}
+
+func fieldSchema(field protoreflect.FieldDescriptor) map[string]any {
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return map[string]any{"type": "string"}
+	case protoreflect.BoolKind:
+		return map[string]any{"type": "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return map[string]any{"type": "integer", "format": "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return map[string]any{"type": "integer", "format": "int64", "minimum": 0}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		//protojson writes 64 bit integers as strings
+		return map[string]any{"type": "string", "format": "int64"}
+	case protoreflect.FloatKind:
+		return map[string]any{"type": "number", "format": "float"}
+	case protoreflect.DoubleKind:
+		return map[string]any{"type": "number", "format": "double"}
+	case protoreflect.BytesKind:
+		return map[string]any{"type": "string", "format": "byte"}
+	case protoreflect.EnumKind:
+		values := field.Enum().Values()
+		names := make([]string, values.Len())
+		for i := range names {
+			names[i] = string(values.Get(i).Name())
+		}
+		return map[string]any{"type": "string", "enum": names}
+	case protoreflect.MessageKind:
+		return schemaRef(field.Message())
+	}
+	return map[string]any{}
+}
@@ -0,0 +1,224 @@
+package replica
+
+import (
+	"Auction/audit"
+	"Auction/auth"
+	"Auction/authz"
+	"Auction/clock"
+	"Auction/config"
+	"Auction/faults"
+	"Auction/frontend"
+	"Auction/logging"
+	"Auction/metrics"
+	"Auction/ratelimit"
+	"Auction/tracing"
+	"crypto/ed25519"
+	"crypto/tls"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+type options struct {
+	biddingDuration time.Duration
+	serverOptions   []grpc.ServerOption
+	authenticator   *auth.Authenticator
+	tlsConfig       *tls.Config
+	accounts        *config.Accounts
+	policy          *authz.Policy
+	seller          string
+	rateLimits      *ratelimit.Limits
+	receiptKey      ed25519.PrivateKey
+	bidderKeys      map[string]ed25519.PublicKey
+	auditLog        *audit.Log
+	sealed          *config.Sealed
+	pseudonymKey    []byte
+	metrics         *metrics.Registry
+	tracer          *tracing.Tracer
+	logger          *slog.Logger
+	id              string
+	address         string
+	peers           *frontend.AuctionClient
+	divergenceCheck time.Duration
+	clock           clock.Clock
+	faults          *faults.Injector
+}
+
+func defaultOptions() options {
+	return options{
+		biddingDuration: 60 * time.Second,
+		logger:          logging.Discard(),
+		clock:           clock.Real,
+	}
+}
+
+// Option configures a ReplicationManager.
+type Option func(*options)
+
+// WithBiddingDuration sets how long the auction runs after the first bid, the default is 60 seconds.
+func WithBiddingDuration(duration time.Duration) Option {
+	return func(o *options) {
+		o.biddingDuration = duration
+	}
+}
+
+// WithServerOptions adds options to the grpc server started by Start.
+func WithServerOptions(serverOptions ...grpc.ServerOption) Option {
+	return func(o *options) {
+		o.serverOptions = append(o.serverOptions, serverOptions...)
+	}
+}
+
+// WithAuthenticator turns on authentication. Bids then need a token from the Login RPC,
+// and the bidder is the subject of the token instead of the id in the BidMessage.
+// The roles in the tokens are checked against the policy from WithPolicy, or the default policy of the authz package.
+func WithAuthenticator(authenticator *auth.Authenticator) Option {
+	return func(o *options) {
+		o.authenticator = authenticator
+	}
+}
+
+// WithTls serves the grpc server over TLS. Services other than Auction can then only be called by members
+// of the cluster, see the tlsconfig package.
+func WithTls(tlsConfig *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = tlsConfig
+	}
+}
+
+// WithAccounts gives the bidders a balance. A bid above the available funds of the bidder is rejected,
+// and the amount of the highest bid is held until the bidder is outbid or wins the auction.
+func WithAccounts(accounts config.Accounts) Option {
+	return func(o *options) {
+		o.accounts = &accounts
+	}
+}
+
+// WithPolicy sets which roles may call which methods, it is only used together with WithAuthenticator.
+func WithPolicy(policy *authz.Policy) Option {
+	return func(o *options) {
+		o.policy = policy
+	}
+}
+
+// WithSeller sets the user who may close the auction besides the admins.
+func WithSeller(seller string) Option {
+	return func(o *options) {
+		o.seller = seller
+	}
+}
+
+// WithRateLimits limits how fast every bidder may bid and how fast every connection may call the server.
+// Callers above the limits get a RESOURCE_EXHAUSTED error.
+func WithRateLimits(rateLimits config.RateLimits) Option {
+	return func(o *options) {
+		o.rateLimits = ratelimit.New(rateLimits)
+	}
+}
+
+// WithReceiptKey signs a receipt for every answer to a bid with the Ed25519 key.
+func WithReceiptKey(privateKey ed25519.PrivateKey) Option {
+	return func(o *options) {
+		o.receiptKey = privateKey
+	}
+}
+
+// WithBidderKeys requires every bid to be signed by the Ed25519 key of its bidder.
+// Bidders without a key in the map can't bid.
+func WithBidderKeys(bidderKeys map[string]ed25519.PublicKey) Option {
+	return func(o *options) {
+		o.bidderKeys = bidderKeys
+	}
+}
+
+// WithAuditLog appends every state change of the auction to the audit log. The caller closes the log after Stop.
+func WithAuditLog(auditLog *audit.Log) Option {
+	return func(o *options) {
+		o.auditLog = auditLog
+	}
+}
+
+// WithSealedBidding turns on sealed bidding. Bids are then committed with CommitBid during the bidding
+// and revealed with RevealBid in the reveal window after it, and the highest revealed bid wins.
+// The penalty must be checked with sealed.CheckPenalty first.
+func WithSealedBidding(sealed config.Sealed) Option {
+	return func(o *options) {
+		o.sealed = &sealed
+	}
+}
+
+// WithPseudonyms shows pseudonyms instead of the names of the bidders in results, streams and the history,
+// except to the bidder themselves, the seller and admins. The pseudonyms are keyed hashes with the secret,
+// so every replication manager with the same secret shows the same pseudonyms.
+func WithPseudonyms(secret []byte) Option {
+	return func(o *options) {
+		o.pseudonymKey = secret
+	}
+}
+
+// WithMetrics registers the metrics of the replication manager in the registry, fx to share it with a frontend.
+// Without it the replication manager has a registry of its own, see Metrics.
+func WithMetrics(registry *metrics.Registry) Option {
+	return func(o *options) {
+		o.metrics = registry
+	}
+}
+
+// WithTracer records a span for every grpc call, which continues the trace sent by the frontend,
+// and spans for applying bids and ending the phases of the auction.
+func WithTracer(tracer *tracing.Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// WithLogger logs every state change of the auction, with the request id of the request that caused it,
+// and every call at the debug level. The default is to log nothing.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithIdentity sets the id of the replication manager and the address the frontends reach it at,
+// which the Admin service reports.
+func WithIdentity(id, address string) Option {
+	return func(o *options) {
+		o.id = id
+		o.address = address
+	}
+}
+
+// WithPeers lets the replication manager see the other replication managers through a frontend, fx the gateway
+// of the HTTP API. The Admin service then reports the members of the cluster and whether this one is the leader.
+func WithPeers(peers *frontend.AuctionClient) Option {
+	return func(o *options) {
+		o.peers = peers
+	}
+}
+
+// WithDivergenceCheck compares the digest of the state of every replication manager at the interval, which needs WithPeers.
+// The differences that are found by two checks in a row are logged and counted in the auction_divergent_items metric,
+// and the CheckDivergence RPC of the Admin service returns them.
+func WithDivergenceCheck(interval time.Duration) Option {
+	return func(o *options) {
+		o.divergenceCheck = interval
+	}
+}
+
+// WithClock sets the clock of the auction, which decides when the bidding and the reveal window end and when bans are over.
+// The default is the clock of the machine, a clock.Manual lets the time be moved forward by hand.
+func WithClock(auctionClock clock.Clock) Option {
+	return func(o *options) {
+		o.clock = auctionClock
+	}
+}
+
+// WithFaults injects the faults of the injector into the calls of the grpc server, for chaos testing.
+// Admins can then change the rules with the SetFaults RPC of the Admin service.
+func WithFaults(injector *faults.Injector) Option {
+	return func(o *options) {
+		o.faults = injector
+	}
+}
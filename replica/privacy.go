@@ -0,0 +1,51 @@
+package replica
+
+import (
+	"Auction/auth"
+	"Auction/authz"
+	proto "Auction/grpc"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+
+	protobuf "google.golang.org/protobuf/proto"
+)
+
+// The pseudonym of a bidder in an auction, the same on every replication manager with the same secret
+// It is a keyed hash, so nobody without the secret can tell which bidder it belongs to
+func (replicationManager *ReplicationManager) pseudonym(bidder string) string {
+	mac := hmac.New(sha256.New, replicationManager.options.pseudonymKey)
+	fmt.Fprintf(mac, "%s\x00%s", auctionId, bidder)
+	return fmt.Sprintf("bidder-%x", mac.Sum(nil)[:4])
+}
+
+// Returns a function that shows a bidder to the caller: the bidder themselves, the seller and admins
+// see the real name, everybody else the pseudonym. Without pseudonyms everybody sees the real names
+func (replicationManager *ReplicationManager) viewer(ctx context.Context) func(string) string {
+	if replicationManager.options.pseudonymKey == nil {
+		return realName
+	}
+	claims := auth.ClaimsFromContext(ctx)
+	if claims != nil && (claims.Role == authz.Admin || (claims.Role == authz.Seller && claims.Subject == replicationManager.options.seller)) {
+		return realName
+	}
+	return func(bidder string) string {
+		if bidder == "" || (claims != nil && bidder == claims.Subject) {
+			return bidder
+		}
+		return replicationManager.pseudonym(bidder)
+	}
+}
+
+// Shows the bidder as they are, to a caller who may see the names
+func realName(bidder string) string {
+	return bidder
+}
+
+// Shows the winner of the snapshot to the caller, the snapshot is copied since it is shared with other watchers
+func showAuction(show func(string) string, info *proto.AuctionInfo) *proto.AuctionInfo {
+	info = protobuf.Clone(info).(*proto.AuctionInfo)
+	info.Winner = show(info.Winner)
+	return info
+}
@@ -0,0 +1,64 @@
+package replica
+
+import (
+	proto "Auction/grpc"
+	"Auction/signing"
+	"context"
+	"crypto/ed25519"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Helper method to check the signature of a bid against the registered key of the bidder
+// Without registered keys, bids don't have to be signed
+func (replicationManager *ReplicationManager) verifySignature(bidder string, bidMessage *proto.BidMessage) error {
+	bidderKeys := replicationManager.options.bidderKeys
+	if bidderKeys == nil {
+		return nil
+	}
+	publicKey, ok := bidderKeys[bidder]
+	if !ok {
+		return status.Errorf(codes.PermissionDenied, "%s has no registered public key", bidder)
+	}
+	if bidMessage.Signature == nil {
+		return status.Error(codes.Unauthenticated, "the bid must be signed")
+	}
+	if err := signing.VerifyBid(publicKey, bidder, bidMessage); err != nil {
+		return status.Errorf(codes.Unauthenticated, "the bid of %s: %v", bidder, err)
+	}
+	return nil
+}
+
+// Helper method to sign a receipt for the answer to a bid, the mutex must be held by the caller
+// The sequence numbers count the receipts of this replication manager, so a missing receipt can be noticed
+func (replicationManager *ReplicationManager) receipt(key ed25519.PrivateKey, bidder string, bidMessage *proto.BidMessage, bidStatus string) *proto.Receipt {
+	replicationManager.receipts++
+	receipt := &proto.Receipt{
+		Sequence:     replicationManager.receipts,
+		Timestamp:    replicationManager.options.clock.Now().UnixMilli(),
+		Status:       bidStatus,
+		Auction:      auctionId,
+		Bidder:       bidder,
+		Amount:       bidMessage.Amount,
+		BidTimestamp: bidMessage.Timestamp,
+		BidSignature: bidMessage.Signature,
+	}
+	signing.SignReceipt(key, receipt)
+	return receipt
+}
+
+func (replicationManager *ReplicationManager) GetSignedResult(ctx context.Context, resultRequest *proto.ResultRequest) (*proto.SignedOutcome, error) {
+	key := replicationManager.options.receiptKey
+	if key == nil {
+		return nil, status.Error(codes.Unimplemented, "signing is not enabled on this server")
+	}
+	if len(resultRequest.Nonce) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "a nonce is required")
+	}
+	outcome, err := replicationManager.GetResult(ctx, &proto.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	return signing.SignOutcome(key, resultRequest.Nonce, outcome), nil
+}
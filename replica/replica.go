@@ -0,0 +1,568 @@
+// Package replica is the replication manager of the auction application.
+//
+// A ReplicationManager hosts a single auction, which starts with the first bid. It doesn't talk to other
+// replication managers, the frontend keeps the replicas in sync by sending every bid to all of them.
+package replica
+
+import (
+	"Auction/audit"
+	"Auction/auth"
+	"Auction/clock"
+	"Auction/config"
+	proto "Auction/grpc"
+	"Auction/logging"
+	"Auction/metrics"
+	"Auction/tlsconfig"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The replication manager hosts a single auction, this is its id in ListAuctions and the HTTP API
+const auctionId = config.DefaultAuction
+
+// ErrStarted is returned by Start when the ReplicationManager is already started.
+var ErrStarted = errors.New("replication manager is already started")
+
+type ReplicationManager struct {
+	proto.UnimplementedAuctionServer
+	options       options
+	mutex         sync.Mutex
+	biddingMap    map[string]int32
+	bidHistory    []*proto.BidRecord
+	watchers      map[chan *proto.AuctionInfo]struct{}
+	ledger        *ledger
+	bans          map[string]time.Time
+	lastSignedBid map[string]int64
+	receipts      uint64
+	sealedBids    map[string]*sealedBid
+	sealedCount   int
+	isRevealing   bool
+	revealEndTime time.Time
+	metrics       *replicaMetrics
+	applied       uint64
+	connections   *connectionCounter
+	divergence    *divergenceChecker
+	startedAt     time.Time
+	isBiddingOver bool
+	isCancelled   bool
+	endTime       time.Time
+	biddingTimer  clock.Timer
+	grpcServer    *grpc.Server
+	done          chan struct{}
+	serveError    error
+}
+
+// New creates a ReplicationManager with an empty auction, it doesn't serve anything until Start is called.
+func New(opts ...Option) *ReplicationManager {
+	replicationManager := &ReplicationManager{
+		options:       defaultOptions(),
+		biddingMap:    make(map[string]int32),
+		watchers:      make(map[chan *proto.AuctionInfo]struct{}),
+		bans:          make(map[string]time.Time),
+		lastSignedBid: make(map[string]int64),
+		sealedBids:    make(map[string]*sealedBid),
+		connections:   &connectionCounter{},
+		divergence:    &divergenceChecker{},
+	}
+	for _, opt := range opts {
+		opt(&replicationManager.options)
+	}
+	if replicationManager.options.accounts != nil {
+		replicationManager.ledger = newLedger(*replicationManager.options.accounts)
+	}
+	if replicationManager.options.metrics == nil {
+		replicationManager.options.metrics = metrics.NewRegistry()
+	}
+	replicationManager.metrics = newReplicaMetrics(replicationManager, replicationManager.options.metrics)
+	return replicationManager
+}
+
+// Metrics returns the registry with the metrics of the ReplicationManager, which HttpHandler serves at /metrics.
+func (replicationManager *ReplicationManager) Metrics() *metrics.Registry {
+	return replicationManager.options.metrics
+}
+
+// Start serves the Auction service on the listener in the background.
+// The listener is closed when the ReplicationManager is stopped.
+func (replicationManager *ReplicationManager) Start(listener net.Listener) error {
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+
+	if replicationManager.grpcServer != nil {
+		return ErrStarted
+	}
+
+	// Create a new grpc server and register the replication manager
+	replicationManager.record(context.Background(), audit.Entry{Event: audit.Started})
+	replicationManager.startedAt = replicationManager.options.clock.Now()
+	grpcServer := grpc.NewServer(replicationManager.serverOptions()...)
+	proto.RegisterAuctionServer(grpcServer, replicationManager)
+	proto.RegisterAdminServer(grpcServer, &adminServer{replicationManager: replicationManager})
+	replicationManager.grpcServer = grpcServer
+	replicationManager.done = make(chan struct{})
+	replicationManager.startDivergenceCheck()
+
+	go func() {
+		err := grpcServer.Serve(listener)
+		replicationManager.mutex.Lock()
+		replicationManager.serveError = err
+		replicationManager.mutex.Unlock()
+		close(replicationManager.done)
+	}()
+	return nil
+}
+
+// Stop stops serving, closes the open Watch streams and stops the timer of the auction and the divergence check.
+// The state of the auction is kept, but a stopped ReplicationManager can't be started again.
+func (replicationManager *ReplicationManager) Stop() {
+	replicationManager.mutex.Lock()
+	grpcServer := replicationManager.grpcServer
+	if replicationManager.biddingTimer != nil {
+		replicationManager.biddingTimer.Stop()
+	}
+	replicationManager.mutex.Unlock()
+	replicationManager.stopDivergenceCheck()
+
+	if grpcServer != nil {
+		grpcServer.Stop()
+		<-replicationManager.done
+	}
+}
+
+// Wait blocks until the ReplicationManager stops serving, and returns the error that made it stop,
+// or nil if it was stopped by Stop.
+func (replicationManager *ReplicationManager) Wait() error {
+	replicationManager.mutex.Lock()
+	done := replicationManager.done
+	replicationManager.mutex.Unlock()
+	if done == nil {
+		return errors.New("replication manager is not started")
+	}
+
+	<-done
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+	if errors.Is(replicationManager.serveError, grpc.ErrServerStopped) {
+		return nil
+	}
+	return replicationManager.serveError
+}
+
+// The options of the grpc server, with the interceptors of the enabled features in front of the given options
+// The request id, tracing and metrics come first so they see every call, also the ones with injected faults,
+// and the rate limits come after authentication, since bidders are limited by the subject of their token
+func (replicationManager *ReplicationManager) serverOptions() []grpc.ServerOption {
+	var serverOptions []grpc.ServerOption
+	if replicationManager.options.tlsConfig != nil {
+		serverOptions = append(serverOptions, tlsconfig.ServerOptions(replicationManager.options.tlsConfig)...)
+	}
+	logger := replicationManager.options.logger
+	unaryInterceptors := []grpc.UnaryServerInterceptor{logging.UnaryServerInterceptor(logger)}
+	streamInterceptors := []grpc.StreamServerInterceptor{logging.StreamServerInterceptor(logger)}
+	if tracer := replicationManager.options.tracer; tracer != nil {
+		unaryInterceptors = append(unaryInterceptors, tracer.UnaryServerInterceptor())
+		streamInterceptors = append(streamInterceptors, tracer.StreamServerInterceptor())
+	}
+	unaryInterceptors = append(unaryInterceptors, replicationManager.metrics.unaryServerInterceptor())
+	if injector := replicationManager.options.faults; injector != nil {
+		unaryInterceptors = append(unaryInterceptors, injector.UnaryServerInterceptor())
+		streamInterceptors = append(streamInterceptors, injector.StreamServerInterceptor())
+	}
+	if authenticator := replicationManager.options.authenticator; authenticator != nil {
+		policy := replicationManager.policy()
+		unaryInterceptors = append(unaryInterceptors, authenticator.UnaryServerInterceptor(), policy.UnaryServerInterceptor())
+		streamInterceptors = append(streamInterceptors, authenticator.StreamServerInterceptor(), policy.StreamServerInterceptor())
+	}
+	if limits := replicationManager.options.rateLimits; limits != nil {
+		unaryInterceptors = append(unaryInterceptors, limits.UnaryServerInterceptor())
+		streamInterceptors = append(streamInterceptors, limits.StreamServerInterceptor())
+	}
+	serverOptions = append(serverOptions, grpc.ChainUnaryInterceptor(unaryInterceptors...), grpc.ChainStreamInterceptor(streamInterceptors...),
+		grpc.StatsHandler(replicationManager.connections))
+	return append(serverOptions, replicationManager.options.serverOptions...)
+}
+
+func (replicationManager *ReplicationManager) Login(ctx context.Context, loginRequest *proto.LoginRequest) (*proto.Token, error) {
+	authenticator := replicationManager.options.authenticator
+	if authenticator == nil {
+		return nil, status.Error(codes.Unimplemented, "authentication is not enabled on this server")
+	}
+	token, claims, err := authenticator.Login(loginRequest.Id, loginRequest.Password)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return &proto.Token{Token: token, ExpiresAt: claims.ExpiresAt}, nil
+}
+
+func (replicationManager *ReplicationManager) Bid(ctx context.Context, bidMessage *proto.BidMessage) (*proto.Acknowledgement, error) {
+	bidder, err := replicationManager.bidder(ctx, bidMessage.Id)
+	if err != nil {
+		return nil, err
+	}
+	if err := replicationManager.verifySignature(bidder, bidMessage); err != nil {
+		return nil, err
+	}
+
+	//The span includes the wait for the mutex, since the bids are applied one at a time
+	_, span := replicationManager.options.tracer.Start(ctx, "replica.placeBid")
+	defer span.End()
+	span.SetAttribute("bidder", bidder)
+	span.SetAttribute("amount", strconv.Itoa(int(bidMessage.Amount)))
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+	if err := replicationManager.limitBidder(bidder, bidMessage.Timestamp); err != nil {
+		return nil, err
+	}
+	//Every write that reaches the auction is counted, also the rejected ones, since the frontends send the writes
+	//to all replication managers in the same order, replication managers in sync have applied the same number
+	replicationManager.applied++
+
+	//A signed bid can only be used once, so nobody can send it again later
+	if bidMessage.Signature != nil {
+		if bidMessage.Timestamp <= replicationManager.lastSignedBid[bidder] {
+			return nil, status.Errorf(codes.InvalidArgument, "the bid is not newer than the last signed bid of %s", bidder)
+		}
+		replicationManager.lastSignedBid[bidder] = bidMessage.Timestamp
+	}
+
+	ack := replicationManager.placeBid(ctx, bidder, bidMessage)
+	span.SetAttribute("status", ack.Status)
+	if ack.Status == "success" {
+		replicationManager.record(ctx, audit.Entry{Event: audit.BidAccepted, Auction: auctionId, Bidder: bidder, Amount: bidMessage.Amount})
+	} else {
+		replicationManager.record(ctx, audit.Entry{Event: audit.BidRejected, Auction: auctionId, Bidder: bidder, Amount: bidMessage.Amount, Detail: ack.Status})
+	}
+	if key := replicationManager.options.receiptKey; key != nil {
+		ack.Receipt = replicationManager.receipt(key, bidder, bidMessage, ack.Status)
+	}
+	return ack, nil
+}
+
+// Rejects the write of a bidder who bids too fast, the mutex must be held by the caller
+// The bucket of the bidder is refilled by the time the frontend sent the write, not the time it arrived,
+// so replication managers that apply the writes in the same order reject the same ones.
+// A time ahead of the clock of the replication manager counts as now, so a client can't fill its bucket by sending later times.
+func (replicationManager *ReplicationManager) limitBidder(bidder string, sentAt int64) error {
+	limits := replicationManager.options.rateLimits
+	if limits == nil {
+		return nil
+	}
+	at := replicationManager.options.clock.Now()
+	if sentAt > 0 && sentAt < at.UnixMilli() {
+		at = time.UnixMilli(sentAt)
+	}
+	return limits.CheckBidAt(bidder, at)
+}
+
+// Helper method to apply a bid to the auction, the mutex must be held by the caller
+func (replicationManager *ReplicationManager) placeBid(ctx context.Context, bidder string, bidMessage *proto.BidMessage) *proto.Acknowledgement {
+	//If this is the first bid, start the bidding phase
+	if replicationManager.endTime.IsZero() {
+		replicationManager.startBidding(ctx)
+	}
+
+	//Return error-status if bidding is over
+	if replicationManager.isBiddingOver {
+		return &proto.Acknowledgement{Status: "fail - bidding is over"}
+	}
+
+	//In a sealed auction the amounts are only known after the bidding
+	if replicationManager.options.sealed != nil {
+		return &proto.Acknowledgement{Status: "fail - the auction is sealed, commit the bid instead"}
+	}
+
+	//Return error-status if the bidder is banned
+	if until, ok := replicationManager.bans[bidder]; ok && replicationManager.options.clock.Now().Before(until) {
+		return &proto.Acknowledgement{Status: "fail - banned until " + until.Format(time.TimeOnly)}
+	}
+
+	//Get the current highest bid
+	currentHighestBidder, currentHighestBid := replicationManager.getHighestBid()
+	//Check if the received bid is higher than the current highest bid
+	if bidMessage.Amount < currentHighestBid {
+		//Return error
+		return &proto.Acknowledgement{Status: "fail - bid too low"}
+	}
+
+	//Check if the bidder has the funds for the bid, and hold them until the bidder is outbid
+	if replicationManager.ledger != nil {
+		if !replicationManager.ledger.canAfford(bidder, auctionId, bidMessage.Amount) {
+			return &proto.Acknowledgement{Status: "fail - insufficient funds"}
+		}
+		replicationManager.ledger.hold(bidder, auctionId, bidMessage.Amount, currentHighestBidder)
+	}
+
+	//Add the new Bid to the map for the Client and remember it in the history
+	replicationManager.biddingMap[bidder] = bidMessage.Amount
+	replicationManager.bidHistory = append(replicationManager.bidHistory, &proto.BidRecord{
+		Id:        bidder,
+		Amount:    bidMessage.Amount,
+		Timestamp: replicationManager.options.clock.Now().UnixMilli(),
+	})
+	replicationManager.notifyWatchers()
+
+	//Return succesful
+	return &proto.Acknowledgement{Status: "success"}
+}
+
+func (replicationManager *ReplicationManager) GetResult(ctx context.Context, empty *proto.Empty) (*proto.Outcome, error) {
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+
+	//Get the current highest bid and bidder
+	currentHighestBidder, currentHighestBid := replicationManager.getHighestBid()
+	//If bidding is over, we return both the winner and the winning bid
+	//A cancelled auction has no winner
+	if replicationManager.isBiddingOver && !replicationManager.isCancelled {
+		winnerString := replicationManager.viewer(ctx)(currentHighestBidder)
+		return &proto.Outcome{Winner: winnerString, HighestBid: currentHighestBid}, nil
+	}
+	//If bidding is not over, we only return the current highest bid
+	return &proto.Outcome{Winner: "", HighestBid: currentHighestBid}, nil
+}
+
+func (replicationManager *ReplicationManager) GetAccount(ctx context.Context, accountRequest *proto.AccountRequest) (*proto.Account, error) {
+	if replicationManager.ledger == nil {
+		return nil, status.Error(codes.Unimplemented, "accounts are not enabled on this server")
+	}
+	//With authentication, bidders can only see their own account
+	bidder := accountRequest.Id
+	if replicationManager.options.authenticator != nil {
+		claims := auth.ClaimsFromContext(ctx)
+		if claims == nil {
+			return nil, status.Error(codes.Unauthenticated, "a token is required, log in first")
+		}
+		if bidder == "" {
+			bidder = claims.Subject
+		}
+		if bidder != claims.Subject {
+			return nil, status.Error(codes.PermissionDenied, "bidders can only see their own account")
+		}
+	}
+	if bidder == "" {
+		return nil, status.Error(codes.InvalidArgument, "the id of the bidder is required")
+	}
+
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+	return replicationManager.ledger.account(bidder), nil
+}
+
+func (replicationManager *ReplicationManager) ListAuctions(ctx context.Context, empty *proto.Empty) (*proto.AuctionList, error) {
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+
+	return &proto.AuctionList{Auctions: []*proto.AuctionInfo{showAuction(replicationManager.viewer(ctx), replicationManager.auctionInfo())}}, nil
+}
+
+func (replicationManager *ReplicationManager) GetBidHistory(ctx context.Context, empty *proto.Empty) (*proto.BidHistory, error) {
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+
+	//Copy the bids, so later bids don't race with the caller reading them, and show the bidders to the caller
+	show := replicationManager.viewer(ctx)
+	bids := make([]*proto.BidRecord, len(replicationManager.bidHistory))
+	for i, bid := range replicationManager.bidHistory {
+		bids[i] = &proto.BidRecord{Id: show(bid.Id), Amount: bid.Amount, Timestamp: bid.Timestamp}
+	}
+	return &proto.BidHistory{Bids: bids}, nil
+}
+
+func (replicationManager *ReplicationManager) Watch(empty *proto.Empty, stream proto.Auction_WatchServer) error {
+	updates, cancel := replicationManager.subscribe()
+	defer cancel()
+
+	show := replicationManager.viewer(stream.Context())
+	for {
+		select {
+		case info := <-updates:
+			if err := stream.Send(showAuction(show, info)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// Helper method to find out who placed a bid
+// With authentication the bidder is the subject of the token, and the id of the message may only repeat it
+func (replicationManager *ReplicationManager) bidder(ctx context.Context, id string) (string, error) {
+	if replicationManager.options.authenticator == nil {
+		if id == "" {
+			return "", status.Error(codes.InvalidArgument, "the id of the bidder is required")
+		}
+		return id, nil
+	}
+	claims := auth.ClaimsFromContext(ctx)
+	if claims == nil {
+		return "", status.Error(codes.Unauthenticated, "a token is required, log in first")
+	}
+	if id != "" && id != claims.Subject {
+		return "", status.Errorf(codes.PermissionDenied, "the token belongs to %s and can't bid as %s", claims.Subject, id)
+	}
+	return claims.Subject, nil
+}
+
+// Helper method to get the highest bid and bidder
+// A bid is only accepted if it is at least the highest bid, so the last accepted bid is always the highest,
+// and of two equal bids the first one is outbid
+func (replicationManager *ReplicationManager) getHighestBid() (string, int32) {
+	if len(replicationManager.bidHistory) == 0 {
+		return "", 0
+	}
+	lastBid := replicationManager.bidHistory[len(replicationManager.bidHistory)-1]
+	return lastBid.Id, lastBid.Amount
+}
+
+// Helper method to build a snapshot of the auction, the mutex must be held by the caller
+func (replicationManager *ReplicationManager) auctionInfo() *proto.AuctionInfo {
+	currentHighestBidder, currentHighestBid := replicationManager.getHighestBid()
+	info := &proto.AuctionInfo{
+		Id:          auctionId,
+		HighestBid:  currentHighestBid,
+		IsOver:      replicationManager.isBiddingOver,
+		IsCancelled: replicationManager.isCancelled,
+		IsSealed:    replicationManager.options.sealed != nil,
+	}
+	//Like GetResult, the winner is only revealed when the bidding is over
+	if replicationManager.isBiddingOver && !replicationManager.isCancelled {
+		info.Winner = currentHighestBidder
+	}
+	if !replicationManager.endTime.IsZero() {
+		info.EndsAt = replicationManager.endTime.UnixMilli()
+	}
+	if !replicationManager.revealEndTime.IsZero() {
+		info.RevealEndsAt = replicationManager.revealEndTime.UnixMilli()
+	}
+	return info
+}
+
+// Registers a watcher, which receives the current snapshot at once and then every change
+// The returned function must be called to unregister it again
+func (replicationManager *ReplicationManager) subscribe() (chan *proto.AuctionInfo, func()) {
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+
+	updates := make(chan *proto.AuctionInfo, 16)
+	updates <- replicationManager.auctionInfo()
+	replicationManager.watchers[updates] = struct{}{}
+
+	return updates, func() {
+		replicationManager.mutex.Lock()
+		defer replicationManager.mutex.Unlock()
+		delete(replicationManager.watchers, updates)
+	}
+}
+
+// Sends the current snapshot to all watchers, the mutex must be held by the caller
+// A watcher that is too slow to keep up misses the update instead of blocking the bid
+func (replicationManager *ReplicationManager) notifyWatchers() {
+	info := replicationManager.auctionInfo()
+	for watcher := range replicationManager.watchers {
+		select {
+		case watcher <- info:
+		default:
+		}
+	}
+}
+
+// Starts the bidding phase, which ends after the bidding duration, the mutex must be held by the caller
+func (replicationManager *ReplicationManager) startBidding(ctx context.Context) {
+	replicationManager.endTime = replicationManager.options.clock.Now().Add(replicationManager.options.biddingDuration)
+	replicationManager.biddingTimer = replicationManager.options.clock.AfterFunc(replicationManager.options.biddingDuration, replicationManager.endBidding)
+	replicationManager.record(ctx, audit.Entry{Event: audit.Opened, Auction: auctionId, Detail: "ends at " + replicationManager.endTime.Format(time.RFC3339)})
+}
+
+func (replicationManager *ReplicationManager) endBidding() {
+	//The timer isn't part of a request, so the span starts a trace of its own
+	ctx, span := replicationManager.options.tracer.Start(context.Background(), "replica.endPhase")
+	defer span.End()
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+	replicationManager.endPhase(ctx)
+}
+
+// Ends the current phase of the auction, the mutex must be held by the caller
+// A sealed auction has a reveal window after the bidding, every other auction is over when the bidding ends
+func (replicationManager *ReplicationManager) endPhase(ctx context.Context) {
+	switch {
+	case replicationManager.options.sealed == nil:
+		replicationManager.closeBidding(ctx)
+	case !replicationManager.isRevealing:
+		replicationManager.startReveal(ctx)
+	default:
+		replicationManager.finishReveal(ctx)
+	}
+}
+
+// Ends the bidding phase, the mutex must be held by the caller
+func (replicationManager *ReplicationManager) closeBidding(ctx context.Context) {
+	if replicationManager.isBiddingOver {
+		return
+	}
+	if replicationManager.biddingTimer != nil {
+		replicationManager.biddingTimer.Stop()
+	}
+	replicationManager.isBiddingOver = true
+	winner, winningBid := replicationManager.getHighestBid()
+	//The winner pays the winning bid
+	if replicationManager.ledger != nil {
+		replicationManager.ledger.capture(winner, auctionId)
+	}
+	if replicationManager.isCancelled {
+		replicationManager.record(ctx, audit.Entry{Event: audit.Cancelled, Auction: auctionId})
+	} else {
+		replicationManager.record(ctx, audit.Entry{Event: audit.Closed, Auction: auctionId, Bidder: winner, Amount: winningBid})
+	}
+	replicationManager.notifyWatchers()
+}
+
+// The log messages of the events of the audit log
+var eventMessages = map[string]string{
+	audit.Started:     "Replication manager started",
+	audit.Opened:      "Auction opened",
+	audit.BidAccepted: "Bid accepted",
+	audit.BidRejected: "Bid rejected",
+	audit.Closed:      "Auction closed",
+	audit.Cancelled:   "Auction cancelled",
+	audit.Banned:      "Bidder banned",
+	audit.Unbanned:    "Bidder unbanned",
+	audit.Committed:   "Sealed bid committed",
+	audit.Revealing:   "Reveal window opened",
+	audit.Revealed:    "Sealed bid revealed",
+	audit.Penalized:   "Bidder penalized",
+}
+
+// Helper method to log a state change and append it to the audit log, if there is one
+// The context is the request that caused the change, so the log has its request id
+func (replicationManager *ReplicationManager) record(ctx context.Context, entry audit.Entry) {
+	attrs := []any{"event", entry.Event}
+	if entry.Auction != "" {
+		attrs = append(attrs, "auction", entry.Auction)
+	}
+	if entry.Bidder != "" {
+		attrs = append(attrs, "bidder", entry.Bidder)
+	}
+	if entry.Amount != 0 {
+		attrs = append(attrs, "amount", entry.Amount)
+	}
+	if entry.Detail != "" {
+		attrs = append(attrs, "detail", entry.Detail)
+	}
+	replicationManager.options.logger.InfoContext(ctx, eventMessages[entry.Event], attrs...)
+
+	if replicationManager.options.auditLog == nil {
+		return
+	}
+	entry.Time = replicationManager.options.clock.Now().UnixMilli()
+	replicationManager.options.auditLog.Append(entry)
+}
@@ -0,0 +1,48 @@
+package replica
+
+import (
+	"Auction/clock"
+	proto "Auction/grpc"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// The start in the status is the time on the clock of the auction, like every other time of the replication manager
+func TestStartedAtUsesTheClock(t *testing.T) {
+	start := time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC)
+	replicationManager := New(WithClock(clock.NewManual(start)))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := replicationManager.Start(listener); err != nil {
+		t.Fatal(err)
+	}
+	defer replicationManager.Stop()
+	nodeStatus, err := (&adminServer{replicationManager: replicationManager}).Status(context.Background(), &proto.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nodeStatus.StartedAt != start.UnixMilli() {
+		t.Fatalf("expected the start %v, got %v", start, time.UnixMilli(nodeStatus.StartedAt))
+	}
+}
+
+// A bid applied by a replication manager directly, without grpc or replication
+func BenchmarkBid(b *testing.B) {
+	replicationManager := New()
+	ctx := context.Background()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ack, err := replicationManager.Bid(ctx, &proto.BidMessage{Id: "bench", Amount: int32(i + 1)})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if ack.Status != "success" {
+			b.Fatalf("bid %d: %s", i+1, ack.Status)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package replica
+
+import (
+	"Auction/audit"
+	proto "Auction/grpc"
+	"Auction/sealed"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The reveal window of a sealed auction when the configuration doesn't set one
+const defaultRevealWindow = 30 * time.Second
+
+// A sealed bid, the amount is only known once it is revealed
+type sealedBid struct {
+	commitment []byte
+	// The order of the commitments, of two equal bids the one committed first wins
+	order      int
+	revealed   bool
+	amount     int32
+	revealedAt time.Time
+}
+
+func (replicationManager *ReplicationManager) CommitBid(ctx context.Context, commitment *proto.Commitment) (*proto.Acknowledgement, error) {
+	if replicationManager.options.sealed == nil {
+		return nil, status.Error(codes.FailedPrecondition, "the auction is not sealed, bid instead")
+	}
+	bidder, err := replicationManager.bidder(ctx, commitment.Id)
+	if err != nil {
+		return nil, err
+	}
+	if len(commitment.Commitment) != sha256.Size {
+		return nil, status.Errorf(codes.InvalidArgument, "the commitment must be a SHA-256 hash of %d bytes", sha256.Size)
+	}
+
+	_, span := replicationManager.options.tracer.Start(ctx, "replica.placeCommitment")
+	defer span.End()
+	span.SetAttribute("bidder", bidder)
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+	if err := replicationManager.limitBidder(bidder, commitment.Timestamp); err != nil {
+		return nil, err
+	}
+	replicationManager.applied++
+
+	ack := replicationManager.placeCommitment(ctx, bidder, commitment)
+	span.SetAttribute("status", ack.Status)
+	if ack.Status == "success" {
+		replicationManager.record(ctx, audit.Entry{Event: audit.Committed, Auction: auctionId, Bidder: bidder, Detail: fmt.Sprintf("%x", commitment.Commitment)})
+	} else {
+		replicationManager.record(ctx, audit.Entry{Event: audit.BidRejected, Auction: auctionId, Bidder: bidder, Detail: ack.Status})
+	}
+	return ack, nil
+}
+
+// Helper method to store a commitment, the mutex must be held by the caller
+func (replicationManager *ReplicationManager) placeCommitment(ctx context.Context, bidder string, commitment *proto.Commitment) *proto.Acknowledgement {
+	//If this is the first commitment, start the bidding phase
+	if replicationManager.endTime.IsZero() {
+		replicationManager.startBidding(ctx)
+	}
+	if replicationManager.isBiddingOver {
+		return &proto.Acknowledgement{Status: "fail - bidding is over"}
+	}
+	if replicationManager.isRevealing {
+		return &proto.Acknowledgement{Status: "fail - bidding is over, reveal the bid instead"}
+	}
+	if until, ok := replicationManager.bans[bidder]; ok && replicationManager.options.clock.Now().Before(until) {
+		return &proto.Acknowledgement{Status: "fail - banned until " + until.Format(time.TimeOnly)}
+	}
+
+	replicationManager.sealedCount++
+	replicationManager.sealedBids[bidder] = &sealedBid{commitment: commitment.Commitment, order: replicationManager.sealedCount}
+	return &proto.Acknowledgement{Status: "success"}
+}
+
+func (replicationManager *ReplicationManager) RevealBid(ctx context.Context, reveal *proto.Reveal) (*proto.Acknowledgement, error) {
+	if replicationManager.options.sealed == nil {
+		return nil, status.Error(codes.FailedPrecondition, "the auction is not sealed, there is nothing to reveal")
+	}
+	bidder, err := replicationManager.bidder(ctx, reveal.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	_, span := replicationManager.options.tracer.Start(ctx, "replica.placeReveal")
+	defer span.End()
+	span.SetAttribute("bidder", bidder)
+	span.SetAttribute("amount", strconv.Itoa(int(reveal.Amount)))
+	replicationManager.mutex.Lock()
+	defer replicationManager.mutex.Unlock()
+	if err := replicationManager.limitBidder(bidder, reveal.Timestamp); err != nil {
+		return nil, err
+	}
+	replicationManager.applied++
+
+	ack := replicationManager.placeReveal(bidder, reveal)
+	span.SetAttribute("status", ack.Status)
+	if ack.Status == "success" {
+		replicationManager.record(ctx, audit.Entry{Event: audit.Revealed, Auction: auctionId, Bidder: bidder, Amount: reveal.Amount})
+	} else {
+		replicationManager.record(ctx, audit.Entry{Event: audit.BidRejected, Auction: auctionId, Bidder: bidder, Amount: reveal.Amount, Detail: ack.Status})
+	}
+	return ack, nil
+}
+
+// Helper method to check a reveal against the commitment, the mutex must be held by the caller
+// A reveal that is rejected can be sent again until the reveal window ends
+func (replicationManager *ReplicationManager) placeReveal(bidder string, reveal *proto.Reveal) *proto.Acknowledgement {
+	if replicationManager.isBiddingOver {
+		return &proto.Acknowledgement{Status: "fail - bidding is over"}
+	}
+	if !replicationManager.isRevealing {
+		return &proto.Acknowledgement{Status: "fail - the bidding is not over yet, reveal the bid after it"}
+	}
+	bid, ok := replicationManager.sealedBids[bidder]
+	if !ok {
+		return &proto.Acknowledgement{Status: "fail - no sealed bid to reveal"}
+	}
+	if bid.revealed {
+		return &proto.Acknowledgement{Status: "fail - the bid is already revealed"}
+	}
+	if reveal.Amount <= 0 {
+		return &proto.Acknowledgement{Status: "fail - the amount must be positive"}
+	}
+	if subtle.ConstantTimeCompare(sealed.Commitment(bidder, reveal.Amount, reveal.Nonce), bid.commitment) != 1 {
+		return &proto.Acknowledgement{Status: "fail - the amount and nonce don't match the commitment"}
+	}
+	if replicationManager.ledger != nil && !replicationManager.ledger.canAfford(bidder, auctionId, reveal.Amount) {
+		return &proto.Acknowledgement{Status: "fail - insufficient funds"}
+	}
+
+	bid.revealed = true
+	bid.amount = reveal.Amount
+	bid.revealedAt = replicationManager.options.clock.Now()
+	return &proto.Acknowledgement{Status: "success"}
+}
+
+// Ends the bidding of a sealed auction and opens the reveal window, the mutex must be held by the caller
+func (replicationManager *ReplicationManager) startReveal(ctx context.Context) {
+	if replicationManager.biddingTimer != nil {
+		replicationManager.biddingTimer.Stop()
+	}
+	revealWindow := time.Duration(replicationManager.options.sealed.RevealWindow)
+	if revealWindow <= 0 {
+		revealWindow = defaultRevealWindow
+	}
+	replicationManager.isRevealing = true
+	replicationManager.revealEndTime = replicationManager.options.clock.Now().Add(revealWindow)
+	replicationManager.biddingTimer = replicationManager.options.clock.AfterFunc(revealWindow, replicationManager.endBidding)
+	replicationManager.record(ctx, audit.Entry{Event: audit.Revealing, Auction: auctionId, Detail: "ends at " + replicationManager.revealEndTime.Format(time.RFC3339)})
+	replicationManager.notifyWatchers()
+}
+
+// Ends the reveal window, picks the winner from the revealed bids and penalizes the bidders who didn't reveal,
+// the mutex must be held by the caller
+func (replicationManager *ReplicationManager) finishReveal(ctx context.Context) {
+	replicationManager.isRevealing = false
+
+	//The revealed bids go into the history from the lowest to the highest, so the last bid is the winner
+	//like in an open auction, and of two equal bids the one committed first comes last
+	//The bidders are sorted, so every replication manager writes the penalties to its audit log in the same order
+	bidders := make([]string, 0, len(replicationManager.sealedBids))
+	for bidder := range replicationManager.sealedBids {
+		bidders = append(bidders, bidder)
+	}
+	sort.Strings(bidders)
+	var revealed []string
+	for _, bidder := range bidders {
+		if replicationManager.sealedBids[bidder].revealed {
+			revealed = append(revealed, bidder)
+		} else {
+			replicationManager.penalize(ctx, bidder)
+		}
+	}
+	sort.Slice(revealed, func(i, j int) bool {
+		first, second := replicationManager.sealedBids[revealed[i]], replicationManager.sealedBids[revealed[j]]
+		if first.amount != second.amount {
+			return first.amount < second.amount
+		}
+		return first.order > second.order
+	})
+	for _, bidder := range revealed {
+		bid := replicationManager.sealedBids[bidder]
+		replicationManager.biddingMap[bidder] = bid.amount
+		replicationManager.bidHistory = append(replicationManager.bidHistory, &proto.BidRecord{
+			Id:        bidder,
+			Amount:    bid.amount,
+			Timestamp: bid.revealedAt.UnixMilli(),
+		})
+		replicationManager.record(ctx, audit.Entry{Event: audit.BidAccepted, Auction: auctionId, Bidder: bidder, Amount: bid.amount})
+	}
+
+	//The winner pays like in an open auction, by holding the amount until closeBidding captures it
+	if winner, winningBid := replicationManager.getHighestBid(); winner != "" && replicationManager.ledger != nil {
+		replicationManager.ledger.hold(winner, auctionId, winningBid, "")
+	}
+	replicationManager.closeBidding(ctx)
+}
+
+// Applies the penalty of the configuration to a bidder who didn't reveal their bid, the mutex must be held by the caller
+func (replicationManager *ReplicationManager) penalize(ctx context.Context, bidder string) {
+	policy := replicationManager.options.sealed
+	detail := ""
+	switch policy.Penalty {
+	case sealed.PenaltyFine:
+		if replicationManager.ledger == nil {
+			return
+		}
+		detail = fmt.Sprintf("fined %d", replicationManager.ledger.charge(bidder, policy.Fine))
+	case sealed.PenaltyBan:
+		until := replicationManager.options.clock.Now().Add(time.Duration(policy.BanDuration))
+		replicationManager.bans[bidder] = until
+		detail = "banned until " + until.Format(time.RFC3339)
+	default:
+		return
+	}
+	replicationManager.record(ctx, audit.Entry{Event: audit.Penalized, Auction: auctionId, Bidder: bidder, Detail: detail})
+}
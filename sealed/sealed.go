@@ -0,0 +1,69 @@
+// Package sealed has the commitments of sealed bidding.
+//
+// In a sealed auction a bidder first sends only a commitment, the SHA-256 of their name, the amount and a
+// secret nonce, so nobody, not even the operator of the servers, knows the amount during the bidding.
+// After the bidding the bidder reveals the amount and the nonce, and the servers check them against the commitment.
+package sealed
+
+import (
+	"Auction/config"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// The penalties for bidders who don't reveal their bid
+const (
+	PenaltyNone = "none"
+	PenaltyFine = "fine"
+	PenaltyBan  = "ban"
+)
+
+// NonceSize is the size of the nonces made by NewBid, large enough that the amount can't be guessed from the commitment.
+const NonceSize = 32
+
+// Commitment is the hash a bidder commits to. The bidder is part of it, so a bidder can't copy the commitment of another.
+func Commitment(bidder string, amount int32, nonce []byte) []byte {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("auction-commitment %q %d %s", bidder, amount, hex.EncodeToString(nonce))))
+	return sum[:]
+}
+
+// Bid is a sealed bid with its secret nonce, which the bidder must keep until they reveal the bid.
+type Bid struct {
+	Bidder string
+	Amount int32
+	Nonce  []byte
+}
+
+// NewBid creates a sealed bid with a random nonce.
+func NewBid(bidder string, amount int32) (*Bid, error) {
+	nonce := make([]byte, NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return &Bid{Bidder: bidder, Amount: amount, Nonce: nonce}, nil
+}
+
+// Commitment returns the commitment of the bid.
+func (bid *Bid) Commitment() []byte {
+	return Commitment(bid.Bidder, bid.Amount, bid.Nonce)
+}
+
+// CheckPenalty checks the penalty in the sealed section of the configuration.
+func CheckPenalty(sealed config.Sealed) error {
+	switch sealed.Penalty {
+	case "", PenaltyNone:
+	case PenaltyFine:
+		if sealed.Fine <= 0 {
+			return fmt.Errorf("the fine must be positive")
+		}
+	case PenaltyBan:
+		if sealed.BanDuration <= 0 {
+			return fmt.Errorf("the ban duration must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown penalty %q, must be %s, %s or %s", sealed.Penalty, PenaltyNone, PenaltyFine, PenaltyBan)
+	}
+	return nil
+}
@@ -1,117 +1,275 @@
-// Server/Replication manager
+// Server/Replication manager, the replication logic is in the replica package
 package main
 
 import (
-	proto "Auction/grpc"
-	"context"
+	"Auction/audit"
+	"Auction/auth"
+	"Auction/authz"
+	"Auction/config"
+	"Auction/faults"
+	"Auction/frontend"
+	"Auction/logging"
+	"Auction/metrics"
+	"Auction/replica"
+	"Auction/sealed"
+	"Auction/signing"
+	"Auction/tlsconfig"
+	"Auction/tracing"
+	"crypto/ed25519"
+	"crypto/tls"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
-type ReplicationManager struct {
-	proto.UnimplementedAuctionServer
-	biddingMap    map[string]int32
-	port          int32
-	isBiddingOver bool
-}
-
 func main() {
+	configPath := flag.String("config", "", "the JSON configuration file")
+	logFlags := logging.AddFlags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-config file] [-log-level level] [-log-format text|json] number\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
 	//Parse the port number from the command line
-	arg1, _ := strconv.ParseInt(os.Args[1], 10, 32)
+	arg1, _ := strconv.ParseInt(flag.Arg(0), 10, 32)
 	ownPort := int32(arg1) + 5000
+	httpPort := int32(arg1) + 8000
 
-	// Create a RM struct with the port and an empty map for the bids
-	replicationManager := &ReplicationManager{
-		port:       ownPort,
-		biddingMap: make(map[string]int32),
+	//Every record has the number of the server, so the logs of all servers can be read together
+	logger, err := logFlags.New(os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
+	logger = logger.With("server", arg1)
+	slog.SetDefault(logger)
 
-	// Start the server
-	startServer(replicationManager)
-}
-
-func startServer(replicationManager *ReplicationManager) {
-
-	// Create a new grpc server
-	grpcServer := grpc.NewServer()
+	serverConfig := &config.Config{}
+	if *configPath != "" {
+		serverConfig, err = config.Load(*configPath)
+		if err != nil {
+			logging.Fatal("Could not load the configuration", "err", err)
+		}
+	}
 
-	// Make the server listen at the given port (convert int port to string)
-	listener, err := net.Listen("tcp", fmt.Sprintf(":%v", replicationManager.port))
+	// Create a RM with an empty auction
+	options := []replica.Option{replica.WithLogger(logger)}
+	if serverConfig.Auth != nil {
+		authenticator, err := auth.New(*serverConfig.Auth)
+		if err != nil {
+			logging.Fatal("Could not set up authentication", "err", err)
+		}
+		options = append(options, replica.WithAuthenticator(authenticator))
 
-	if err != nil {
-		log.Fatalf("Could not create the Replication Manager %v", err)
+		policy, err := authz.NewPolicy(authorization(serverConfig))
+		if err != nil {
+			logging.Fatal("Could not set up authorization", "err", err)
+		}
+		options = append(options, replica.WithPolicy(policy))
+	} else {
+		logger.Warn("Authentication is not configured, bidders are trusted to send their own id")
 	}
-	log.Printf("Started Replication Manager at port: %d\n", replicationManager.port)
-
-	// Register the grpc server and serve its listener
-	proto.RegisterAuctionServer(grpcServer, replicationManager)
-	serveError := grpcServer.Serve(listener)
-	if serveError != nil {
-		log.Fatalf("Could not serve listener")
+	if serverConfig.Auction != nil {
+		options = append(options, replica.WithSeller(serverConfig.Auction.Seller))
+		if serverConfig.Auction.Duration > 0 {
+			options = append(options, replica.WithBiddingDuration(time.Duration(serverConfig.Auction.Duration)))
+		}
+		if sealedConfig := serverConfig.Auction.Sealed; sealedConfig != nil {
+			if err := sealed.CheckPenalty(*sealedConfig); err != nil {
+				logging.Fatal("Invalid sealed bidding", "err", err)
+			}
+			if sealedConfig.Penalty == sealed.PenaltyFine && serverConfig.Accounts == nil {
+				logging.Fatal("Invalid sealed bidding, fines need an accounts section")
+			}
+			options = append(options, replica.WithSealedBidding(*sealedConfig))
+		}
+	}
+	if serverConfig.Accounts != nil {
+		options = append(options, replica.WithAccounts(*serverConfig.Accounts))
+	}
+	if serverConfig.RateLimits != nil {
+		options = append(options, replica.WithRateLimits(*serverConfig.RateLimits))
+	}
+	if serverConfig.Faults != nil {
+		injector, err := faults.New(*serverConfig.Faults)
+		if err != nil {
+			logging.Fatal("Could not read the fault rules", "err", err)
+		}
+		logger.Warn("Fault injection is enabled", "rules", len(serverConfig.Faults.Rules))
+		options = append(options, replica.WithFaults(injector))
+	}
+	if serverConfig.Signing != nil {
+		signingOptions, err := signingOptions(*serverConfig.Signing)
+		if err != nil {
+			logging.Fatal("Could not set up signing", "err", err)
+		}
+		options = append(options, signingOptions...)
+	}
+	if serverConfig.Privacy != nil {
+		secret, err := os.ReadFile(serverConfig.Privacy.KeyFile)
+		if err != nil {
+			logging.Fatal("Could not read the key of the pseudonyms", "err", err)
+		}
+		secret = []byte(strings.TrimSpace(string(secret)))
+		if len(secret) < 32 {
+			logging.Fatal("The key of the pseudonyms must be at least 32 bytes")
+		}
+		options = append(options, replica.WithPseudonyms(secret))
+	}
+	if serverConfig.Audit != nil {
+		auditLog, err := audit.Open(filepath.Join(serverConfig.Audit.Dir, fmt.Sprintf("audit-%d.jsonl", arg1)))
+		if err != nil {
+			logging.Fatal("Could not open the audit log", "err", err)
+		}
+		defer auditLog.Close()
+		options = append(options, replica.WithAuditLog(auditLog))
+	}
+	var tracer *tracing.Tracer
+	if serverConfig.Tracing != nil {
+		exporter, err := tracing.OpenFile(filepath.Join(serverConfig.Tracing.Dir, fmt.Sprintf("spans-%d.jsonl", arg1)))
+		if err != nil {
+			logging.Fatal("Could not open the file of the spans", "err", err)
+		}
+		defer exporter.Close()
+		tracer = tracing.New(fmt.Sprintf("server-%d", arg1), exporter)
+		options = append(options, replica.WithTracer(tracer))
 	}
-}
 
-func (replicationManager *ReplicationManager) Bid(ctx context.Context, bidMessage *proto.BidMessage) (*proto.Acknowledgement, error) {
-	//If map is empty, start the bidding phase
-	if len(replicationManager.biddingMap) == 0 {
-		go replicationManager.startBidding()
+	//The servers connect to each other through the gateway of the HTTP API
+	gatewayOptions := []frontend.Option{frontend.WithDiscovery(frontend.DefaultReplicas), frontend.WithTracer(tracer), frontend.WithLogger(logger.With("component", "gateway"))}
+	if serverConfig.Cluster != nil {
+		clusterOptions, err := frontend.ClusterOptions(*serverConfig.Cluster)
+		if err != nil {
+			logging.Fatal("Could not read the cluster", "err", err)
+		}
+		gatewayOptions = append(gatewayOptions, clusterOptions...)
+	}
+	if serverConfig.RateLimits != nil {
+		gatewayOptions = append(gatewayOptions, frontend.WithRateLimits(*serverConfig.RateLimits))
+	}
+	var httpTls *tls.Config
+	if serverConfig.Tls != nil {
+		serverTls, err := tlsconfig.ServerConfig(*serverConfig.Tls)
+		if err != nil {
+			logging.Fatal("Could not set up TLS", "err", err)
+		}
+		peerTls, err := tlsconfig.PeerConfig(*serverConfig.Tls)
+		if err != nil {
+			logging.Fatal("Could not set up TLS", "err", err)
+		}
+		options = append(options, replica.WithTls(serverTls))
+		gatewayOptions = append(gatewayOptions, frontend.WithDialOptions(grpc.WithTransportCredentials(credentials.NewTLS(peerTls))))
+		httpTls = serverTls
+	} else {
+		logger.Warn("TLS is not configured, requests are sent in plaintext")
 	}
 
-	//Return error-status if bidding is over
-	if replicationManager.isBiddingOver {
-		return &proto.Acknowledgement{Status: "fail - bidding is over"}, nil
+	//Bids placed over HTTP go through the gateway, so they reach every replication manager,
+	//and the Admin service sees the other replication managers through it
+	//Its metrics are served at /metrics together with the metrics of the replication manager
+	registry := metrics.NewRegistry()
+	gatewayOptions = append(gatewayOptions, frontend.WithMetrics(registry))
+	gateway, err := frontend.New(gatewayOptions...)
+	if err != nil {
+		logging.Fatal("Could not create the gateway", "err", err)
+	}
+	options = append(options, replica.WithMetrics(registry), replica.WithPeers(gateway),
+		replica.WithIdentity(strconv.FormatInt(arg1, 10), ownAddress(serverConfig, int(arg1), ownPort)))
+	if serverConfig.Divergence != nil && serverConfig.Divergence.Interval > 0 {
+		options = append(options, replica.WithDivergenceCheck(time.Duration(serverConfig.Divergence.Interval)))
 	}
+	replicationManager := replica.New(options...)
 
-	//Get the current highest bid
-	_, currentHighestBid := replicationManager.getHighestBid()
-	//Check if the received bid is higher than the current highest bid
-	if bidMessage.Amount < currentHighestBid {
-		//Return error
-		return &proto.Acknowledgement{Status: "fail - bid too low"}, nil
+	// Make the server listen at the given port (convert int port to string)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%v", ownPort))
+	if err != nil {
+		logging.Fatal("Could not listen for grpc", "port", ownPort, "err", err)
+	}
+	if err := replicationManager.Start(listener); err != nil {
+		logging.Fatal("Could not start the replication manager", "err", err)
 	}
+	logger.Info("Serving grpc", "port", ownPort)
 
-	//Add the new Bid to the map for the Client
-	replicationManager.biddingMap[bidMessage.Id] = bidMessage.Amount
+	// Start the HTTP/JSON API next to the grpc server
+	go startHttpServer(replicationManager, gateway, httpPort, httpTls)
 
-	//Return succesful
-	return &proto.Acknowledgement{Status: "success"}, nil
+	if err := replicationManager.Wait(); err != nil {
+		logging.Fatal("Stopped serving grpc", "err", err)
+	}
 }
 
-func (replicationManager *ReplicationManager) GetResult(ctx context.Context, empty *proto.Empty) (*proto.Outcome, error) {
-	//Get the current highest bid and bidder
-	currentHighestBidder, currentHighestBid := replicationManager.getHighestBid()
-	//If bidding is over, we return both the winner and the winning bid
-	if replicationManager.isBiddingOver {
-		winnerString := currentHighestBidder
-		return &proto.Outcome{Winner: winnerString, HighestBid: currentHighestBid}, nil
+// The authorization section of the configuration, or the default policy if there is none
+func authorization(serverConfig *config.Config) config.Authorization {
+	if serverConfig.Authorization == nil {
+		return config.Authorization{}
 	}
-	//If bidding is not over, we only return the current highest bid
-	return &proto.Outcome{Winner: "", HighestBid: currentHighestBid}, nil
+	return *serverConfig.Authorization
 }
 
-//Helper method to get the highest bid and bidder from the map of bids
-func (replicationManager *ReplicationManager) getHighestBid() (string, int32) {
-	var currentHighestBidder string
-	currentHighestBid := int32(0)
+// The address the other servers reach the server at, the entry of the server in the cluster section,
+// or the port on localhost like in the default cluster
+func ownAddress(serverConfig *config.Config, number int, port int32) string {
+	if serverConfig.Cluster != nil && number >= 0 && number < len(serverConfig.Cluster.Replicas) {
+		return serverConfig.Cluster.Replicas[number]
+	}
+	return fmt.Sprintf("localhost:%d", port)
+}
 
-	//Run through the map to find highest bid and bidder
-	for key, value := range replicationManager.biddingMap {
-		if value > currentHighestBid {
-			currentHighestBid = value
-			currentHighestBidder = key
+// The options for signing receipts and checking the signatures of the bids
+func signingOptions(signingConfig config.Signing) ([]replica.Option, error) {
+	var options []replica.Option
+	if signingConfig.KeyFile != "" {
+		privateKey, err := signing.LoadPrivateKey(signingConfig.KeyFile)
+		if err != nil {
+			return nil, err
 		}
+		options = append(options, replica.WithReceiptKey(privateKey))
+		slog.Info("Signing receipts", "publicKey", signing.EncodePublicKey(privateKey.Public().(ed25519.PublicKey)))
 	}
-	return currentHighestBidder, currentHighestBid
+	if signingConfig.Bidders != nil {
+		bidderKeys := make(map[string]ed25519.PublicKey)
+		for bidder, encoded := range signingConfig.Bidders {
+			publicKey, err := signing.ParsePublicKey(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("the key of %s: %w", bidder, err)
+			}
+			bidderKeys[bidder] = publicKey
+		}
+		options = append(options, replica.WithBidderKeys(bidderKeys))
+	}
+	return options, nil
 }
 
-//Method to start the bidding phase which is now hardcoded to last 60 seconds
-func (replicationManager *ReplicationManager) startBidding() {
-	time.Sleep(60 * time.Second)
-	replicationManager.isBiddingOver = true
+func startHttpServer(replicationManager *replica.ReplicationManager, gateway *frontend.AuctionClient, port int32, tlsConfig *tls.Config) {
+	httpServer := &http.Server{
+		Addr:      fmt.Sprintf(":%v", port),
+		Handler:   replicationManager.HttpHandler(gateway),
+		TLSConfig: tlsConfig,
+	}
+	slog.Info("Serving the HTTP API", "port", port)
+	var err error
+	if tlsConfig != nil {
+		//The certificate is already in the TLS configuration
+		err = httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	if err != nil {
+		slog.Error("Stopped serving the HTTP API", "err", err)
+	}
 }
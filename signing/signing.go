@@ -0,0 +1,146 @@
+// Package signing signs bids and receipts with Ed25519, so a bidder can't deny a bid and a server can't deny its answer.
+//
+// The signatures are over a canonical text encoding of the fields, and not over the proto encoding,
+// which isn't guaranteed to be the same between proto libraries.
+package signing
+
+import (
+	"Auction/auth"
+	proto "Auction/grpc"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Errors of the verification
+var (
+	ErrInvalidSignature = errors.New("the signature is invalid")
+	ErrUnknownServer    = errors.New("the answer is not signed by a trusted server")
+)
+
+// EncodePublicKey encodes the public key as base64, like the keys in the configuration.
+func EncodePublicKey(publicKey ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(publicKey)
+}
+
+// ParsePublicKey parses a base64 encoded Ed25519 public key.
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("the public key is not base64: %w", err)
+	}
+	if len(data) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("the public key must be %d bytes, it is %d", ed25519.PublicKeySize, len(data))
+	}
+	return ed25519.PublicKey(data), nil
+}
+
+// LoadPrivateKey reads a PEM encoded Ed25519 private key, as written by `auctionctl gen-key -alg EdDSA`.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return auth.ParseEd25519PrivateKey(data)
+}
+
+// BidPayload is the text the bidder signs. The bidder is given separately, since with authentication
+// the bidder comes from the token and the id of the message may be empty.
+func BidPayload(bidder string, bidMessage *proto.BidMessage) []byte {
+	return []byte(fmt.Sprintf("auction-bid %q %d %d", bidder, bidMessage.Amount, bidMessage.Timestamp))
+}
+
+// SignBid signs the bid with the key of the bidder. The timestamp of the bid must be set before.
+func SignBid(privateKey ed25519.PrivateKey, bidMessage *proto.BidMessage) {
+	bidMessage.Signature = ed25519.Sign(privateKey, BidPayload(bidMessage.Id, bidMessage))
+}
+
+// VerifyBid checks that the bid was signed by the key of the bidder.
+func VerifyBid(publicKey ed25519.PublicKey, bidder string, bidMessage *proto.BidMessage) error {
+	if !ed25519.Verify(publicKey, BidPayload(bidder, bidMessage), bidMessage.Signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ReceiptPayload is the text the server signs, all fields of the receipt except the signature.
+func ReceiptPayload(receipt *proto.Receipt) []byte {
+	return []byte(fmt.Sprintf("auction-receipt %q %d %d %q %q %q %d %d %s",
+		receipt.Server, receipt.Sequence, receipt.Timestamp, receipt.Status, receipt.Auction,
+		receipt.Bidder, receipt.Amount, receipt.BidTimestamp, base64.StdEncoding.EncodeToString(receipt.BidSignature)))
+}
+
+// SignReceipt fills in the public key of the server and signs the receipt.
+func SignReceipt(privateKey ed25519.PrivateKey, receipt *proto.Receipt) {
+	receipt.Server = EncodePublicKey(privateKey.Public().(ed25519.PublicKey))
+	receipt.Signature = ed25519.Sign(privateKey, ReceiptPayload(receipt))
+}
+
+// VerifyReceipt checks that the receipt was signed by one of the trusted servers.
+// With the public key of the bidder, it also checks that the bidder signed the bid of the receipt.
+func VerifyReceipt(receipt *proto.Receipt, trustedServers []ed25519.PublicKey, bidderKey ed25519.PublicKey) error {
+	serverKey, err := trustedKey(receipt.Server, trustedServers)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(serverKey, ReceiptPayload(receipt), receipt.Signature) {
+		return fmt.Errorf("receipt: %w", ErrInvalidSignature)
+	}
+	if bidderKey != nil {
+		bidMessage := &proto.BidMessage{Amount: receipt.Amount, Timestamp: receipt.BidTimestamp, Signature: receipt.BidSignature}
+		if err := VerifyBid(bidderKey, receipt.Bidder, bidMessage); err != nil {
+			return fmt.Errorf("bid: %w", err)
+		}
+	}
+	return nil
+}
+
+// OutcomePayload is the text the server signs for a result.
+func OutcomePayload(server string, nonce []byte, outcome *proto.Outcome) []byte {
+	return []byte(fmt.Sprintf("auction-result %q %s %d %q",
+		server, base64.StdEncoding.EncodeToString(nonce), outcome.HighestBid, outcome.Winner))
+}
+
+// SignOutcome signs the result for the nonce of the caller.
+func SignOutcome(privateKey ed25519.PrivateKey, nonce []byte, outcome *proto.Outcome) *proto.SignedOutcome {
+	signed := &proto.SignedOutcome{
+		Outcome: outcome,
+		Nonce:   nonce,
+		Server:  EncodePublicKey(privateKey.Public().(ed25519.PublicKey)),
+	}
+	signed.Signature = ed25519.Sign(privateKey, OutcomePayload(signed.Server, nonce, outcome))
+	return signed
+}
+
+// VerifyOutcome checks that the result was signed by one of the trusted servers for the nonce.
+func VerifyOutcome(signed *proto.SignedOutcome, nonce []byte, trustedServers []ed25519.PublicKey) error {
+	serverKey, err := trustedKey(signed.Server, trustedServers)
+	if err != nil {
+		return err
+	}
+	if signed.Outcome == nil || !bytes.Equal(signed.Nonce, nonce) {
+		return fmt.Errorf("result: %w", ErrInvalidSignature)
+	}
+	if !ed25519.Verify(serverKey, OutcomePayload(signed.Server, nonce, signed.Outcome), signed.Signature) {
+		return fmt.Errorf("result: %w", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// Parses the key of a server and checks that it is one of the trusted keys
+func trustedKey(server string, trustedServers []ed25519.PublicKey) (ed25519.PublicKey, error) {
+	serverKey, err := ParsePublicKey(server)
+	if err != nil {
+		return nil, err
+	}
+	for _, trustedServer := range trustedServers {
+		if trustedServer.Equal(serverKey) {
+			return serverKey, nil
+		}
+	}
+	return nil, ErrUnknownServer
+}
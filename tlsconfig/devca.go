@@ -0,0 +1,108 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// The files written by GenerateDevCA
+const (
+	CaCertFile     = "ca.pem"
+	CaKeyFile      = "ca-key.pem"
+	ServerCertFile = "server.pem"
+	ServerKeyFile  = "server-key.pem"
+)
+
+// GenerateDevCA writes a certificate authority for local development to the directory,
+// and a certificate signed by it for the servers, valid for the hosts and for both server and client authentication.
+// The certificates are valid for a year.
+func GenerateDevCA(dir string, hosts []string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	now := time.Now()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          randomSerial(),
+		Subject:               pkix.Name{CommonName: "Auction development CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+
+	serverKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serverTemplate := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{CommonName: "Auction replication manager"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			serverTemplate.IPAddresses = append(serverTemplate.IPAddresses, ip)
+		} else {
+			serverTemplate.DNSNames = append(serverTemplate.DNSNames, host)
+		}
+	}
+	serverDer, err := x509.CreateCertificate(rand.Reader, serverTemplate, caTemplate, &serverKey.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+
+	files := []struct {
+		name      string
+		blockType string
+		der       []byte
+		key       *ecdsa.PrivateKey
+	}{
+		{CaCertFile, "CERTIFICATE", caDer, nil},
+		{CaKeyFile, "PRIVATE KEY", nil, caKey},
+		{ServerCertFile, "CERTIFICATE", serverDer, nil},
+		{ServerKeyFile, "PRIVATE KEY", nil, serverKey},
+	}
+	for _, file := range files {
+		der := file.der
+		permissions := os.FileMode(0644)
+		if file.key != nil {
+			der, err = x509.MarshalPKCS8PrivateKey(file.key)
+			if err != nil {
+				return err
+			}
+			permissions = 0600
+		}
+		data := pem.EncodeToMemory(&pem.Block{Type: file.blockType, Bytes: der})
+		if err := os.WriteFile(filepath.Join(dir, file.name), data, permissions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func randomSerial() *big.Int {
+	serial, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	return serial
+}
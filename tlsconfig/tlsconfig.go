@@ -0,0 +1,119 @@
+// Package tlsconfig sets up TLS for the servers and frontends, and mutual TLS between the servers.
+//
+// All servers of a cluster share a certificate authority. A frontend only needs the certificate of the authority
+// to trust the servers, while the servers also show their own certificate when they connect to each other.
+// The Auction service is public, but every other grpc service is internal to the cluster and can only be called
+// with a client certificate signed by the authority.
+package tlsconfig
+
+import (
+	"Auction/config"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// The grpc service that anyone may call, the methods of all other services are internal to the cluster
+const publicService = "/Auction.Auction/"
+
+// ServerConfig returns the TLS configuration of a server. Client certificates are optional,
+// but a certificate that is shown must be signed by the certificate authority of the cluster.
+func ServerConfig(tlsConfig config.Tls) (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the certificate: %w", err)
+	}
+	pool, err := loadPool(tlsConfig.CaFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientCAs:    pool,
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// ClientConfig returns the TLS configuration of a frontend, which trusts the servers signed by the certificate authority.
+func ClientConfig(caFile string) (*tls.Config, error) {
+	pool, err := loadPool(caFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
+
+// PeerConfig returns the TLS configuration a server uses to connect to the other servers,
+// showing its own certificate so it is accepted as a member of the cluster.
+func PeerConfig(tlsConfig config.Tls) (*tls.Config, error) {
+	clientConfig, err := ClientConfig(tlsConfig.CaFile)
+	if err != nil {
+		return nil, err
+	}
+	certificate, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the certificate: %w", err)
+	}
+	clientConfig.Certificates = []tls.Certificate{certificate}
+	return clientConfig, nil
+}
+
+// ServerOptions returns the grpc server options for TLS, with interceptors that keep the internal services to the cluster.
+func ServerOptions(serverConfig *tls.Config) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.Creds(credentials.NewTLS(serverConfig)),
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := checkClusterMember(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, request)
+		}),
+		grpc.ChainStreamInterceptor(func(server any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := checkClusterMember(stream.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(server, stream)
+		}),
+	}
+}
+
+// IsClusterMember tells whether the caller showed a client certificate signed by the certificate authority of the cluster.
+func IsClusterMember(ctx context.Context) bool {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	//The chains are only verified when a client certificate was shown and accepted by ClientCAs
+	return ok && len(tlsInfo.State.VerifiedChains) > 0
+}
+
+func checkClusterMember(ctx context.Context, method string) error {
+	if strings.HasPrefix(method, publicService) || IsClusterMember(ctx) {
+		return nil
+	}
+	return status.Error(codes.PermissionDenied, "only members of the cluster may call "+method)
+}
+
+func loadPool(caFile string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the certificate authority: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, errors.New("the certificate authority file has no PEM certificates")
+	}
+	return pool, nil
+}
@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+)
+
+// FileExporter writes every span as a line of JSON to a file, it is safe for concurrent use.
+// Processes should have a file each, the files can be read together by ReadRecords.
+type FileExporter struct {
+	mutex sync.Mutex
+	file  *os.File
+	err   error
+}
+
+// OpenFile opens the file for appending, and creates it if it doesn't exist.
+func OpenFile(path string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileExporter{file: file}, nil
+}
+
+// Export writes the span. If a write fails, the error is logged once and no more spans are written.
+func (exporter *FileExporter) Export(record Record) {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	if exporter.err != nil {
+		return
+	}
+	data, _ := json.Marshal(record)
+	if _, err := exporter.file.Write(append(data, '\n')); err != nil {
+		exporter.err = err
+		slog.Error("Could not write the spans, no more spans are written", "err", err)
+	}
+}
+
+// Close closes the file.
+func (exporter *FileExporter) Close() error {
+	return exporter.file.Close()
+}
+
+// ReadRecords reads the spans written by a FileExporter, sorted by the time they started.
+func ReadRecords(reader io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d is not a span: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	SortRecords(records)
+	return records, nil
+}
+
+// SortRecords sorts spans by the time they started.
+func SortRecords(records []Record) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Start.Before(records[j].Start)
+	})
+}
@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// The key of the trace context in the grpc metadata and HTTP headers
+const traceparentKey = "traceparent"
+
+// Traceparent formats the span context as a W3C traceparent header, which is always sampled.
+func (spanContext SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", spanContext.TraceId, spanContext.SpanId)
+}
+
+// ParseTraceparent reads a W3C traceparent header, it returns false if the header is not valid.
+func ParseTraceparent(traceparent string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}, false
+	}
+	spanContext := SpanContext{TraceId: parts[1], SpanId: parts[2]}
+	if !isHexId(spanContext.TraceId, 16) || !isHexId(spanContext.SpanId, 8) {
+		return SpanContext{}, false
+	}
+	return spanContext, true
+}
+
+func isHexId(id string, size int) bool {
+	if len(id) != 2*size || strings.ToLower(id) != id {
+		return false
+	}
+	decoded, err := hex.DecodeString(id)
+	if err != nil {
+		return false
+	}
+	for _, b := range decoded {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Inject adds the span of the context to the outgoing grpc metadata, so the server continues the trace.
+// Without a span the context is returned as it is.
+func Inject(ctx context.Context) context.Context {
+	spanContext, ok := SpanContextFromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, traceparentKey, spanContext.Traceparent())
+}
+
+// Extract returns a context with the span from the incoming grpc metadata, if the caller sent one.
+func Extract(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	values := md.Get(traceparentKey)
+	if len(values) == 0 {
+		return ctx
+	}
+	if spanContext, ok := ParseTraceparent(values[0]); ok {
+		return ContextWithSpanContext(ctx, spanContext)
+	}
+	return ctx
+}
+
+// ExtractHttp returns the context of the request with the span from its traceparent header, if it has one.
+func ExtractHttp(request *http.Request) context.Context {
+	if spanContext, ok := ParseTraceparent(request.Header.Get(traceparentKey)); ok {
+		return ContextWithSpanContext(request.Context(), spanContext)
+	}
+	return request.Context()
+}
+
+// UnaryServerInterceptor records a span for every call, which continues the trace of the caller.
+func (tracer *Tracer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, span := tracer.Start(Extract(ctx), info.FullMethod)
+		defer span.End()
+		response, err := handler(ctx, request)
+		span.SetAttribute("code", status.Code(err).String())
+		span.SetError(err)
+		return response, err
+	}
+}
+
+// StreamServerInterceptor records a span for every stream, which lasts until the stream ends.
+func (tracer *Tracer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(server any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, span := tracer.Start(Extract(stream.Context()), info.FullMethod)
+		defer span.End()
+		err := handler(server, &contextStream{ServerStream: stream, ctx: ctx})
+		span.SetAttribute("code", status.Code(err).String())
+		span.SetError(err)
+		return err
+	}
+}
+
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *contextStream) Context() context.Context {
+	return stream.ctx
+}
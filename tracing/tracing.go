@@ -0,0 +1,153 @@
+// Package tracing follows a request through the client, the frontend and the replication managers.
+//
+// A Tracer records spans, timed operations that belong to a trace. The trace context is sent to the
+// replication managers in the traceparent metadata of the grpc calls, in the format of W3C Trace Context,
+// so the spans of all processes that handled a request share the trace id. Finished spans are written by an
+// Exporter, fx a FileExporter, which writes one JSON object per line.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// SpanContext identifies a span, the ids are lowercase hex like in W3C Trace Context.
+type SpanContext struct {
+	TraceId string
+	SpanId  string
+}
+
+// Record is a finished span as it is exported.
+type Record struct {
+	TraceId      string            `json:"traceId"`
+	SpanId       string            `json:"spanId"`
+	ParentSpanId string            `json:"parentSpanId,omitempty"`
+	Service      string            `json:"service"`
+	Name         string            `json:"name"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// Duration is the time between the start and the end of the span.
+func (record Record) Duration() time.Duration {
+	return record.End.Sub(record.Start)
+}
+
+// Exporter receives every span when it ends.
+type Exporter interface {
+	Export(record Record)
+}
+
+// Tracer starts spans for a service, fx "frontend" or "replica-0". A nil *Tracer records nothing.
+type Tracer struct {
+	service  string
+	exporter Exporter
+}
+
+// New creates a Tracer that names the spans after the service and sends them to the exporter.
+func New(service string, exporter Exporter) *Tracer {
+	return &Tracer{service: service, exporter: exporter}
+}
+
+// Span is an operation that is being timed. A nil *Span does nothing, so code can use the span
+// that a nil *Tracer returns without checking whether tracing is enabled.
+type Span struct {
+	tracer *Tracer
+	mutex  sync.Mutex
+	record Record
+	ended  bool
+}
+
+type contextKey struct{}
+
+// ContextWithSpanContext returns a context whose spans are children of the span.
+func ContextWithSpanContext(ctx context.Context, spanContext SpanContext) context.Context {
+	return context.WithValue(ctx, contextKey{}, spanContext)
+}
+
+// SpanContextFromContext returns the span of the context, if there is one.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	spanContext, ok := ctx.Value(contextKey{}).(SpanContext)
+	return spanContext, ok
+}
+
+// Start starts a span, which is a child of the span of the context or else the first span of a new trace.
+// The returned context carries the new span, and the span must be ended with End.
+func (tracer *Tracer) Start(ctx context.Context, name string) (context.Context, *Span) {
+	if tracer == nil {
+		return ctx, nil
+	}
+	span := &Span{tracer: tracer, record: Record{
+		SpanId:  newId(8),
+		Service: tracer.service,
+		Name:    name,
+		Start:   time.Now(),
+	}}
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		span.record.TraceId = parent.TraceId
+		span.record.ParentSpanId = parent.SpanId
+	} else {
+		span.record.TraceId = newId(16)
+	}
+	return ContextWithSpanContext(ctx, span.Context()), span
+}
+
+// Context returns the ids of the span.
+func (span *Span) Context() SpanContext {
+	if span == nil {
+		return SpanContext{}
+	}
+	return SpanContext{TraceId: span.record.TraceId, SpanId: span.record.SpanId}
+}
+
+// SetAttribute adds a key and value to the span, fx the bidder of a bid.
+func (span *Span) SetAttribute(key, value string) {
+	if span == nil {
+		return
+	}
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	if span.record.Attributes == nil {
+		span.record.Attributes = make(map[string]string)
+	}
+	span.record.Attributes[key] = value
+}
+
+// SetError marks the span as failed with the error, a nil error is ignored.
+func (span *Span) SetError(err error) {
+	if span == nil || err == nil {
+		return
+	}
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	span.record.Error = err.Error()
+}
+
+// End ends the span and exports it, only the first call has an effect.
+func (span *Span) End() {
+	if span == nil {
+		return
+	}
+	span.mutex.Lock()
+	if span.ended {
+		span.mutex.Unlock()
+		return
+	}
+	span.ended = true
+	span.record.End = time.Now()
+	record := span.record
+	span.mutex.Unlock()
+	span.tracer.exporter.Export(record)
+}
+
+// Random ids, W3C Trace Context doesn't allow ids that are all zeros, which is too unlikely to check
+func newId(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}